@@ -0,0 +1,270 @@
+package bench
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"in-memory-store/constants"
+	"in-memory-store/protocol"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultMix = "set_int=25,get_int=25,set_string=20,get_string=20,set_int_array=5,get_int_array=5"
+
+type latencySummary struct {
+	MinMicros  float64 `json:"min_us"`
+	MeanMicros float64 `json:"mean_us"`
+	P50Micros  float64 `json:"p50_us"`
+	P99Micros  float64 `json:"p99_us"`
+	P999Micros float64 `json:"p999_us"`
+	MaxMicros  float64 `json:"max_us"`
+}
+
+type operationReport struct {
+	Requests     uint64         `json:"requests"`
+	Errors       uint64         `json:"errors"`
+	OpsPerSecond float64        `json:"ops_per_second"`
+	Latency      latencySummary `json:"latency"`
+}
+
+// report is printed as a table or, with -json, as one JSON document that
+// can be compared across commits.
+type report struct {
+	Address         string                     `json:"address"`
+	Clients         int                        `json:"clients"`
+	Mix             string                     `json:"mix"`
+	KeySpace        int                        `json:"keyspace"`
+	ValueSize       string                     `json:"value_size"`
+	ArrayLength     string                     `json:"array_length"`
+	Requests        uint64                     `json:"requests"`
+	Errors          uint64                     `json:"errors"`
+	DurationSeconds float64                    `json:"duration_seconds"`
+	OpsPerSecond    float64                    `json:"ops_per_second"`
+	Latency         latencySummary             `json:"latency"`
+	Operations      map[string]operationReport `json:"operations"`
+}
+
+func micros(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Microsecond)
+}
+
+func summarize(h *histogram) latencySummary {
+	return latencySummary{
+		MinMicros:  micros(h.min),
+		MeanMicros: micros(h.mean()),
+		P50Micros:  micros(h.percentile(50)),
+		P99Micros:  micros(h.percentile(99)),
+		P999Micros: micros(h.percentile(99.9)),
+		MaxMicros:  micros(h.max),
+	}
+}
+
+// workerResult holds what one connection measured, merged after the run so
+// workers never share a histogram.
+type workerResult struct {
+	histograms map[string]*histogram
+	errors     map[string]uint64
+	lastError  error
+}
+
+// Run is the entry point of the bench command. bench keyindex and bench
+// snapshot run the in process key index and snapshot benchmarks instead.
+func Run(args []string) int {
+	if len(args) > 0 && args[0] == "keyindex" {
+		return runKeyIndex(args[1:])
+	}
+	if len(args) > 0 && args[0] == "snapshot" {
+		return runSnapshot(args[1:])
+	}
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	address := flags.String("address", constants.DEFAULT_LISTEN_ADDRESS, "server address")
+	clients := flags.Int("clients", 50, "number of concurrent connections")
+	requests := flags.Int("requests", 100000, "total number of requests, ignored when -duration is set")
+	duration := flags.Duration("duration", 0, "run for this long instead of a fixed number of requests")
+	keySpace := flags.Int("keyspace", 10000, "number of distinct keys per value type")
+	keyPrefix := flags.String("key-prefix", "bench:", "prefix of every key written")
+	valueSize := flags.String("value-size", "16", "string length: N, MIN-MAX or normal:MEAN:STDDEV")
+	arrayLength := flags.String("array-length", "8", "array length: N, MIN-MAX or normal:MEAN:STDDEV")
+	mix := flags.String("mix", defaultMix, "weighted operations, name=weight separated by commas")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "random seed")
+	jsonOutput := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *clients <= 0 || *keySpace <= 0 || (*duration <= 0 && *requests <= 0) {
+		fmt.Fprintln(os.Stderr, "clients, keyspace and requests or duration must be positive")
+		return 2
+	}
+	operations, err := parseMix(*mix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	valueDistribution, err := parseDistribution(*valueSize)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	arrayDistribution, err := parseDistribution(*arrayLength)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	work := &workload{
+		keyPrefix:   *keyPrefix,
+		keySpace:    *keySpace,
+		valueSize:   valueDistribution,
+		arrayLength: arrayDistribution,
+		operations:  operations,
+	}
+	for _, operation := range operations {
+		work.totalWeight += operation.weight
+	}
+
+	connections := []*protocol.Client{}
+	for i := 0; i < *clients; i++ {
+		client, err := protocol.Dial(*address, 30*time.Second)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not open connection %d to %s: %s\n", i+1, *address, err)
+			for _, opened := range connections {
+				opened.Close()
+			}
+			return 1
+		}
+		connections = append(connections, client)
+	}
+	defer func() {
+		for _, client := range connections {
+			client.Close()
+		}
+	}()
+
+	var issued atomic.Int64
+	var deadline time.Time
+	start := time.Now()
+	if *duration > 0 {
+		deadline = start.Add(*duration)
+	}
+	results := make([]*workerResult, len(connections))
+	var wg sync.WaitGroup
+	for i, client := range connections {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := &workerResult{histograms: map[string]*histogram{}, errors: map[string]uint64{}}
+			random := rand.New(rand.NewSource(*seed + int64(i)))
+			for {
+				if *duration > 0 {
+					if time.Now().After(deadline) {
+						break
+					}
+				} else if issued.Add(1) > int64(*requests) {
+					break
+				}
+				operation := work.next(random)
+				name, commandArgs := operation.build(work, random)
+				sent := time.Now()
+				_, err := client.Do(name, commandArgs...)
+				latency := time.Since(sent)
+				h, ok := result.histograms[operation.name]
+				if !ok {
+					h = newHistogram()
+					result.histograms[operation.name] = h
+				}
+				h.record(latency)
+				if err != nil {
+					result.errors[operation.name]++
+					result.lastError = err
+				}
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	benchReport := buildReport(results, elapsed)
+	benchReport.Address = *address
+	benchReport.Clients = *clients
+	benchReport.Mix = *mix
+	benchReport.KeySpace = *keySpace
+	benchReport.ValueSize = *valueSize
+	benchReport.ArrayLength = *arrayLength
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(benchReport)
+	} else {
+		printReport(os.Stdout, benchReport)
+	}
+	for _, result := range results {
+		if result.lastError != nil {
+			fmt.Fprintf(os.Stderr, "last error: %s\n", result.lastError)
+			return 1
+		}
+	}
+	return 0
+}
+
+func buildReport(results []*workerResult, elapsed time.Duration) *report {
+	overall := newHistogram()
+	perOperation := map[string]*histogram{}
+	errors := map[string]uint64{}
+	for _, result := range results {
+		for name, h := range result.histograms {
+			if _, ok := perOperation[name]; !ok {
+				perOperation[name] = newHistogram()
+			}
+			perOperation[name].merge(h)
+			overall.merge(h)
+		}
+		for name, count := range result.errors {
+			errors[name] += count
+		}
+	}
+	seconds := elapsed.Seconds()
+	benchReport := &report{
+		Requests:        overall.total,
+		DurationSeconds: seconds,
+		OpsPerSecond:    float64(overall.total) / seconds,
+		Latency:         summarize(overall),
+		Operations:      map[string]operationReport{},
+	}
+	for name, h := range perOperation {
+		benchReport.Errors += errors[name]
+		benchReport.Operations[name] = operationReport{
+			Requests:     h.total,
+			Errors:       errors[name],
+			OpsPerSecond: float64(h.total) / seconds,
+			Latency:      summarize(h),
+		}
+	}
+	return benchReport
+}
+
+func printReport(out io.Writer, benchReport *report) {
+	fmt.Fprintf(out, "%d requests in %.2fs over %d connections, %d errors\n",
+		benchReport.Requests, benchReport.DurationSeconds, benchReport.Clients, benchReport.Errors)
+	fmt.Fprintf(out, "%.0f ops/sec\n\n", benchReport.OpsPerSecond)
+	fmt.Fprintf(out, "%-18s %10s %12s %10s %10s %10s %10s\n", "operation", "requests", "ops/sec", "p50 us", "p99 us", "p999 us", "max us")
+	names := []string{}
+	for name := range benchReport.Operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	row := func(name string, requests uint64, opsPerSecond float64, latency latencySummary) {
+		fmt.Fprintf(out, "%-18s %10d %12.0f %10.1f %10.1f %10.1f %10.1f\n",
+			name, requests, opsPerSecond, latency.P50Micros, latency.P99Micros, latency.P999Micros, latency.MaxMicros)
+	}
+	for _, name := range names {
+		operation := benchReport.Operations[name]
+		row(name, operation.Requests, operation.OpsPerSecond, operation.Latency)
+	}
+	row("all", benchReport.Requests, benchReport.OpsPerSecond, benchReport.Latency)
+}
@@ -0,0 +1,108 @@
+package bench
+
+import (
+	"math/bits"
+	"time"
+)
+
+// subBuckets per power of two keeps every recorded latency within about
+// 3% of its true value.
+const subBucketBits = 5
+const subBuckets = 1 << subBucketBits
+
+// histogram records latencies in log linear buckets: values below
+// subBuckets nanoseconds get a bucket each, above that every power of two
+// is split into subBuckets equal buckets.
+type histogram struct {
+	counts []uint64
+	total  uint64
+	min    time.Duration
+	max    time.Duration
+	sum    time.Duration
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]uint64, (64-subBucketBits+1)*subBuckets)}
+}
+
+func bucketIndex(value uint64) int {
+	if value < subBuckets {
+		return int(value)
+	}
+	exponent := bits.Len64(value) - 1 - subBucketBits
+	mantissa := value >> uint(exponent)
+	return (exponent+1)*subBuckets + int(mantissa-subBuckets)
+}
+
+// bucketUpperBound is the largest value stored in bucket index.
+func bucketUpperBound(index int) uint64 {
+	if index < subBuckets {
+		return uint64(index)
+	}
+	exponent := index/subBuckets - 1
+	mantissa := uint64(index%subBuckets + subBuckets)
+	return ((mantissa + 1) << uint(exponent)) - 1
+}
+
+func (h *histogram) record(latency time.Duration) {
+	if latency < 0 {
+		latency = 0
+	}
+	h.counts[bucketIndex(uint64(latency))]++
+	if h.total == 0 || latency < h.min {
+		h.min = latency
+	}
+	if latency > h.max {
+		h.max = latency
+	}
+	h.total++
+	h.sum += latency
+}
+
+func (h *histogram) merge(other *histogram) {
+	if other.total == 0 {
+		return
+	}
+	for i, count := range other.counts {
+		h.counts[i] += count
+	}
+	if h.total == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	h.total += other.total
+	h.sum += other.sum
+}
+
+// percentile returns the latency below which p percent of the recorded
+// values fall, p being between 0 and 100.
+func (h *histogram) percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := uint64(p / 100 * float64(h.total))
+	if rank >= h.total {
+		rank = h.total - 1
+	}
+	seen := uint64(0)
+	for i, count := range h.counts {
+		seen += count
+		if seen > rank {
+			upper := time.Duration(bucketUpperBound(i))
+			if upper > h.max {
+				return h.max
+			}
+			return upper
+		}
+	}
+	return h.max
+}
+
+func (h *histogram) mean() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.total)
+}
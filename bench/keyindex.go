@@ -0,0 +1,129 @@
+package bench
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"in-memory-store/schemas"
+	"io"
+	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// keyIndexReport compares a map without and with the key index.
+type keyIndexReport struct {
+	Keys    int              `json:"keys"`
+	Tenants int              `json:"tenants"`
+	Plain   keyIndexVariant  `json:"plain"`
+	Indexed keyIndexVariant  `json:"indexed"`
+	Ratios  keyIndexOverhead `json:"overhead"`
+}
+
+type keyIndexVariant struct {
+	InsertNanos      float64 `json:"insert_ns"`
+	BytesPerKey      float64 `json:"bytes_per_key"`
+	PrefixListMicros float64 `json:"prefix_list_us"`
+	PrefixCountNanos float64 `json:"prefix_count_ns"`
+}
+
+type keyIndexOverhead struct {
+	Insert float64 `json:"insert"`
+	Memory float64 `json:"memory"`
+}
+
+func heapInUse() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// measureKeyIndex fills a fresh map with keys, then lists and counts the
+// keys of every tenant.
+func measureKeyIndex(keys []string, tenants int, indexed bool) keyIndexVariant {
+	before := heapInUse()
+	mainMap := schemas.CreateMainMap()
+	if indexed {
+		mainMap.EnableKeyIndex()
+	}
+	start := time.Now()
+	for i, key := range keys {
+		mainMap.SetInteger(key, int64(i))
+	}
+	insert := time.Since(start)
+	after := heapInUse()
+
+	start = time.Now()
+	for tenant := 0; tenant < tenants; tenant++ {
+		mainMap.KeysWithPrefix("tenant:"+strconv.Itoa(tenant)+":", "", true, -1)
+	}
+	list := time.Since(start)
+	start = time.Now()
+	for tenant := 0; tenant < tenants; tenant++ {
+		mainMap.CountPrefix("tenant:" + strconv.Itoa(tenant) + ":")
+	}
+	count := time.Since(start)
+	runtime.KeepAlive(mainMap)
+	return keyIndexVariant{
+		InsertNanos:      float64(insert.Nanoseconds()) / float64(len(keys)),
+		BytesPerKey:      float64(after-before) / float64(len(keys)),
+		PrefixListMicros: micros(list) / float64(tenants),
+		PrefixCountNanos: float64(count.Nanoseconds()) / float64(tenants),
+	}
+}
+
+// runKeyIndex is the keyindex mode of the bench command. It runs in
+// process, without a server, and reports what the key index costs per
+// insert and per key against what it saves on prefix queries.
+func runKeyIndex(args []string) int {
+	flags := flag.NewFlagSet("bench keyindex", flag.ContinueOnError)
+	keyCount := flags.Int("keys", 200000, "number of keys inserted")
+	tenants := flags.Int("tenants", 200, "number of tenant:N: prefixes the keys are spread over")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "random seed")
+	jsonOutput := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *keyCount <= 0 || *tenants <= 0 {
+		fmt.Fprintln(os.Stderr, "keys and tenants must be positive")
+		return 2
+	}
+	random := rand.New(rand.NewSource(*seed))
+	keys := make([]string, *keyCount)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("tenant:%d:session:%x", random.Intn(*tenants), random.Int63())
+	}
+	keyIndexReport := &keyIndexReport{
+		Keys:    *keyCount,
+		Tenants: *tenants,
+		Plain:   measureKeyIndex(keys, *tenants, false),
+		Indexed: measureKeyIndex(keys, *tenants, true),
+	}
+	keyIndexReport.Ratios = keyIndexOverhead{
+		Insert: keyIndexReport.Indexed.InsertNanos / keyIndexReport.Plain.InsertNanos,
+		Memory: keyIndexReport.Indexed.BytesPerKey / keyIndexReport.Plain.BytesPerKey,
+	}
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(keyIndexReport)
+	} else {
+		printKeyIndexReport(os.Stdout, keyIndexReport)
+	}
+	return 0
+}
+
+func printKeyIndexReport(out io.Writer, keyIndexReport *keyIndexReport) {
+	fmt.Fprintf(out, "%d keys over %d tenants\n\n", keyIndexReport.Keys, keyIndexReport.Tenants)
+	fmt.Fprintf(out, "%-10s %12s %14s %16s %17s\n", "", "insert ns", "bytes/key", "prefix list us", "prefix count ns")
+	row := func(name string, variant keyIndexVariant) {
+		fmt.Fprintf(out, "%-10s %12.0f %14.1f %16.1f %17.0f\n",
+			name, variant.InsertNanos, variant.BytesPerKey, variant.PrefixListMicros, variant.PrefixCountNanos)
+	}
+	row("plain", keyIndexReport.Plain)
+	row("indexed", keyIndexReport.Indexed)
+	fmt.Fprintf(out, "\nthe index costs %.2fx insert time and %.2fx memory\n", keyIndexReport.Ratios.Insert, keyIndexReport.Ratios.Memory)
+}
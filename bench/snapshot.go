@@ -0,0 +1,130 @@
+package bench
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"in-memory-store/constants"
+	"in-memory-store/schemas"
+	"in-memory-store/snapshots"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotReport is what saving and reading back a filled key space took,
+// averaged over the rounds.
+type snapshotReport struct {
+	Keys         int     `json:"keys"`
+	Rounds       int     `json:"rounds"`
+	FileBytes    int64   `json:"file_bytes"`
+	SaveMillis   float64 `json:"save_ms"`
+	ReadMillis   float64 `json:"read_ms"`
+	SaveMBps     float64 `json:"save_mb_per_sec"`
+	ReadMBps     float64 `json:"read_mb_per_sec"`
+	KeysRestored int     `json:"keys_restored"`
+}
+
+func millis(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
+
+// runSnapshot is the snapshot mode of the bench command. It runs in
+// process, without a server, fills every type of the key space as the
+// set_ operations would and times SaveSnapShot and ReadSnapShotFile.
+func runSnapshot(args []string) int {
+	flags := flag.NewFlagSet("bench snapshot", flag.ContinueOnError)
+	keySpace := flags.Int("keyspace", 100000, "number of distinct keys per value type")
+	keyPrefix := flags.String("key-prefix", "bench:", "prefix of every key written")
+	valueSize := flags.String("value-size", "16", "string length: N, MIN-MAX or normal:MEAN:STDDEV")
+	arrayLength := flags.String("array-length", "8", "array length: N, MIN-MAX or normal:MEAN:STDDEV")
+	rounds := flags.Int("rounds", 3, "number of times the snapshot is saved and read")
+	path := flags.String("path", "", "snapshot file, a temporary one when empty")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "random seed")
+	jsonOutput := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *keySpace <= 0 || *rounds <= 0 {
+		fmt.Fprintln(os.Stderr, "keyspace and rounds must be positive")
+		return 2
+	}
+	valueDistribution, err := parseDistribution(*valueSize)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	arrayDistribution, err := parseDistribution(*arrayLength)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *path == "" {
+		directory, err := os.MkdirTemp("", "bench-snapshot")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer os.RemoveAll(directory)
+		*path = filepath.Join(directory, "snapshot")
+	}
+	work := &workload{
+		keyPrefix:   *keyPrefix,
+		keySpace:    *keySpace,
+		valueSize:   valueDistribution,
+		arrayLength: arrayDistribution,
+	}
+	databases := schemas.NewDatabases(1, nil)
+	report := &snapshotReport{
+		Keys:   work.fill(databases.Open(constants.DEFAULT_DATABASE), rand.New(rand.NewSource(*seed))),
+		Rounds: *rounds,
+	}
+
+	var save, read time.Duration
+	for round := 0; round < *rounds; round++ {
+		start := time.Now()
+		if err := snapshots.SaveSnapShot(databases, *path); err != nil {
+			fmt.Fprintf(os.Stderr, "could not save the snapshot to %s: %s\n", *path, err)
+			return 1
+		}
+		save += time.Since(start)
+		restored := schemas.NewDatabases(1, nil)
+		start = time.Now()
+		snapshots.ReadSnapShotFile(restored, *path)
+		read += time.Since(start)
+		report.KeysRestored = len(restored.Open(constants.DEFAULT_DATABASE).Keys())
+	}
+	info, err := os.Stat(*path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	report.FileBytes = info.Size()
+	report.SaveMillis = millis(save) / float64(*rounds)
+	report.ReadMillis = millis(read) / float64(*rounds)
+	megabytes := float64(report.FileBytes) / (1 << 20)
+	report.SaveMBps = megabytes / (report.SaveMillis / 1000)
+	report.ReadMBps = megabytes / (report.ReadMillis / 1000)
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		printSnapshotReport(os.Stdout, report)
+	}
+	if report.KeysRestored != report.Keys {
+		fmt.Fprintf(os.Stderr, "the snapshot restored %d of %d keys\n", report.KeysRestored, report.Keys)
+		return 1
+	}
+	return 0
+}
+
+func printSnapshotReport(out io.Writer, report *snapshotReport) {
+	fmt.Fprintf(out, "%d keys, %.1f MB snapshot, %d rounds\n\n", report.Keys, float64(report.FileBytes)/(1<<20), report.Rounds)
+	fmt.Fprintf(out, "%-6s %10s %10s\n", "", "ms", "MB/s")
+	fmt.Fprintf(out, "%-6s %10.1f %10.1f\n", "save", report.SaveMillis, report.SaveMBps)
+	fmt.Fprintf(out, "%-6s %10.1f %10.1f\n", "read", report.ReadMillis, report.ReadMBps)
+}
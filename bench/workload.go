@@ -0,0 +1,219 @@
+package bench
+
+import (
+	"fmt"
+	"in-memory-store/schemas"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// operation is one kind of request the benchmark sends.
+type operation struct {
+	name   string
+	weight int
+	// build returns the command name and arguments for the next request
+	build func(workload *workload, random *rand.Rand) (string, []interface{})
+}
+
+// distribution draws sizes either fixed ("16"), uniformly ("8-64") or
+// from a normal distribution clamped at zero ("normal:64:16").
+type distribution struct {
+	kind   string
+	first  float64
+	second float64
+}
+
+func parseDistribution(spec string) (distribution, error) {
+	switch {
+	case strings.HasPrefix(spec, "normal:"):
+		parts := strings.Split(strings.TrimPrefix(spec, "normal:"), ":")
+		if len(parts) != 2 {
+			return distribution{}, fmt.Errorf("normal distribution must be normal:mean:stddev, found %q", spec)
+		}
+		mean, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return distribution{}, err
+		}
+		stddev, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return distribution{}, err
+		}
+		return distribution{kind: "normal", first: mean, second: stddev}, nil
+	case strings.Contains(spec, "-"):
+		parts := strings.SplitN(spec, "-", 2)
+		low, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return distribution{}, err
+		}
+		high, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return distribution{}, err
+		}
+		if low < 0 || high < low {
+			return distribution{}, fmt.Errorf("invalid range %q", spec)
+		}
+		return distribution{kind: "uniform", first: float64(low), second: float64(high)}, nil
+	}
+	size, err := strconv.Atoi(spec)
+	if err != nil || size < 0 {
+		return distribution{}, fmt.Errorf("invalid size %q", spec)
+	}
+	return distribution{kind: "fixed", first: float64(size)}, nil
+}
+
+func (d distribution) sample(random *rand.Rand) int {
+	switch d.kind {
+	case "uniform":
+		return int(d.first) + random.Intn(int(d.second-d.first)+1)
+	case "normal":
+		size := int(random.NormFloat64()*d.second + d.first)
+		if size < 0 {
+			return 0
+		}
+		return size
+	}
+	return int(d.first)
+}
+
+type workload struct {
+	keyPrefix   string
+	keySpace    int
+	valueSize   distribution
+	arrayLength distribution
+	operations  []operation
+	totalWeight int
+}
+
+const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func (workload *workload) key(random *rand.Rand, kind string) string {
+	return workload.keyPrefix + kind + ":" + strconv.Itoa(random.Intn(workload.keySpace))
+}
+
+func (workload *workload) text(random *rand.Rand) string {
+	size := workload.valueSize.sample(random)
+	bytes := make([]byte, size)
+	for i := range bytes {
+		bytes[i] = letters[random.Intn(len(letters))]
+	}
+	return string(bytes)
+}
+
+// availableOperations maps the names accepted in -mix to their requests.
+// Every type writes to and reads from its own slice of the key space so
+// reads find values of the expected type.
+var availableOperations = map[string]func(workload *workload, random *rand.Rand) (string, []interface{}){
+	"set_int": func(workload *workload, random *rand.Rand) (string, []interface{}) {
+		return "SET", []interface{}{workload.key(random, "int"), random.Int63()}
+	},
+	"set_float": func(workload *workload, random *rand.Rand) (string, []interface{}) {
+		return "SET", []interface{}{workload.key(random, "float"), random.Float64()}
+	},
+	"set_string": func(workload *workload, random *rand.Rand) (string, []interface{}) {
+		return "SET", []interface{}{workload.key(random, "string"), workload.text(random)}
+	},
+	"set_int_array": func(workload *workload, random *rand.Rand) (string, []interface{}) {
+		values := make([]int64, workload.arrayLength.sample(random))
+		for i := range values {
+			values[i] = random.Int63()
+		}
+		return "SET", []interface{}{workload.key(random, "int_array"), values}
+	},
+	"set_float_array": func(workload *workload, random *rand.Rand) (string, []interface{}) {
+		values := make([]float64, workload.arrayLength.sample(random))
+		for i := range values {
+			values[i] = random.Float64()
+		}
+		return "SET", []interface{}{workload.key(random, "float_array"), values}
+	},
+	"set_string_array": func(workload *workload, random *rand.Rand) (string, []interface{}) {
+		values := make([]string, workload.arrayLength.sample(random))
+		for i := range values {
+			values[i] = workload.text(random)
+		}
+		return "SET", []interface{}{workload.key(random, "string_array"), values}
+	},
+	"get_int": func(workload *workload, random *rand.Rand) (string, []interface{}) {
+		return "GET", []interface{}{workload.key(random, "int")}
+	},
+	"get_float": func(workload *workload, random *rand.Rand) (string, []interface{}) {
+		return "GET", []interface{}{workload.key(random, "float")}
+	},
+	"get_string": func(workload *workload, random *rand.Rand) (string, []interface{}) {
+		return "GET", []interface{}{workload.key(random, "string")}
+	},
+	"get_int_array": func(workload *workload, random *rand.Rand) (string, []interface{}) {
+		return "GET", []interface{}{workload.key(random, "int_array")}
+	},
+	"get_float_array": func(workload *workload, random *rand.Rand) (string, []interface{}) {
+		return "GET", []interface{}{workload.key(random, "float_array")}
+	},
+	"get_string_array": func(workload *workload, random *rand.Rand) (string, []interface{}) {
+		return "GET", []interface{}{workload.key(random, "string_array")}
+	},
+	"del": func(workload *workload, random *rand.Rand) (string, []interface{}) {
+		return "DEL", []interface{}{workload.key(random, "string")}
+	},
+	"ping": func(workload *workload, random *rand.Rand) (string, []interface{}) {
+		return "PING", nil
+	},
+}
+
+// valueKinds are the suffixes of the set_ operations, one per value type.
+var valueKinds = []string{"int", "float", "string", "int_array", "float_array", "string_array"}
+
+// fill stores a value of every type under every key of the key space,
+// drawn as the set_ operations draw them, and returns the number of keys.
+func (workload *workload) fill(mainMap *schemas.MainMap, random *rand.Rand) int {
+	for _, kind := range valueKinds {
+		build := availableOperations["set_"+kind]
+		for i := 0; i < workload.keySpace; i++ {
+			_, args := build(workload, random)
+			mainMap.SetValue(workload.keyPrefix+kind+":"+strconv.Itoa(i), args[1])
+		}
+	}
+	return len(valueKinds) * workload.keySpace
+}
+
+// parseMix reads "name=weight,name=weight" into weighted operations.
+func parseMix(spec string) ([]operation, error) {
+	operations := []operation{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, weightText, found := strings.Cut(part, "=")
+		weight := 1
+		if found {
+			parsed, err := strconv.Atoi(weightText)
+			if err != nil || parsed < 0 {
+				return nil, fmt.Errorf("invalid weight in %q", part)
+			}
+			weight = parsed
+		}
+		build, ok := availableOperations[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown operation %q", name)
+		}
+		if weight > 0 {
+			operations = append(operations, operation{name: name, weight: weight, build: build})
+		}
+	}
+	if len(operations) == 0 {
+		return nil, fmt.Errorf("the mix needs at least one operation with a positive weight")
+	}
+	return operations, nil
+}
+
+func (workload *workload) next(random *rand.Rand) *operation {
+	pick := random.Intn(workload.totalWeight)
+	for i := range workload.operations {
+		if pick < workload.operations[i].weight {
+			return &workload.operations[i]
+		}
+		pick -= workload.operations[i].weight
+	}
+	return &workload.operations[len(workload.operations)-1]
+}
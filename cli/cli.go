@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"in-memory-store/constants"
+	"in-memory-store/protocol"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const historyFileName = ".in-memory-store-history"
+
+type shell struct {
+	address  string
+	timeout  time.Duration
+	client   *protocol.Client
+	commands []string
+	out      io.Writer
+}
+
+// Run is the entry point of the cli command. With arguments it runs them
+// as a single command, otherwise it starts the REPL when stdin is a
+// terminal and runs stdin line by line as a script when it is not.
+func Run(args []string) int {
+	flags := flag.NewFlagSet("cli", flag.ContinueOnError)
+	address := flags.String("address", constants.DEFAULT_LISTEN_ADDRESS, "server address")
+	timeoutSeconds := flags.Int("timeout", 10, "seconds to wait for the server, 0 waits forever")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	repl := &shell{
+		address: *address,
+		timeout: time.Duration(*timeoutSeconds) * time.Second,
+		out:     os.Stdout,
+	}
+	if err := repl.connect(); err != nil {
+		fmt.Fprintf(os.Stderr, "could not connect to %s: %s\n", repl.address, err)
+		return 1
+	}
+	defer repl.client.Close()
+
+	if flags.NArg() > 0 {
+		values := []interface{}{flags.Arg(0)}
+		for _, arg := range flags.Args()[1:] {
+			value, err := parseArg(arg)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 2
+			}
+			values = append(values, value)
+		}
+		if !repl.execute(values) {
+			return 1
+		}
+		return 0
+	}
+	if isTerminal(int(os.Stdin.Fd())) {
+		return repl.interactive()
+	}
+	return repl.script(os.Stdin)
+}
+
+func (shell *shell) connect() error {
+	client, err := protocol.Dial(shell.address, shell.timeout)
+	if err != nil {
+		return err
+	}
+	shell.client = client
+	if names, err := client.Do("COMMANDS"); err == nil {
+		if list, ok := names.([]string); ok {
+			shell.commands = list
+		}
+	}
+	return nil
+}
+
+// execute runs one parsed command and prints its reply. A broken
+// connection is reopened, and the command sent again only when it never
+// reached the server, so it can not run twice.
+func (shell *shell) execute(values []interface{}) bool {
+	name := values[0].(string)
+	shell.client.SetTimeout(shell.requestTimeout(values))
+	reply, err := shell.client.Do(name, values[1:]...)
+	var protocolError *protocol.ProtocolError
+	if err != nil && !errors.As(err, &protocolError) {
+		shell.client.Close()
+		if reconnectErr := shell.connect(); reconnectErr != nil {
+			fmt.Fprintln(shell.out, FormatError(err))
+			return false
+		}
+		if errors.Is(err, protocol.ErrNotSent) {
+			shell.client.SetTimeout(shell.requestTimeout(values))
+			reply, err = shell.client.Do(name, values[1:]...)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(shell.out, FormatError(err))
+		return false
+	}
+	fmt.Fprintln(shell.out, FormatReply(reply))
+	return true
+}
+
+// requestTimeout is how long to wait for the reply to values. BLPOP and
+// BRPOP get their own timeout on top, and none when they block forever.
+func (shell *shell) requestTimeout(values []interface{}) time.Duration {
+	name := strings.ToUpper(values[0].(string))
+	if (name != "BLPOP" && name != "BRPOP") || len(values) < 3 || shell.timeout == 0 {
+		return shell.timeout
+	}
+	var seconds float64
+	switch v := values[len(values)-1].(type) {
+	case int64:
+		seconds = float64(v)
+	case float64:
+		seconds = v
+	default:
+		return shell.timeout
+	}
+	if seconds == 0 {
+		return 0
+	}
+	return shell.timeout + time.Duration(seconds*float64(time.Second))
+}
+
+func (shell *shell) completeCommand(prefix string) []string {
+	matches := []string{}
+	for _, name := range shell.commands {
+		if strings.HasPrefix(name, strings.ToUpper(prefix)) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
+
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, historyFileName)
+}
+
+func (shell *shell) interactive() int {
+	fd := int(os.Stdin.Fd())
+	state, err := makeRaw(fd)
+	if err != nil {
+		return shell.script(os.Stdin)
+	}
+	defer restoreTerminal(fd, state)
+
+	editor := newLineEditor(os.Stdin, os.Stdout, shell.completeCommand)
+	path := historyPath()
+	if data, err := os.ReadFile(path); err == nil && path != "" {
+		for _, line := range strings.Split(string(data), "\n") {
+			editor.addHistory(line)
+		}
+	}
+	var historyFile *os.File
+	if path != "" {
+		historyFile, _ = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	}
+	if historyFile != nil {
+		defer historyFile.Close()
+	}
+
+	prompt := shell.address + "> "
+	for {
+		line, err := editor.readLine(prompt)
+		if err == errInterrupted {
+			continue
+		}
+		if err != nil {
+			return 0
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		editor.addHistory(line)
+		if historyFile != nil {
+			fmt.Fprintln(historyFile, line)
+		}
+		if quit := shell.runLine(line); quit {
+			return 0
+		}
+	}
+}
+
+// runLine parses and executes one line and reports whether the user asked
+// to quit.
+func (shell *shell) runLine(line string) bool {
+	switch strings.ToLower(line) {
+	case "quit", "exit":
+		return true
+	case "help":
+		fmt.Fprintln(shell.out, strings.Join(shell.commands, " "))
+		return false
+	}
+	values, err := ParseLine(line)
+	if err != nil {
+		fmt.Fprintln(shell.out, FormatError(err))
+		return false
+	}
+	shell.execute(values)
+	return false
+}
+
+// script runs every non empty line of in that is not a # comment and
+// returns 1 when any command failed.
+func (shell *shell) script(in io.Reader) int {
+	exitCode := 0
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), constants.DEFAULT_MAX_FRAME_SIZE)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.EqualFold(line, "quit") || strings.EqualFold(line, "exit") {
+			break
+		}
+		values, err := ParseLine(line)
+		if err != nil {
+			fmt.Fprintln(shell.out, FormatError(err))
+			exitCode = 1
+			continue
+		}
+		if !shell.execute(values) {
+			exitCode = 1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return exitCode
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FormatReply renders a typed reply the way it is shown in the REPL, one
+// element per numbered line for arrays.
+func FormatReply(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "(nil)"
+	case int64:
+		return fmt.Sprintf("(integer) %d", v)
+	case float64:
+		return fmt.Sprintf("(float) %s", strconv.FormatFloat(v, 'g', -1, 64))
+	case string:
+		return strconv.Quote(v)
+	case []int64:
+		items := []interface{}{}
+		for _, item := range v {
+			items = append(items, item)
+		}
+		return formatList(items)
+	case []float64:
+		items := []interface{}{}
+		for _, item := range v {
+			items = append(items, item)
+		}
+		return formatList(items)
+	case []string:
+		items := []interface{}{}
+		for _, item := range v {
+			items = append(items, item)
+		}
+		return formatList(items)
+	case []interface{}:
+		return formatList(v)
+	case error:
+		return FormatError(v)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func formatList(items []interface{}) string {
+	if len(items) == 0 {
+		return "(empty array)"
+	}
+	width := len(strconv.Itoa(len(items)))
+	lines := []string{}
+	for i, item := range items {
+		prefix := fmt.Sprintf("%*d) ", width, i+1)
+		formatted := FormatReply(item)
+		// nested lists are indented under their index
+		formatted = strings.ReplaceAll(formatted, "\n", "\n"+strings.Repeat(" ", len(prefix)))
+		lines = append(lines, prefix+formatted)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func FormatError(err error) string {
+	return fmt.Sprintf("(error) %s", err.Error())
+}
@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+const maxHistory = 1000
+
+// lineEditor reads lines from a terminal in raw mode, with cursor movement,
+// history on the arrow keys and tab completion of the first word.
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  []string
+	complete func(prefix string) []string
+}
+
+func newLineEditor(in io.Reader, out io.Writer, complete func(prefix string) []string) *lineEditor {
+	return &lineEditor{
+		in:       bufio.NewReader(in),
+		out:      out,
+		complete: complete,
+	}
+}
+
+func (editor *lineEditor) addHistory(line string) {
+	if line == "" || (len(editor.history) > 0 && editor.history[len(editor.history)-1] == line) {
+		return
+	}
+	editor.history = append(editor.history, line)
+	if len(editor.history) > maxHistory {
+		editor.history = editor.history[len(editor.history)-maxHistory:]
+	}
+}
+
+func (editor *lineEditor) render(prompt string, line []rune, cursor int) {
+	fmt.Fprintf(editor.out, "\r%s%s\x1b[K\r", prompt, string(line))
+	if offset := len([]rune(prompt)) + cursor; offset > 0 {
+		fmt.Fprintf(editor.out, "\x1b[%dC", offset)
+	}
+}
+
+// readLine returns the entered line, io.EOF on Ctrl-D with an empty line
+// and errInterrupted on Ctrl-C.
+func (editor *lineEditor) readLine(prompt string) (string, error) {
+	line := []rune{}
+	cursor := 0
+	historyIndex := len(editor.history)
+	pending := ""
+	editor.render(prompt, line, cursor)
+	for {
+		r, _, err := editor.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(editor.out, "\r\n")
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(editor.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(editor.out, "\r\n")
+				return "", io.EOF
+			}
+			if cursor < len(line) {
+				line = append(line[:cursor], line[cursor+1:]...)
+			}
+		case 127, 8: // Backspace
+			if cursor > 0 {
+				line = append(line[:cursor-1], line[cursor:]...)
+				cursor--
+			}
+		case 1: // Ctrl-A
+			cursor = 0
+		case 5: // Ctrl-E
+			cursor = len(line)
+		case 11: // Ctrl-K
+			line = line[:cursor]
+		case 21: // Ctrl-U
+			line = line[cursor:]
+			cursor = 0
+		case 12: // Ctrl-L
+			fmt.Fprint(editor.out, "\x1b[H\x1b[2J")
+		case '\t':
+			line, cursor = editor.completeLine(prompt, line, cursor)
+		case 27: // escape sequences for arrows, home, end and delete
+			sequence := editor.readEscape()
+			switch sequence {
+			case "[A", "OA":
+				if historyIndex > 0 {
+					if historyIndex == len(editor.history) {
+						pending = string(line)
+					}
+					historyIndex--
+					line = []rune(editor.history[historyIndex])
+					cursor = len(line)
+				}
+			case "[B", "OB":
+				if historyIndex < len(editor.history) {
+					historyIndex++
+					if historyIndex == len(editor.history) {
+						line = []rune(pending)
+					} else {
+						line = []rune(editor.history[historyIndex])
+					}
+					cursor = len(line)
+				}
+			case "[C", "OC":
+				if cursor < len(line) {
+					cursor++
+				}
+			case "[D", "OD":
+				if cursor > 0 {
+					cursor--
+				}
+			case "[H", "OH", "[1~":
+				cursor = 0
+			case "[F", "OF", "[4~":
+				cursor = len(line)
+			case "[3~":
+				if cursor < len(line) {
+					line = append(line[:cursor], line[cursor+1:]...)
+				}
+			}
+		default:
+			if r >= 32 {
+				line = append(line[:cursor], append([]rune{r}, line[cursor:]...)...)
+				cursor++
+			}
+		}
+		editor.render(prompt, line, cursor)
+	}
+}
+
+func (editor *lineEditor) readEscape() string {
+	first, _, err := editor.in.ReadRune()
+	if err != nil {
+		return ""
+	}
+	sequence := string(first)
+	for {
+		r, _, err := editor.in.ReadRune()
+		if err != nil {
+			return sequence
+		}
+		sequence += string(r)
+		// a sequence ends with a letter or a tilde
+		if r == '~' || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') {
+			return sequence
+		}
+	}
+}
+
+// completeLine completes the first word. A single match is inserted with a
+// trailing space, several matches are extended to their common prefix or
+// listed below the prompt.
+func (editor *lineEditor) completeLine(prompt string, line []rune, cursor int) ([]rune, int) {
+	if editor.complete == nil || strings.ContainsAny(string(line[:cursor]), " \t") {
+		return line, cursor
+	}
+	prefix := string(line[:cursor])
+	matches := editor.complete(prefix)
+	if len(matches) == 0 {
+		return line, cursor
+	}
+	completion := matches[0]
+	if len(matches) == 1 {
+		completion += " "
+	} else {
+		completion = commonPrefix(matches)
+		if len(completion) <= len(prefix) {
+			sort.Strings(matches)
+			fmt.Fprintf(editor.out, "\r\n%s\r\n", strings.Join(matches, "  "))
+			return line, cursor
+		}
+	}
+	rest := line[cursor:]
+	line = append([]rune(completion), rest...)
+	return line, len([]rune(completion))
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(strings.ToUpper(word), strings.ToUpper(prefix)) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
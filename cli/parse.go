@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// token is one word of a command line. Quoted tokens are always strings,
+// bare tokens become numbers when they parse as one.
+type token struct {
+	text   string
+	quoted bool
+	array  bool
+}
+
+// ParseLine splits a command line into typed values: integers become
+// int64, decimals float64, quoted or other words string, and bracketed
+// lists such as [1,2,3] or ["a","b"] the matching array type.
+func ParseLine(line string) ([]interface{}, error) {
+	tokens, err := tokenize(line)
+	if err != nil {
+		return nil, err
+	}
+	values := []interface{}{}
+	for i, token := range tokens {
+		// the command name stays text even if it looks like a number
+		if i == 0 {
+			values = append(values, token.text)
+			continue
+		}
+		value, err := token.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// parseArg types a single argument that the shell has already split, so
+// quotes and brackets inside it keep their meaning.
+func parseArg(arg string) (interface{}, error) {
+	tokens, err := tokenize(arg)
+	if err != nil || len(tokens) != 1 {
+		return arg, nil
+	}
+	return tokens[0].value()
+}
+
+func tokenize(line string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(line)
+	for i := 0; i < len(runes); {
+		switch {
+		case runes[i] == ' ' || runes[i] == '\t':
+			i++
+		case runes[i] == '"' || runes[i] == '\'':
+			text, next, err := readQuoted(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{text: text, quoted: true})
+			i = next
+		case runes[i] == '[':
+			end, err := findClosingBracket(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{text: string(runes[i+1 : end]), array: true})
+			i = end + 1
+		default:
+			start := i
+			for i < len(runes) && runes[i] != ' ' && runes[i] != '\t' {
+				i++
+			}
+			tokens = append(tokens, token{text: string(runes[start:i])})
+		}
+	}
+	return tokens, nil
+}
+
+// readQuoted reads a quoted string starting at runes[start] and returns it
+// unescaped together with the index after the closing quote.
+func readQuoted(runes []rune, start int) (string, int, error) {
+	quote := runes[start]
+	var builder strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		switch {
+		case runes[i] == '\\' && i+1 < len(runes):
+			i++
+			switch runes[i] {
+			case 'n':
+				builder.WriteRune('\n')
+			case 't':
+				builder.WriteRune('\t')
+			case 'r':
+				builder.WriteRune('\r')
+			default:
+				builder.WriteRune(runes[i])
+			}
+		case runes[i] == quote:
+			return builder.String(), i + 1, nil
+		default:
+			builder.WriteRune(runes[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted string")
+}
+
+func findClosingBracket(runes []rune, start int) (int, error) {
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '"', '\'':
+			_, next, err := readQuoted(runes, i)
+			if err != nil {
+				return 0, err
+			}
+			i = next - 1
+		case ']':
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated array, missing ]")
+}
+
+func (t token) value() (interface{}, error) {
+	if t.quoted {
+		return t.text, nil
+	}
+	if t.array {
+		return parseArray(t.text)
+	}
+	if integer, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+		return integer, nil
+	}
+	if float, err := strconv.ParseFloat(t.text, 64); err == nil && looksNumeric(t.text) {
+		return float, nil
+	}
+	return t.text, nil
+}
+
+// looksNumeric keeps words such as "inf" or "nan" as strings, they are
+// accepted by strconv but rarely meant as numbers on a command line.
+func looksNumeric(text string) bool {
+	return strings.ContainsAny(text, "0123456789")
+}
+
+// parseArray turns the inside of [...] into []int64, []float64 or
+// []string. Mixing integers and decimals gives []float64, any string
+// element makes the whole array []string.
+func parseArray(text string) (interface{}, error) {
+	elements, err := splitArray(text)
+	if err != nil {
+		return nil, err
+	}
+	values := []interface{}{}
+	allIntegers, allNumbers := true, true
+	for _, element := range elements {
+		value, err := element.value()
+		if err != nil {
+			return nil, err
+		}
+		switch value.(type) {
+		case int64:
+		case float64:
+			allIntegers = false
+		default:
+			allIntegers, allNumbers = false, false
+		}
+		values = append(values, value)
+	}
+	switch {
+	case len(values) == 0:
+		return []string{}, nil
+	case allIntegers:
+		integers := []int64{}
+		for _, value := range values {
+			integers = append(integers, value.(int64))
+		}
+		return integers, nil
+	case allNumbers:
+		floats := []float64{}
+		for _, value := range values {
+			switch v := value.(type) {
+			case int64:
+				floats = append(floats, float64(v))
+			case float64:
+				floats = append(floats, v)
+			}
+		}
+		return floats, nil
+	}
+	strs := []string{}
+	for _, element := range elements {
+		strs = append(strs, element.text)
+	}
+	return strs, nil
+}
+
+func splitArray(text string) ([]token, error) {
+	elements := []token{}
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		switch {
+		case runes[i] == ' ' || runes[i] == '\t' || runes[i] == ',':
+			i++
+		case runes[i] == '"' || runes[i] == '\'':
+			value, next, err := readQuoted(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, token{text: value, quoted: true})
+			i = next
+		case runes[i] == '[':
+			return nil, fmt.Errorf("nested arrays are not supported")
+		default:
+			start := i
+			for i < len(runes) && runes[i] != ',' && runes[i] != ' ' && runes[i] != '\t' {
+				i++
+			}
+			elements = append(elements, token{text: string(runes[start:i])})
+		}
+	}
+	return elements, nil
+}
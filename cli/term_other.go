@@ -0,0 +1,23 @@
+//go:build !linux
+
+package cli
+
+import (
+	"errors"
+)
+
+type terminalState struct{}
+
+// Raw terminal input is only implemented on linux, elsewhere the REPL
+// reads whole lines without history or completion.
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (*terminalState, error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+func restoreTerminal(fd int, state *terminalState) error {
+	return nil
+}
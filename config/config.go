@@ -0,0 +1,451 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"in-memory-store/constants"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is everything the server reads at startup. Values are resolved
+// in order: defaults, config file, environment, command line flags.
+type Config struct {
+	ListenAddresses         []string `json:"listen_addresses"`
+	SnapshotPath            string   `json:"snapshot_path"`
+	SnapshotEveryOperations int      `json:"snapshot_every_operations"`
+	SnapshotIntervalSeconds int      `json:"snapshot_interval_seconds"`
+	MaxMemoryBytes          int64    `json:"max_memory_bytes"`
+	LogLevel                string   `json:"log_level"`
+	MaxConnections          int      `json:"max_connections"`
+	MaxFrameSize            uint64   `json:"max_frame_size"`
+	ReadTimeoutSeconds      int      `json:"read_timeout_seconds"`
+	WriteTimeoutSeconds     int      `json:"write_timeout_seconds"`
+	IdleTimeoutSeconds      int      `json:"idle_timeout_seconds"`
+	ShutdownTimeoutSeconds  int      `json:"shutdown_timeout_seconds"`
+	KeyIndex                bool     `json:"key_index"`
+	Databases               int      `json:"databases"`
+	DatabaseNames           []string `json:"database_names"`
+	ScriptMaxSteps          int      `json:"script_max_steps"`
+	ScriptTimeoutMillis     int      `json:"script_timeout_milliseconds"`
+}
+
+func Default() *Config {
+	return &Config{
+		ListenAddresses:         []string{constants.DEFAULT_LISTEN_ADDRESS},
+		SnapshotPath:            constants.SNAPSHOT_FILE_NAME,
+		SnapshotEveryOperations: constants.RUN_SNAPSHOT_AFTER,
+		SnapshotIntervalSeconds: constants.DEFAULT_SNAPSHOT_INTERVAL,
+		MaxMemoryBytes:          0,
+		LogLevel:                "info",
+		MaxConnections:          constants.DEFAULT_MAX_CONNECTIONS,
+		MaxFrameSize:            uint64(constants.DEFAULT_MAX_FRAME_SIZE),
+		ReadTimeoutSeconds:      constants.DEFAULT_READ_TIMEOUT_SECONDS,
+		WriteTimeoutSeconds:     constants.DEFAULT_WRITE_TIMEOUT_SECONDS,
+		IdleTimeoutSeconds:      constants.DEFAULT_IDLE_TIMEOUT_SECONDS,
+		ShutdownTimeoutSeconds:  constants.DEFAULT_SHUTDOWN_TIMEOUT_SECONDS,
+		Databases:               constants.DEFAULT_DATABASE_COUNT,
+		ScriptMaxSteps:          constants.DEFAULT_SCRIPT_MAX_STEPS,
+		ScriptTimeoutMillis:     constants.DEFAULT_SCRIPT_TIMEOUT_MILLISECONDS,
+	}
+}
+
+// LoadFile overlays the JSON file at path on top of config. Fields missing
+// from the file keep their current value, unknown fields are an error.
+func (config *Config) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return fmt.Errorf("failed parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// ApplyEnv overlays CACHE_* environment variables on top of config.
+func (config *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	for _, setting := range config.settings() {
+		value, ok := lookup(setting.env)
+		if !ok {
+			continue
+		}
+		if err := setting.set(value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", setting.env, err)
+		}
+	}
+	return nil
+}
+
+// Validate reports the first setting that is out of range.
+func (config *Config) Validate() error {
+	if len(config.ListenAddresses) == 0 {
+		return fmt.Errorf("at least one listen address is required")
+	}
+	for _, address := range config.ListenAddresses {
+		if !strings.Contains(address, ":") {
+			return fmt.Errorf("listen address %q must be host:port", address)
+		}
+	}
+	if config.SnapshotPath == "" {
+		return fmt.Errorf("snapshot_path must not be empty")
+	}
+	if config.SnapshotEveryOperations < 0 {
+		return fmt.Errorf("snapshot_every_operations must not be negative")
+	}
+	if config.SnapshotIntervalSeconds < 0 {
+		return fmt.Errorf("snapshot_interval_seconds must not be negative")
+	}
+	if config.MaxMemoryBytes < 0 {
+		return fmt.Errorf("max_memory_bytes must not be negative")
+	}
+	switch config.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("log_level must be one of debug, info, warn, error, found %q", config.LogLevel)
+	}
+	if config.MaxConnections < 0 {
+		return fmt.Errorf("max_connections must not be negative")
+	}
+	if config.MaxFrameSize == 0 {
+		return fmt.Errorf("max_frame_size must be positive")
+	}
+	if config.ReadTimeoutSeconds < 0 || config.WriteTimeoutSeconds < 0 || config.IdleTimeoutSeconds < 0 {
+		return fmt.Errorf("timeouts must not be negative")
+	}
+	if config.ShutdownTimeoutSeconds <= 0 {
+		return fmt.Errorf("shutdown_timeout_seconds must be positive")
+	}
+	if config.ScriptMaxSteps < 0 || config.ScriptTimeoutMillis < 0 {
+		return fmt.Errorf("script limits must not be negative")
+	}
+	if config.Databases < 1 {
+		return fmt.Errorf("databases must be at least 1")
+	}
+	seen := map[string]bool{}
+	for _, name := range config.DatabaseNames {
+		if name == "" || seen[name] {
+			return fmt.Errorf("database names must not be empty or repeated, found %q", name)
+		}
+		if _, err := strconv.Atoi(name); err == nil {
+			return fmt.Errorf("database name %q is a number, numbered databases come from databases", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+func (config *Config) ReadTimeout() time.Duration {
+	return time.Duration(config.ReadTimeoutSeconds) * time.Second
+}
+
+func (config *Config) WriteTimeout() time.Duration {
+	return time.Duration(config.WriteTimeoutSeconds) * time.Second
+}
+
+func (config *Config) IdleTimeout() time.Duration {
+	return time.Duration(config.IdleTimeoutSeconds) * time.Second
+}
+
+func (config *Config) SnapshotInterval() time.Duration {
+	return time.Duration(config.SnapshotIntervalSeconds) * time.Second
+}
+
+func (config *Config) ShutdownTimeout() time.Duration {
+	return time.Duration(config.ShutdownTimeoutSeconds) * time.Second
+}
+
+func (config *Config) ScriptTimeout() time.Duration {
+	return time.Duration(config.ScriptTimeoutMillis) * time.Millisecond
+}
+
+// setting binds one field to its JSON name, environment variable and
+// command line flag. Hot settings can change while the server runs, the
+// others only take effect after a restart.
+type setting struct {
+	name  string
+	env   string
+	usage string
+	hot   bool
+	get   func() string
+	set   func(string) error
+}
+
+func stringSetting(name string, usage string, hot bool, field *string) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		hot:   hot,
+		get:   func() string { return *field },
+		set: func(value string) error {
+			*field = value
+			return nil
+		},
+	}
+}
+
+func intSetting(name string, usage string, hot bool, field *int) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		hot:   hot,
+		get:   func() string { return strconv.Itoa(*field) },
+		set: func(value string) error {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+			*field = parsed
+			return nil
+		},
+	}
+}
+
+func int64Setting(name string, usage string, hot bool, field *int64) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		hot:   hot,
+		get:   func() string { return strconv.FormatInt(*field, 10) },
+		set: func(value string) error {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return err
+			}
+			*field = parsed
+			return nil
+		},
+	}
+}
+
+func uint64Setting(name string, usage string, hot bool, field *uint64) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		hot:   hot,
+		get:   func() string { return strconv.FormatUint(*field, 10) },
+		set: func(value string) error {
+			parsed, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return err
+			}
+			*field = parsed
+			return nil
+		},
+	}
+}
+
+func boolSetting(name string, usage string, hot bool, field *bool) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		hot:   hot,
+		get:   func() string { return strconv.FormatBool(*field) },
+		set: func(value string) error {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			*field = parsed
+			return nil
+		},
+	}
+}
+
+func listSetting(name string, usage string, hot bool, field *[]string) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		hot:   hot,
+		get:   func() string { return strings.Join(*field, ",") },
+		set: func(value string) error {
+			list := []string{}
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			*field = list
+			return nil
+		},
+	}
+}
+
+func (config *Config) settings() []setting {
+	settings := []setting{
+		listSetting("listen_addresses", "comma separated host:port addresses to listen on", false, &config.ListenAddresses),
+		stringSetting("snapshot_path", "path of the snapshot file", true, &config.SnapshotPath),
+		intSetting("snapshot_every_operations", "take a snapshot after this many writes, 0 disables", true, &config.SnapshotEveryOperations),
+		intSetting("snapshot_interval_seconds", "take a snapshot this often when keys changed, 0 disables", true, &config.SnapshotIntervalSeconds),
+		int64Setting("max_memory_bytes", "reject writes above this heap size, 0 disables", true, &config.MaxMemoryBytes),
+		stringSetting("log_level", "debug, info, warn or error", true, &config.LogLevel),
+		intSetting("max_connections", "maximum concurrent clients, 0 disables", true, &config.MaxConnections),
+		uint64Setting("max_frame_size", "maximum frame content length in bytes, changes apply to new connections", true, &config.MaxFrameSize),
+		intSetting("read_timeout_seconds", "time allowed to read a frame once started, 0 disables", true, &config.ReadTimeoutSeconds),
+		intSetting("write_timeout_seconds", "time allowed to write a reply, 0 disables", true, &config.WriteTimeoutSeconds),
+		intSetting("idle_timeout_seconds", "close clients idle this long, 0 disables", true, &config.IdleTimeoutSeconds),
+		intSetting("shutdown_timeout_seconds", "time allowed to drain clients on shutdown", true, &config.ShutdownTimeoutSeconds),
+		boolSetting("key_index", "keep keys in a radix tree for fast prefix listing, counting and deletion", false, &config.KeyIndex),
+		intSetting("databases", "number of numbered databases, 0 to databases-1", false, &config.Databases),
+		listSetting("database_names", "comma separated names of additional databases", false, &config.DatabaseNames),
+		intSetting("script_max_steps", "stop scripts after this many steps, 0 disables", true, &config.ScriptMaxSteps),
+		intSetting("script_timeout_milliseconds", "stop scripts running longer than this, 0 disables", true, &config.ScriptTimeoutMillis),
+	}
+	for i := range settings {
+		settings[i].env = "CACHE_" + strings.ToUpper(settings[i].name)
+	}
+	return settings
+}
+
+func (config *Config) lookupSetting(name string) (setting, bool) {
+	for _, setting := range config.settings() {
+		if setting.name == name {
+			return setting, true
+		}
+	}
+	return setting{}, false
+}
+
+// Clone returns a deep copy so a running config is never mutated in place.
+func (config *Config) Clone() *Config {
+	clone := *config
+	clone.ListenAddresses = append([]string{}, config.ListenAddresses...)
+	if config.DatabaseNames != nil {
+		clone.DatabaseNames = append([]string{}, config.DatabaseNames...)
+	}
+	return &clone
+}
+
+// Get returns name, value pairs of every setting matching the glob pattern.
+func (config *Config) Get(pattern string) []string {
+	pairs := []string{}
+	for _, setting := range config.settings() {
+		if matched, _ := path.Match(pattern, setting.name); matched {
+			pairs = append(pairs, setting.name, setting.get())
+		}
+	}
+	return pairs
+}
+
+// WithChanges applies name, value pairs to a copy of config. Either every
+// change is valid and the copy is returned, or nothing is applied.
+func (config *Config) WithChanges(pairs []string) (*Config, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("expected name value pairs, found %d arguments", len(pairs))
+	}
+	updated := config.Clone()
+	for i := 0; i < len(pairs); i += 2 {
+		setting, ok := updated.lookupSetting(pairs[i])
+		if !ok {
+			return nil, fmt.Errorf("unknown setting %q", pairs[i])
+		}
+		if !setting.hot {
+			return nil, fmt.Errorf("setting %q cannot be changed at runtime, restart the server", pairs[i])
+		}
+		if err := setting.set(pairs[i+1]); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", pairs[i], err)
+		}
+	}
+	if err := updated.Validate(); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// MergeReloaded takes the hot settings of reloaded on top of a copy of
+// config and returns the names of settings that changed but need a restart.
+func (config *Config) MergeReloaded(reloaded *Config) (*Config, []string) {
+	merged := config.Clone()
+	mergedSettings := merged.settings()
+	reloadedSettings := reloaded.settings()
+	ignored := []string{}
+	for i, setting := range mergedSettings {
+		value := reloadedSettings[i].get()
+		if setting.get() == value {
+			continue
+		}
+		if !setting.hot {
+			ignored = append(ignored, setting.name)
+			continue
+		}
+		setting.set(value)
+	}
+	return merged, ignored
+}
+
+// WriteFile stores config as indented JSON, replacing path atomically.
+func (config *Config) WriteFile(path string) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempFileName := path + ".tmp"
+	if err := os.WriteFile(tempFileName, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempFileName, path)
+}
+
+// Source remembers where a config came from so it can be loaded again on
+// reload. Path is empty when no config file was given.
+type Source struct {
+	Args      []string
+	LookupEnv func(string) (string, bool)
+	Path      string
+}
+
+// Reload resolves the configuration again from the same arguments,
+// environment and file.
+func (source *Source) Reload() (*Config, error) {
+	config, _, err := Load(source.Args, source.LookupEnv)
+	return config, err
+}
+
+// Load resolves the server configuration from args and the environment.
+// The config file is taken from -config or CACHE_CONFIG.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, *Source, error) {
+	config := Default()
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	configPath := flags.String("config", "", "path of a JSON config file")
+	settings := config.settings()
+	flagValues := map[string]*string{}
+	for _, setting := range settings {
+		flagName := strings.ReplaceAll(setting.name, "_", "-")
+		flagValues[setting.name] = flags.String(flagName, "", fmt.Sprintf("%s (env %s)", setting.usage, setting.env))
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	if *configPath == "" {
+		*configPath, _ = lookupEnv("CACHE_CONFIG")
+	}
+	if *configPath != "" {
+		if err := config.LoadFile(*configPath); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := config.ApplyEnv(lookupEnv); err != nil {
+		return nil, nil, err
+	}
+	explicit := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { explicit[strings.ReplaceAll(f.Name, "-", "_")] = true })
+	for _, setting := range config.settings() {
+		if !explicit[setting.name] {
+			continue
+		}
+		if err := setting.set(*flagValues[setting.name]); err != nil {
+			return nil, nil, fmt.Errorf("invalid value for -%s: %w", strings.ReplaceAll(setting.name, "_", "-"), err)
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return config, &Source{Args: args, LookupEnv: lookupEnv, Path: *configPath}, nil
+}
@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+func TestCloneCopiesLists(t *testing.T) {
+	running := Default()
+	running.ListenAddresses = []string{"localhost:1"}
+	running.DatabaseNames = []string{"users"}
+	clone := running.Clone()
+	clone.ListenAddresses[0] = "localhost:2"
+	clone.DatabaseNames[0] = "sessions"
+	if running.ListenAddresses[0] != "localhost:1" || running.DatabaseNames[0] != "users" {
+		t.Fatalf("changing the clone changed the running config: %v %v", running.ListenAddresses, running.DatabaseNames)
+	}
+}
+
+func TestWithChangesLeavesTheRunningConfig(t *testing.T) {
+	running := Default()
+	updated, err := running.WithChanges([]string{"max_connections", "7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.MaxConnections != 7 || running.MaxConnections == 7 {
+		t.Fatalf("expected only the copy to change, found %d and %d", updated.MaxConnections, running.MaxConnections)
+	}
+	if _, err := running.WithChanges([]string{"max_connections", "8", "no_such_setting", "1"}); err == nil {
+		t.Fatal("expected an unknown setting to fail every change")
+	}
+	if _, err := running.WithChanges([]string{"databases", "4"}); err == nil {
+		t.Fatal("expected a restart only setting to be rejected")
+	}
+}
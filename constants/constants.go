@@ -4,12 +4,23 @@ var SNAPSHOT_FILE_NAME string = "snapshot"
 
 var DEFAULT_SNAPSHOT_INTERVAL = 10
 
+var NIL_TYPE int64 = 0x00
 var STRING_TYPE int64 = 0x01
 var STRING_ARRAY_TYPE int64 = 0x02
 var INTEGER_TYPE int64 = 0x03
 var INTEGER_ARRAY_TYPE int64 = 0x04
 var FLOAT_TYPE int64 = 0x05
 var FLOAT_ARRAY_TYPE int64 = 0x06
+var LIST_TYPE int64 = 0x07
+var SET_TYPE int64 = 0x08
+var HASH_TYPE int64 = 0x09
+var SORTED_SET_TYPE int64 = 0x0A
+var VECTOR_INDEX_TYPE int64 = 0x0B
+var RANGE_INDEX_TYPE int64 = 0x0C
+var TEXT_INDEX_TYPE int64 = 0x0D
+var DATABASE_TYPE int64 = 0x0E
+var ERROR_TYPE int64 = 0x0F
+var VERSIONS_TYPE int64 = 0x10
 
 var FILE_HEADER string = "CerebralCache"
 
@@ -24,3 +35,27 @@ var BLOCK_SEPERATOR_LENGTH = 2
 var CURRENT_VERSION int64 = 1
 
 var RUN_SNAPSHOT_AFTER = 10
+
+var DEFAULT_LISTEN_ADDRESS = "localhost:4444"
+
+var DEFAULT_MAX_FRAME_SIZE = 64 * 1024 * 1024
+
+var DEFAULT_MAX_CONNECTIONS = 1024
+
+var DEFAULT_READ_TIMEOUT_SECONDS = 30
+
+var DEFAULT_WRITE_TIMEOUT_SECONDS = 30
+
+var DEFAULT_IDLE_TIMEOUT_SECONDS = 300
+
+var DEFAULT_SHUTDOWN_TIMEOUT_SECONDS = 10
+
+var DEFAULT_DATABASE = "0"
+
+var DEFAULT_DATABASE_COUNT = 16
+
+var DEFAULT_SCRIPT_MAX_STEPS = 10000000
+
+var DEFAULT_SCRIPT_TIMEOUT_MILLISECONDS = 5000
+
+var MAX_STRING_LENGTH = 512 * 1024 * 1024
@@ -3,15 +3,24 @@ package main
 import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"in-memory-store/config"
+	"math"
 	"os"
+	"runtime/debug"
 	"time"
 )
 
-func GetLogger() *zap.Logger {
+// logLevel is shared with the built logger so the level can change at runtime.
+var logLevel = zap.NewAtomicLevel()
+
+func GetLogger(level string) *zap.Logger {
 	config := zap.NewProductionConfig()
 	if os.Getenv("ENV") == "develoment" {
 		config = zap.NewDevelopmentConfig()
 	}
+	logLevel.SetLevel(config.Level.Level())
+	setLogLevel(level)
+	config.Level = logLevel
 	config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 	config.EncoderConfig.EncodeTime = zapcore.TimeEncoderOfLayout(time.RFC3339)
 	config.Encoding = "console"
@@ -19,3 +28,25 @@ func GetLogger() *zap.Logger {
 	zap.ReplaceGlobals(logger)
 	return logger
 }
+
+// applyMemoryLimit makes the garbage collector work harder as the heap
+// approaches the configured limit, writes above it are rejected by the server.
+func applyMemoryLimit(maxMemoryBytes int64) {
+	if maxMemoryBytes > 0 {
+		debug.SetMemoryLimit(maxMemoryBytes)
+	} else {
+		debug.SetMemoryLimit(math.MaxInt64)
+	}
+}
+
+func setLogLevel(level string) {
+	if parsedLevel, err := zap.ParseAtomicLevel(level); err == nil {
+		logLevel.SetLevel(parsedLevel.Level())
+	}
+}
+
+// applyRuntimeConfig applies the settings the server does not handle itself.
+func applyRuntimeConfig(serverConfig *config.Config) {
+	setLogLevel(serverConfig.LogLevel)
+	applyMemoryLimit(serverConfig.MaxMemoryBytes)
+}
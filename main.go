@@ -3,64 +3,73 @@ package main
 import (
 	"fmt"
 	"go.uber.org/zap"
-	"in-memory-store/constants"
+	"in-memory-store/bench"
+	"in-memory-store/cli"
+	"in-memory-store/config"
+	"in-memory-store/protocol"
 	"in-memory-store/schemas"
 	"in-memory-store/snapshots"
+	"os"
+	"strings"
 )
 
-func SetValue(m *schemas.MainMap, key string, value interface{}) {
-	switch v := value.(type) {
-	case int64:
-		m.SetInteger(key, v)
-	case string:
-		m.SetString(key, v)
-	case float64:
-		m.SetFloat(key, v)
-	case []int64:
-		m.SetIntegerArray(key, v)
-	case []string:
-		m.SetStringArray(key, v)
-	case []float64:
-		m.SetFloatArray(key, v)
-	default:
-		zap.L().Warn("Unsupported value type", zap.String("key", key), zap.Any("value", value))
-		return
+const usage = `usage: in-memory-store [command] [flags]
+
+commands:
+  server    run the cache server (default)
+  cli       connect to a server, run a command or start a REPL
+  bench     measure throughput and latency of a running server, or with
+            bench keyindex what the key index costs and with bench
+            snapshot how long snapshots take to save and read
+`
+
+func main() {
+	args := os.Args[1:]
+	command := "server"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
-	m.TotalNoOfOperations++
-	if (m.TotalNoOfOperations % constants.RUN_SNAPSHOT_AFTER) == 0 {
-		snapshots.RunSnapShotTaker(m)
+	switch command {
+	case "server":
+		os.Exit(runServer(args))
+	case "bench":
+		os.Exit(bench.Run(args))
+	case "cli":
+		os.Exit(cli.Run(args))
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
 	}
 }
-func main() {
-	logger := GetLogger()
+
+func runServer(args []string) int {
+	serverConfig, source, err := config.Load(args, os.LookupEnv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	logger := GetLogger(serverConfig.LogLevel)
 	defer logger.Sync()
-	globalMap := schemas.CreateMainMap()
-	defer snapshots.RunSnapShotTaker(globalMap)
 	logger.Info("Application Initilized")
-	snapshots.ReadSnapShotFile(globalMap)
-	fmt.Println(globalMap)
+	if source.Path != "" {
+		logger.Info("Loaded config file", zap.String("path", source.Path))
+	}
+	applyRuntimeConfig(serverConfig)
 
-	SetValue(globalMap, "thisisveryveryveryveryveryverylong", int64(458234092380598235))
-	SetValue(globalMap, "minusOne", int64(-1))
-	SetValue(globalMap, "plus2", int64(2))
-	SetValue(globalMap, "plus3", int64(3))
-	SetValue(globalMap, "plus10", int64(10))
-	SetValue(globalMap, "one hundred", int64(100))
-	SetValue(globalMap, "hehehehe", int64(69))
-	SetValue(globalMap, "firstString", "this is the value of the first string")
-	SetValue(globalMap, "New string", "new string")
-	arr := []int64{10, 20, 30, 40, 50}
-	SetValue(globalMap, "int arr", arr)
-	arr2 := []int64{80, 90, 500, 200, 80808080}
-	SetValue(globalMap, "int arr12", arr2)
-	floatArray := []float64{69.69, 20.22, 33.33}
-	SetValue(globalMap, "nirajanArray", floatArray)
-	SetValue(globalMap, "nirajanFloat", float64(69.69))
-	stringArray := []string{"hello", "how are you", "hehehhehehh"}
-	SetValue(globalMap, "str array", stringArray)
-	stringArray2 := []string{"brrr", "fff"}
-	SetValue(globalMap, "str212", stringArray2)
-	logger.Info("Application Closing....")
+	databases := schemas.NewDatabases(serverConfig.Databases, serverConfig.DatabaseNames)
+	snapshots.ReadSnapShotFile(databases, serverConfig.SnapshotPath)
+	if serverConfig.KeyIndex {
+		for _, name := range databases.Names() {
+			mainMap, _ := databases.Get(name)
+			mainMap.EnableKeyIndex()
+		}
+	}
 
+	server := protocol.NewServer(serverConfig, source, databases)
+	server.OnConfigChange(applyRuntimeConfig)
+	exitCode := serveUntilShutdown(server, databases)
 	logger.Info("Application Closing....")
+	return exitCode
 }
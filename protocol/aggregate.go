@@ -0,0 +1,103 @@
+package protocol
+
+import (
+	"in-memory-store/schemas"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "ASUM", minArgs: 1, maxArgs: 3, handler: statCommand(func(stats *schemas.ArrayStats) interface{} { return stats.Sum })},
+		&command{name: "AMIN", minArgs: 1, maxArgs: 3, handler: statCommand(func(stats *schemas.ArrayStats) interface{} { return stats.Min })},
+		&command{name: "AMAX", minArgs: 1, maxArgs: 3, handler: statCommand(func(stats *schemas.ArrayStats) interface{} { return stats.Max })},
+		&command{name: "AMEAN", minArgs: 1, maxArgs: 3, handler: statCommand(func(stats *schemas.ArrayStats) interface{} { return stats.Mean })},
+		&command{name: "AVAR", minArgs: 1, maxArgs: 3, handler: statCommand(func(stats *schemas.ArrayStats) interface{} { return stats.Variance })},
+		&command{name: "APERCENTILE", minArgs: 2, maxArgs: 4, handler: percentileCommand},
+	)
+}
+
+// rangeArgs reads the optional start and stop following args[from],
+// defaulting to the whole array.
+func rangeArgs(args []interface{}, from int) (int, int, error) {
+	switch len(args) - from {
+	case 0:
+		return 0, -1, nil
+	case 2:
+		start, err := integerArg(args, from)
+		if err != nil {
+			return 0, 0, err
+		}
+		stop, err := integerArg(args, from+1)
+		if err != nil {
+			return 0, 0, err
+		}
+		return int(start), int(stop), nil
+	}
+	return 0, 0, newProtocolError(ErrCodeInvalidArgument, "a range needs both start and stop")
+}
+
+// statCommand builds the handler of a command of the form NAME key
+// [start stop] replying with one field of the array's statistics.
+func statCommand(field func(stats *schemas.ArrayStats) interface{}) handlerFunc {
+	return func(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+		key, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		start, stop, err := rangeArgs(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		var stats *schemas.ArrayStats
+		server.read(session, func(mainMap *schemas.MainMap) {
+			stats, err = mainMap.ArrayStats(key, start, stop)
+		})
+		if err != nil {
+			return nil, invalidArgument(err)
+		}
+		return field(stats), nil
+	}
+}
+
+// percentileCommand implements APERCENTILE key percentile [start stop].
+// percentile is a number, replied with a float, or an array of them,
+// replied with a float array.
+func percentileCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var percentiles []float64
+	single := false
+	switch v := args[1].(type) {
+	case []float64:
+		percentiles = v
+	case []int64:
+		for _, percentile := range v {
+			percentiles = append(percentiles, float64(percentile))
+		}
+	default:
+		percentile, err := floatArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		percentiles, single = []float64{percentile}, true
+	}
+	start, stop, err := rangeArgs(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	var results []float64
+	server.read(session, func(mainMap *schemas.MainMap) {
+		results, err = mainMap.ArrayPercentiles(key, start, stop, percentiles)
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	if results == nil {
+		return nil, nil
+	}
+	if single {
+		return results[0], nil
+	}
+	return results, nil
+}
@@ -0,0 +1,227 @@
+package protocol
+
+import (
+	"go.uber.org/zap"
+	"in-memory-store/schemas"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "BLPOP", minArgs: 2, maxArgs: -1, handler: blpopCommand},
+		&command{name: "BRPOP", minArgs: 2, maxArgs: -1, handler: brpopCommand},
+	)
+}
+
+// popWaiter is a client parked in BLPOP or BRPOP on one or more keys of
+// one database.
+type popWaiter struct {
+	mainMap *schemas.MainMap
+	keys    []string
+	left    bool
+	ready   chan []interface{}
+}
+
+// waitKey is a key of one database.
+type waitKey struct {
+	mainMap *schemas.MainMap
+	key     string
+}
+
+// popWaiters queues parked clients per key in arrival order. The queues
+// of every database share one map, guarded by mu. Waiters are also only
+// added, removed and served under the write lock of the key's database,
+// so an element is either handed to the oldest waiter as soon as it is
+// pushed or stays in the array.
+type popWaiters struct {
+	mu     sync.Mutex
+	queues map[waitKey][]*popWaiter
+}
+
+func (waiters *popWaiters) add(waiter *popWaiter) {
+	waiters.mu.Lock()
+	defer waiters.mu.Unlock()
+	for _, key := range waiter.keys {
+		queued := waitKey{waiter.mainMap, key}
+		waiters.queues[queued] = append(waiters.queues[queued], waiter)
+	}
+}
+
+func (waiters *popWaiters) remove(waiter *popWaiter) {
+	waiters.mu.Lock()
+	defer waiters.mu.Unlock()
+	waiters.removeLocked(waiter)
+}
+
+// removeLocked is remove while mu is held.
+func (waiters *popWaiters) removeLocked(waiter *popWaiter) {
+	for _, key := range waiter.keys {
+		queued := waitKey{waiter.mainMap, key}
+		queue := waiters.queues[queued]
+		for i, other := range queue {
+			if other == waiter {
+				queue = append(queue[:i], queue[i+1:]...)
+				break
+			}
+		}
+		if len(queue) == 0 {
+			delete(waiters.queues, queued)
+		} else {
+			waiters.queues[queued] = queue
+		}
+	}
+}
+
+// serve pops elements of key for its waiters, oldest first, while both
+// remain. Each served waiter leaves every queue it was in.
+func (waiters *popWaiters) serve(mainMap *schemas.MainMap, key string) {
+	waiters.mu.Lock()
+	defer waiters.mu.Unlock()
+	queued := waitKey{mainMap, key}
+	for len(waiters.queues[queued]) > 0 {
+		length, err := mainMap.ListLength(key)
+		if err != nil || length == 0 {
+			return
+		}
+		waiter := waiters.queues[queued][0]
+		popped, _ := mainMap.ListPop(key, 1, waiter.left)
+		waiters.removeLocked(waiter)
+		waiter.ready <- []interface{}{key, firstElement(popped)}
+	}
+}
+
+func blpopCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	return blockingPop(server, session, args, true)
+}
+
+func brpopCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	return blockingPop(server, session, args, false)
+}
+
+// blockingPop implements BLPOP and BRPOP key [key ...] timeout. It pops
+// from the first non empty key, or parks the client until an element is
+// pushed to any of them or timeout seconds pass, 0 waiting forever. The
+// reply is [key, element], or nil on timeout. Inside EXEC it never waits.
+func blockingPop(server *Server, session *clientSession, args []interface{}, left bool) (interface{}, error) {
+	keys, err := keyArgs(args[:len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+	seconds, err := floatArg(args, len(args)-1)
+	if err != nil {
+		return nil, err
+	}
+	if seconds < 0 {
+		return nil, newProtocolError(ErrCodeInvalidArgument, "timeout must not be negative, found %v", seconds)
+	}
+	waiter := &popWaiter{mainMap: server.database(session), keys: keys, left: left, ready: make(chan []interface{}, 1)}
+	var reply []interface{}
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		for _, key := range keys {
+			length, err := mainMap.ListLength(key)
+			if err != nil {
+				return false, invalidArgument(err)
+			}
+			if length > 0 {
+				popped, _ := mainMap.ListPop(key, 1, left)
+				reply = []interface{}{key, firstElement(popped)}
+				return true, nil
+			}
+		}
+		if !session.locked {
+			server.waiters.add(waiter)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reply != nil {
+		return reply, nil
+	}
+	if session.locked {
+		// inside EXEC or a script nothing can push meanwhile, so it times out at once
+		return nil, nil
+	}
+	return server.waitForPop(session, waiter, time.Duration(seconds*float64(time.Second)))
+}
+
+// waitForPop parks the client until waiter is served, the timeout passes,
+// the server shuts down or the client disconnects. An element handed over
+// after the client disconnected is pushed back where it was popped.
+func (server *Server) waitForPop(session *clientSession, waiter *popWaiter, timeout time.Duration) (interface{}, error) {
+	server.metrics.BlockedClients.Add(1)
+	defer server.metrics.BlockedClients.Add(-1)
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	disconnected, stopWatching := server.watchDisconnect(session)
+	defer stopWatching()
+
+	gone := false
+	select {
+	case reply := <-waiter.ready:
+		return reply, nil
+	case <-expired:
+	case <-server.stop:
+	case <-disconnected:
+		gone = true
+	}
+	var reply []interface{}
+	server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		server.waiters.remove(waiter)
+		select {
+		case reply = <-waiter.ready:
+		default:
+		}
+		if reply == nil || !gone {
+			return false, nil
+		}
+		key := reply[0].(string)
+		if _, err := mainMap.ListPush(key, waiter.left, reply[1]); err != nil {
+			zap.L().Error("Could not return element of disconnected client", zap.String("key", key), zap.Error(err))
+			return false, nil
+		}
+		server.waiters.serve(mainMap, key)
+		return true, nil
+	})
+	if gone {
+		zap.L().Info("Client disconnected while blocked", zap.Strings("keys", waiter.keys))
+		return nil, nil
+	}
+	if reply != nil {
+		return reply, nil
+	}
+	return nil, nil
+}
+
+// watchDisconnect reports on the returned channel when the client closes
+// the connection while a command blocks. Frames the client pipelines in the
+// meantime stay buffered; once one arrives a disconnect is noticed only
+// when the connection is read again. stop must be called before reading
+// from the connection again.
+func (server *Server) watchDisconnect(session *clientSession) (<-chan struct{}, func()) {
+	disconnected := make(chan struct{})
+	done := make(chan struct{})
+	server.mu.Lock()
+	if !server.closing.Load() {
+		session.conn.SetReadDeadline(time.Time{})
+	}
+	server.mu.Unlock()
+	go func() {
+		defer close(done)
+		_, err := session.reader.Peek(1)
+		if err != nil && !isTimeout(err) {
+			close(disconnected)
+		}
+	}()
+	stop := func() {
+		session.conn.SetReadDeadline(time.Now())
+		<-done
+	}
+	return disconnected, stop
+}
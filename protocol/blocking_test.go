@@ -0,0 +1,182 @@
+package protocol
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// pipeSession returns a session on one end of an in-memory connection,
+// as blocking commands watch it for disconnects, and the client's end.
+func pipeSession(t *testing.T, database string) (*clientSession, net.Conn) {
+	serverEnd, clientEnd := net.Pipe()
+	t.Cleanup(func() {
+		serverEnd.Close()
+		clientEnd.Close()
+	})
+	return &clientSession{database: database, conn: serverEnd, reader: bufio.NewReader(serverEnd)}, clientEnd
+}
+
+type popResult struct {
+	reply interface{}
+	err   error
+}
+
+// blockIn runs a blocking command for a new session in the background and
+// returns once the session is parked, blocked counting the clients parked
+// before it. The reply arrives on the returned channel.
+func blockIn(t *testing.T, server *Server, database string, blocked int64, values ...interface{}) (<-chan popResult, net.Conn) {
+	t.Helper()
+	session, clientEnd := pipeSession(t, database)
+	results := make(chan popResult, 1)
+	go func() {
+		reply, err := do(server, session, values...)
+		results <- popResult{reply, err}
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for server.metrics.BlockedClients.Load() != blocked+1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%v did not block", values)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return results, clientEnd
+}
+
+func expectPop(t *testing.T, results <-chan popResult, expected ...interface{}) {
+	t.Helper()
+	select {
+	case result := <-results:
+		if result.err != nil || !reflect.DeepEqual(result.reply, []interface{}(expected)) {
+			t.Fatalf("expected %v, found %v, %v", expected, result.reply, result.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected %v, the client is still blocked", expected)
+	}
+}
+
+func expectNoWaiters(t *testing.T, server *Server) {
+	t.Helper()
+	server.waiters.mu.Lock()
+	defer server.waiters.mu.Unlock()
+	if len(server.waiters.queues) != 0 {
+		t.Fatalf("expected no parked clients, found queues %v", server.waiters.queues)
+	}
+}
+
+func TestBlockedClientsAreServedInArrivalOrder(t *testing.T) {
+	server := newTestServer(t, 1)
+	session := &clientSession{database: "0"}
+	first, _ := blockIn(t, server, "0", 0, "BLPOP", "a", "b", int64(0))
+	second, _ := blockIn(t, server, "0", 1, "BLPOP", "b", int64(0))
+	third, _ := blockIn(t, server, "0", 2, "BRPOP", "a", int64(0))
+	// one push serves as many clients as it brings elements, oldest first
+	mustDo(t, server, session, "RPUSH", "b", "x", "y")
+	expectPop(t, first, "b", "x")
+	expectPop(t, second, "b", "y")
+	// the first client no longer waits on a
+	mustDo(t, server, session, "RPUSH", "a", "z")
+	expectPop(t, third, "a", "z")
+	if value := mustDo(t, server, session, "LLEN", "a"); value != int64(0) {
+		t.Fatalf("expected every element to be handed out, a holds %v", value)
+	}
+	expectNoWaiters(t, server)
+}
+
+func TestMovesAndCopiesServeBlockedClients(t *testing.T) {
+	server := newTestServer(t, 2)
+	session := &clientSession{database: "0"}
+	moved, _ := blockIn(t, server, "1", 0, "BLPOP", "k", int64(0))
+	// the same key name in another database does not serve it
+	mustDo(t, server, session, "RPUSH", "k", "v")
+	select {
+	case result := <-moved:
+		t.Fatalf("a push to database 0 served a client of database 1: %v", result.reply)
+	case <-time.After(20 * time.Millisecond):
+	}
+	mustDo(t, server, session, "MOVE", "k", "1")
+	expectPop(t, moved, "k", "v")
+
+	copied, _ := blockIn(t, server, "1", 0, "BLPOP", "target", int64(0))
+	mustDo(t, server, session, "RPUSH", "source", "w")
+	mustDo(t, server, session, "COPY", "source", "target", "DB", "1")
+	expectPop(t, copied, "target", "w")
+	if value := mustDo(t, server, session, "LLEN", "source"); value != int64(1) {
+		t.Fatalf("expected the source of the copy to keep its element, found %v", value)
+	}
+	expectNoWaiters(t, server)
+}
+
+func TestDisconnectedClientsStopWaiting(t *testing.T) {
+	server := newTestServer(t, 1)
+	results, clientEnd := blockIn(t, server, "0", 0, "BLPOP", "a", "b", int64(0))
+	clientEnd.Close()
+	select {
+	case result := <-results:
+		if result.reply != nil || result.err != nil {
+			t.Fatalf("expected a nil reply for a disconnected client, found %v, %v", result.reply, result.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the disconnected client is still blocked")
+	}
+	expectNoWaiters(t, server)
+	if blocked := server.metrics.BlockedClients.Load(); blocked != 0 {
+		t.Fatalf("expected no blocked clients, found %d", blocked)
+	}
+	// a later push stays in the list instead of going to the gone client
+	session := &clientSession{database: "0"}
+	mustDo(t, server, session, "RPUSH", "a", "x")
+	if value := mustDo(t, server, session, "LLEN", "a"); value != int64(1) {
+		t.Fatalf("expected the element to stay in the list, found length %v", value)
+	}
+}
+
+func TestBlockingPopTimesOut(t *testing.T) {
+	server := newTestServer(t, 1)
+	session, _ := pipeSession(t, "0")
+	start := time.Now()
+	reply, err := do(server, session, "BLPOP", "a", 0.05)
+	if err != nil || reply != nil {
+		t.Fatalf("expected a nil reply on timeout, found %v, %v", reply, err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("expected to wait 50ms, returned after %s", elapsed)
+	}
+	expectNoWaiters(t, server)
+}
+
+func TestBlockingPopTakesWhatIsThere(t *testing.T) {
+	server := newTestServer(t, 1)
+	session := &clientSession{database: "0"}
+	mustDo(t, server, session, "RPUSH", "b", "x", "y")
+	// the first non empty key is popped from, at once
+	if reply := mustDo(t, server, session, "BLPOP", "a", "b", int64(0)); !reflect.DeepEqual(reply, []interface{}{"b", "x"}) {
+		t.Fatalf("expected [b x], found %v", reply)
+	}
+	if reply := mustDo(t, server, session, "BRPOP", "b", int64(0)); !reflect.DeepEqual(reply, []interface{}{"b", "y"}) {
+		t.Fatalf("expected [b y], found %v", reply)
+	}
+	// inside EXEC nothing can push, so an empty key times out at once
+	mustDo(t, server, session, "MULTI")
+	mustDo(t, server, session, "BLPOP", "a", int64(0))
+	if replies := mustDo(t, server, session, "EXEC"); !reflect.DeepEqual(replies, []interface{}{nil}) {
+		t.Fatalf("expected [<nil>], found %v", replies)
+	}
+	expectNoWaiters(t, server)
+}
+
+func TestBlockingPopArguments(t *testing.T) {
+	server := newTestServer(t, 1)
+	session := &clientSession{database: "0"}
+	mustDo(t, server, session, "SET", "text", "x")
+	for _, values := range [][]interface{}{
+		{"BLPOP", "a", int64(-1)},
+		{"BLPOP", "a", "soon"},
+		{"BLPOP", "text", int64(0)},
+	} {
+		_, err := do(server, session, values...)
+		expectCode(t, err, ErrCodeInvalidArgument)
+	}
+}
@@ -0,0 +1,138 @@
+package protocol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// ErrNotSent wraps errors of requests that did not reach the server, so
+// sending them again on a new connection can not run them twice.
+var ErrNotSent = errors.New("request not sent")
+
+// Client is a connection to a server after a successful handshake. It is
+// not safe for concurrent use.
+type Client struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	hello   *Hello
+	timeout time.Duration
+	// used is when the last reply arrived
+	used time.Time
+}
+
+// staleCheckAfter is how long a connection must be idle before a request
+// first checks whether the server closed it.
+const staleCheckAfter = 100 * time.Millisecond
+
+// Dial connects to address and performs the HELLO handshake offering every
+// supported version. timeout bounds the dial and each request, zero waits
+// forever.
+func Dial(address string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{conn: conn, reader: bufio.NewReader(conn), timeout: timeout}
+	client.armDeadline()
+	hello, err := ClientHandshake(client.reader, conn, SupportedVersions, ServerCapabilities)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	client.hello = hello
+	return client, nil
+}
+
+func (client *Client) Hello() *Hello {
+	return client.hello
+}
+
+func (client *Client) Close() error {
+	return client.conn.Close()
+}
+
+func (client *Client) armDeadline() {
+	if client.timeout > 0 {
+		client.conn.SetDeadline(time.Now().Add(client.timeout))
+	} else {
+		client.conn.SetDeadline(time.Time{})
+	}
+}
+
+// SetTimeout changes the per request timeout, zero waits forever.
+func (client *Client) SetTimeout(timeout time.Duration) {
+	client.timeout = timeout
+}
+
+// Do sends a command, name first, and waits for its reply. Errors reported
+// by the server are returned as *ProtocolError.
+func (client *Client) Do(name string, args ...interface{}) (interface{}, error) {
+	content, err := EncodeValues(append([]interface{}{name}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	return client.roundTrip(&Frame{Version: client.hello.Version, Action: Command, Content: content})
+}
+
+// roundTrip first checks that the server did not close an idle connection
+// and reports that, requests above the server's frame size and writes
+// failing before any byte went out as ErrNotSent.
+func (client *Client) roundTrip(request *Frame) (interface{}, error) {
+	if uint64(len(request.Content)) > client.hello.MaxFrameSize {
+		return nil, fmt.Errorf("%w: %w", ErrNotSent, &ProtocolError{
+			Code:    ErrCodeFrameTooLarge,
+			Message: fmt.Sprintf("request of %d bytes exceeds the server's limit of %d bytes", len(request.Content), client.hello.MaxFrameSize),
+		})
+	}
+	if time.Since(client.used) > staleCheckAfter {
+		// a deadline already passed fails without reading, so a closed
+		// connection would go unnoticed
+		client.conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+		if _, err := client.reader.Peek(1); err != nil && !isTimeout(err) {
+			return nil, fmt.Errorf("%w: %w", ErrNotSent, err)
+		}
+	}
+	client.armDeadline()
+	written := &countingWriter{writer: client.conn}
+	if err := writeFrame(written, request); err != nil {
+		if written.count == 0 {
+			return nil, fmt.Errorf("%w: %w", ErrNotSent, err)
+		}
+		return nil, err
+	}
+	frame, err := readFrame(client.reader, client.hello.MaxFrameSize)
+	if err != nil {
+		return nil, err
+	}
+	client.used = time.Now()
+	if frame.Action == Error {
+		return nil, DecodeErrorFrame(frame.Content)
+	}
+	if frame.Action != Reply {
+		return nil, fmt.Errorf("expected reply, found action %d", frame.Action)
+	}
+	values, err := DecodeValues(frame.Content)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 1 {
+		return nil, fmt.Errorf("expected a single reply value, found %d", len(values))
+	}
+	return values[0], nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	writer io.Writer
+	count  int
+}
+
+func (counter *countingWriter) Write(p []byte) (int, error) {
+	n, err := counter.writer.Write(p)
+	counter.count += n
+	return n, err
+}
@@ -0,0 +1,235 @@
+package protocol
+
+import (
+	"bufio"
+	"fmt"
+	"in-memory-store/config"
+	"in-memory-store/schemas"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// clientSession is the per connection state commands may read or change.
+type clientSession struct {
+	hello  *Hello
+	conn   net.Conn
+	reader *bufio.Reader
+	// database names the database commands run against
+	database string
+	// transaction holds the commands queued since MULTI, nil outside one
+	transaction *transaction
+	// watch is shared by the keys in watched, nil when none are watched
+	watch   *schemas.Watch
+	watched []waitKey
+	// locked is set while EXEC or a script holds every database lock, so
+	// the commands they run must not lock again; snapshotDue then defers a
+	// snapshot until the locks are released
+	locked      bool
+	snapshotDue bool
+}
+
+// command describes one named command and how many arguments may follow
+// its name. maxArgs is -1 when there is no upper bound. denyOOM commands
+// are rejected while the memory limit is exceeded. immediate commands run
+// at once even inside MULTI instead of being queued. noScript commands
+// can not be called from scripts, because they are not deterministic or
+// act on the connection or the server instead of the data.
+type command struct {
+	name      string
+	minArgs   int
+	maxArgs   int
+	denyOOM   bool
+	immediate bool
+	noScript  bool
+	handler   handlerFunc
+}
+
+type handlerFunc = func(server *Server, session *clientSession, args []interface{}) (interface{}, error)
+
+var commandTable = map[string]*command{}
+
+func registerCommands(commands ...*command) {
+	for _, command := range commands {
+		commandTable[command.name] = command
+	}
+}
+
+// CommandNames lists every command the server understands, sorted.
+func CommandNames() []string {
+	names := []string{}
+	for name := range commandTable {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	registerCommands(
+		&command{name: "PING", minArgs: 0, maxArgs: 1, handler: pingCommand},
+		&command{name: "COMMANDS", minArgs: 0, maxArgs: 0, handler: commandsCommand},
+		&command{name: "CONFIG", minArgs: 1, maxArgs: -1, noScript: true, handler: configCommand},
+		&command{name: "STATS", minArgs: 0, maxArgs: 0, noScript: true, handler: statsCommand},
+	)
+}
+
+// handleCommand executes a Command frame, whose content is the command
+// name followed by its arguments. Inside MULTI commands are checked and
+// queued instead; a command that fails the check makes EXEC fail too.
+func handleCommand(server *Server, session *clientSession, values []interface{}) (interface{}, error) {
+	command, args, err := lookupCommand(values)
+	if err != nil {
+		if session.transaction != nil {
+			session.transaction.failed = true
+		}
+		return nil, err
+	}
+	if session.transaction != nil && !command.immediate {
+		session.transaction.queued = append(session.transaction.queued, queuedCommand{command, args})
+		return "QUEUED", nil
+	}
+	return runCommand(server, session, command, args)
+}
+
+// lookupCommand finds the command named by values[0] and checks how many
+// arguments follow it.
+func lookupCommand(values []interface{}) (*command, []interface{}, error) {
+	if len(values) == 0 {
+		return nil, nil, newProtocolError(ErrCodeMalformedFrame, "missing command name")
+	}
+	name, ok := values[0].(string)
+	if !ok {
+		return nil, nil, newProtocolError(ErrCodeMalformedFrame, "command name must be a string, found %T", values[0])
+	}
+	command, ok := commandTable[strings.ToUpper(name)]
+	if !ok {
+		return nil, nil, newProtocolError(ErrCodeUnknownCommand, "unknown command %q", name)
+	}
+	args := values[1:]
+	if len(args) < command.minArgs || (command.maxArgs >= 0 && len(args) > command.maxArgs) {
+		return nil, nil, newProtocolError(ErrCodeInvalidArgument, "wrong number of arguments for %s", command.name)
+	}
+	return command, args, nil
+}
+
+func runCommand(server *Server, session *clientSession, command *command, args []interface{}) (interface{}, error) {
+	if command.denyOOM {
+		if err := server.checkMemory(); err != nil {
+			return nil, err
+		}
+	}
+	return command.handler(server, session, args)
+}
+
+// stringArg accepts strings and numbers, so typed literals from clients can
+// be used where a command expects text.
+func stringArg(args []interface{}, index int) (string, error) {
+	switch v := args[index].(type) {
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	}
+	return "", newProtocolError(ErrCodeInvalidArgument, "argument %d must be a string, found %T", index+1, args[index])
+}
+
+// integerArg accepts integers and text holding one.
+func integerArg(args []interface{}, index int) (int64, error) {
+	switch v := args[index].(type) {
+	case int64:
+		return v, nil
+	case string:
+		if parsed, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return parsed, nil
+		}
+	}
+	return 0, newProtocolError(ErrCodeInvalidArgument, "argument %d must be an integer, found %v", index+1, args[index])
+}
+
+// floatArg accepts floats, integers and text holding a number.
+func floatArg(args []interface{}, index int) (float64, error) {
+	switch v := args[index].(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case string:
+		if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+			return parsed, nil
+		}
+	}
+	return 0, newProtocolError(ErrCodeInvalidArgument, "argument %d must be a number, found %v", index+1, args[index])
+}
+
+func pingCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	return "PONG", nil
+}
+
+func commandsCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	return CommandNames(), nil
+}
+
+// statsCommand replies the connection counters as name value pairs.
+func statsCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	return server.metrics.Pairs(), nil
+}
+
+// configCommand implements CONFIG GET pattern, CONFIG SET name value
+// [name value ...], CONFIG REWRITE and CONFIG RELOAD.
+func configCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	subcommand, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToUpper(subcommand) {
+	case "GET":
+		if len(args) != 2 {
+			return nil, newProtocolError(ErrCodeInvalidArgument, "CONFIG GET expects a pattern")
+		}
+		pattern, err := stringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return server.Config().Get(pattern), nil
+	case "SET":
+		pairs := []string{}
+		for i := 1; i < len(args); i++ {
+			value, err := stringArg(args, i)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, value)
+		}
+		if len(pairs) == 0 || len(pairs)%2 != 0 {
+			return nil, newProtocolError(ErrCodeInvalidArgument, "CONFIG SET expects name value pairs")
+		}
+		err := server.updateConfig(func(running *config.Config) (*config.Config, error) {
+			return running.WithChanges(pairs)
+		})
+		if err != nil {
+			return nil, newProtocolError(ErrCodeInvalidArgument, "%s", err.Error())
+		}
+		return "OK", nil
+	case "REWRITE":
+		if server.source == nil || server.source.Path == "" {
+			return nil, newProtocolError(ErrCodeInvalidArgument, "the server was started without a config file")
+		}
+		if err := server.Config().WriteFile(server.source.Path); err != nil {
+			return nil, fmt.Errorf("failed rewriting config file: %w", err)
+		}
+		return "OK", nil
+	case "RELOAD":
+		if err := server.ReloadConfig(); err != nil {
+			return nil, newProtocolError(ErrCodeInvalidArgument, "%s", err.Error())
+		}
+		return "OK", nil
+	}
+	return nil, newProtocolError(ErrCodeInvalidArgument, "unknown CONFIG subcommand %q", subcommand)
+}
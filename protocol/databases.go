@@ -0,0 +1,184 @@
+package protocol
+
+import (
+	"in-memory-store/schemas"
+	"strings"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "SELECT", minArgs: 1, maxArgs: 1, handler: selectCommand},
+		&command{name: "DATABASES", minArgs: 0, maxArgs: 0, handler: databasesCommand},
+		&command{name: "DBSIZE", minArgs: 0, maxArgs: 0, handler: dbsizeCommand},
+		&command{name: "MOVE", minArgs: 2, maxArgs: 2, handler: moveCommand},
+		&command{name: "COPY", minArgs: 2, maxArgs: 5, denyOOM: true, handler: copyCommand},
+		&command{name: "FLUSHDB", minArgs: 0, maxArgs: 0, handler: flushdbCommand},
+		&command{name: "FLUSHALL", minArgs: 0, maxArgs: 0, handler: flushallCommand},
+	)
+}
+
+// databaseArg returns the name of the database in args[index] and the
+// database itself.
+func databaseArg(server *Server, args []interface{}, index int) (string, *schemas.MainMap, error) {
+	name, err := stringArg(args, index)
+	if err != nil {
+		return "", nil, err
+	}
+	mainMap, ok := server.databases.Get(name)
+	if !ok {
+		return "", nil, newProtocolError(ErrCodeInvalidArgument, "no such database %q", name)
+	}
+	return name, mainMap, nil
+}
+
+// writeBoth runs fn under the write locks of the database session selected
+// and the database called target, taken in lock order so clients moving
+// keys in opposite directions cannot deadlock. Both are the same map when
+// target is the selected database.
+func (server *Server) writeBoth(session *clientSession, target string, fn func(source *schemas.MainMap, destination *schemas.MainMap) (bool, error)) error {
+	source := server.database(session)
+	destination, _ := server.databases.Get(target)
+	if source == destination || session.locked {
+		return server.writeDatabase(session, destination, func(*schemas.MainMap) (bool, error) {
+			return fn(source, destination)
+		})
+	}
+	first, second := source, destination
+	if server.databases.Less(target, session.database) {
+		first, second = destination, source
+	}
+	runSnapshot, err := func() (bool, error) {
+		first.Lock()
+		defer first.Unlock()
+		second.Lock()
+		defer second.Unlock()
+		changed, err := fn(source, destination)
+		return changed && server.countWrite(destination), err
+	}()
+	if runSnapshot {
+		server.takeSnapshot()
+	}
+	return err
+}
+
+// selectCommand implements SELECT db, switching the database later
+// commands of the connection run against.
+func selectCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	name, _, err := databaseArg(server, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	session.database = name
+	return "OK", nil
+}
+
+// databasesCommand replies with one [name, keys] entry per database.
+func databasesCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	entries := []interface{}{}
+	for _, name := range server.databases.Names() {
+		mainMap, _ := server.databases.Get(name)
+		server.readDatabase(session, mainMap, func(mainMap *schemas.MainMap) {
+			entries = append(entries, []interface{}{name, int64(mainMap.KeyCount())})
+		})
+	}
+	return entries, nil
+}
+
+func dbsizeCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	var count int
+	server.read(session, func(mainMap *schemas.MainMap) {
+		count = mainMap.KeyCount()
+	})
+	return int64(count), nil
+}
+
+// moveCommand implements MOVE key db. It replies 1 when key was moved and
+// 0 when it is absent or db already has it.
+func moveCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	target, _, err := databaseArg(server, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if target == session.database {
+		return nil, newProtocolError(ErrCodeInvalidArgument, "source and destination database are the same")
+	}
+	moved := false
+	server.writeBoth(session, target, func(source *schemas.MainMap, destination *schemas.MainMap) (bool, error) {
+		if moved = source.CopyTo(key, destination, key, false); moved {
+			source.Delete(key)
+			server.waiters.serve(destination, key)
+		}
+		return moved, nil
+	})
+	if moved {
+		return int64(1), nil
+	}
+	return int64(0), nil
+}
+
+// copyCommand implements COPY source destination [DB db] [REPLACE]. It
+// replies 1 when the value was copied and 0 when source is absent or
+// destination exists without REPLACE.
+func copyCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	keys, err := keyArgs(args[:2])
+	if err != nil {
+		return nil, err
+	}
+	target, replace := session.database, false
+	for i := 2; i < len(args); i++ {
+		option, err := stringArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case strings.EqualFold(option, "REPLACE"):
+			replace = true
+		case strings.EqualFold(option, "DB") && i+1 < len(args):
+			if target, _, err = databaseArg(server, args, i+1); err != nil {
+				return nil, err
+			}
+			i++
+		default:
+			return nil, newProtocolError(ErrCodeInvalidArgument, "expected DB db or REPLACE, found %q", option)
+		}
+	}
+	if target == session.database && keys[0] == keys[1] {
+		return nil, newProtocolError(ErrCodeInvalidArgument, "source and destination are the same key")
+	}
+	copied := false
+	server.writeBoth(session, target, func(source *schemas.MainMap, destination *schemas.MainMap) (bool, error) {
+		if copied = source.CopyTo(keys[0], destination, keys[1], replace); copied {
+			server.waiters.serve(destination, keys[1])
+		}
+		return copied, nil
+	})
+	if copied {
+		return int64(1), nil
+	}
+	return int64(0), nil
+}
+
+// flushdbCommand implements FLUSHDB, removing every key of the selected
+// database.
+func flushdbCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		return mainMap.Flush() > 0, nil
+	})
+	return "OK", nil
+}
+
+// flushallCommand implements FLUSHALL, removing every key of every
+// database. Databases are emptied one after the other.
+func flushallCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	for _, name := range server.databases.Names() {
+		mainMap, _ := server.databases.Get(name)
+		server.writeDatabase(session, mainMap, func(mainMap *schemas.MainMap) (bool, error) {
+			return mainMap.Flush() > 0, nil
+		})
+	}
+	return "OK", nil
+}
@@ -0,0 +1,90 @@
+package protocol
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Frame is a single message on the wire. Every frame is laid out as
+// version (3 bytes) | content length (8 bytes) | content | action (8 bytes).
+type Frame struct {
+	Version []uint8
+	Action  uint64
+	Content []byte
+}
+
+// readFrame reads one frame. A content length above maxFrameSize is
+// rejected with ErrCodeFrameTooLarge before anything is allocated.
+func readFrame(reader *bufio.Reader, maxFrameSize uint64) (*Frame, error) {
+	versionBuffer, err := readExactBytes(reader, len(Version))
+	if err != nil {
+		return nil, err
+	}
+	contentLengthBuffer, err := readExactBytes(reader, 8)
+	if err != nil {
+		return nil, err
+	}
+	contentLength := binary.BigEndian.Uint64(contentLengthBuffer)
+	if contentLength > maxFrameSize {
+		return nil, &ProtocolError{
+			Code:    ErrCodeFrameTooLarge,
+			Message: fmt.Sprintf("frame content length %d exceeds limit of %d bytes", contentLength, maxFrameSize),
+		}
+	}
+	content, err := readExactBytes(reader, int(contentLength))
+	if err != nil {
+		return nil, err
+	}
+	actionBuffer, err := readExactBytes(reader, 8)
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{
+		Version: convertBytesToUnit8(versionBuffer),
+		Action:  binary.BigEndian.Uint64(actionBuffer),
+		Content: content,
+	}, nil
+}
+
+func writeFrame(writer io.Writer, frame *Frame) error {
+	if len(frame.Version) != len(Version) {
+		return fmt.Errorf("invalid frame version length %d", len(frame.Version))
+	}
+	buffer := make([]byte, 0, len(frame.Version)+8+len(frame.Content)+8)
+	buffer = append(buffer, frame.Version...)
+	buffer = binary.BigEndian.AppendUint64(buffer, uint64(len(frame.Content)))
+	buffer = append(buffer, frame.Content...)
+	buffer = binary.BigEndian.AppendUint64(buffer, frame.Action)
+	_, err := writer.Write(buffer)
+	return err
+}
+
+func writeErrorFrame(writer io.Writer, version []uint8, code uint16, message string) error {
+	content := binary.BigEndian.AppendUint16(nil, code)
+	content = append(content, []byte(message)...)
+	return writeFrame(writer, &Frame{Version: version, Action: Error, Content: content})
+}
+
+// ProtocolError is the decoded content of an error frame.
+type ProtocolError struct {
+	Code    uint16
+	Message string
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("protocol error %d: %s", e.Code, e.Message)
+}
+
+// DecodeErrorFrame reads the content of an error frame:
+// code (2 bytes) | message.
+func DecodeErrorFrame(content []byte) *ProtocolError {
+	if len(content) < 2 {
+		return &ProtocolError{Code: ErrCodeMalformedFrame, Message: "truncated error frame"}
+	}
+	return &ProtocolError{
+		Code:    binary.BigEndian.Uint16(content[:2]),
+		Message: string(content[2:]),
+	}
+}
@@ -0,0 +1,191 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// frameBytes lays out a frame without checking any of its fields.
+func frameBytes(version []uint8, contentLength uint64, content []byte, action uint64) []byte {
+	buffer := append([]byte{}, version...)
+	buffer = binary.BigEndian.AppendUint64(buffer, contentLength)
+	buffer = append(buffer, content...)
+	return binary.BigEndian.AppendUint64(buffer, action)
+}
+
+func commandFrame(values ...interface{}) []byte {
+	content, err := EncodeValues(values...)
+	if err != nil {
+		panic(err)
+	}
+	return frameBytes(Version, uint64(len(content)), content, Command)
+}
+
+func FuzzReadFrame(f *testing.F) {
+	f.Add(commandFrame("PING"))
+	f.Add(frameBytes(Version, 1<<40, nil, Command))
+	f.Add(frameBytes(Version, 100, []byte("short"), Command))
+	f.Add(frameBytes(HandshakeVersion, 0, nil, HelloAction))
+	f.Add([]byte{0, 1})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		frame, err := readFrame(bufio.NewReader(bytes.NewReader(data)), 1024)
+		if err != nil {
+			return
+		}
+		var written bytes.Buffer
+		if err := writeFrame(&written, frame); err != nil {
+			t.Fatalf("frame read but not written: %v", err)
+		}
+		if !bytes.Equal(written.Bytes(), data[:written.Len()]) {
+			t.Fatalf("frame written as %x, read from %x", written.Bytes(), data)
+		}
+	})
+}
+
+func FuzzDecodeValues(f *testing.F) {
+	seeds := [][]interface{}{
+		{"SET", "key", int64(42)},
+		{[]int64{1, 2}, []float64{1.5}, []string{"a", ""}, nil},
+		{[]interface{}{"nested", []interface{}{int64(1), &ProtocolError{Code: ErrCodeInternal, Message: "failed"}}}},
+	}
+	for _, seed := range seeds {
+		content, err := EncodeValues(seed...)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(content)
+	}
+	f.Add([]byte{7, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		values, err := DecodeValues(data)
+		if err != nil {
+			return
+		}
+		content, err := EncodeValues(values...)
+		if err != nil {
+			t.Fatalf("values decoded but not encoded: %v", err)
+		}
+		// encodings are compared, as NaN differs from itself
+		again, err := DecodeValues(content)
+		if err != nil {
+			t.Fatalf("values %#v encoded but not decoded again: %v", values, err)
+		}
+		if encoded, _ := EncodeValues(again...); !bytes.Equal(encoded, content) {
+			t.Fatalf("values %#v decoded again as %#v", values, again)
+		}
+	})
+}
+
+// connect serves one end of a pipe and returns the other, after the
+// handshake unless handshake is false.
+func connect(t *testing.T, handshake bool) (net.Conn, *bufio.Reader) {
+	server := newTestServer(t, 1)
+	serverConfig := server.Config().Clone()
+	serverConfig.ReadTimeoutSeconds = 1
+	serverConfig.MaxFrameSize = 1024
+	server.config.Store(serverConfig)
+	serverEnd, client := net.Pipe()
+	go func() {
+		defer serverEnd.Close()
+		server.acceptConnection(serverEnd)
+	}()
+	t.Cleanup(func() { client.Close() })
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(client)
+	if handshake {
+		if _, err := ClientHandshake(reader, client, [][]uint8{Version}, 0); err != nil {
+			t.Fatalf("handshake failed: %v", err)
+		}
+	}
+	return client, reader
+}
+
+// expectError reads the next frame and fails unless it is an error frame
+// with code.
+func expectError(t *testing.T, reader *bufio.Reader, code uint16) {
+	t.Helper()
+	frame, err := readFrame(reader, 1<<20)
+	if err != nil {
+		t.Fatalf("expected an error frame with code %d, reading failed: %v", code, err)
+	}
+	if frame.Action != Error {
+		t.Fatalf("expected an error frame with code %d, found action %d", code, frame.Action)
+	}
+	if found := DecodeErrorFrame(frame.Content); found.Code != code {
+		t.Fatalf("expected error code %d, found %v", code, found)
+	}
+}
+
+func TestMalformedFramesCloseTheConnection(t *testing.T) {
+	tests := []struct {
+		name      string
+		handshake bool
+		frame     []byte
+		code      uint16
+	}{
+		{"not hello", false, commandFrame("PING"), ErrCodeHandshakeRequired},
+		{"truncated", true, frameBytes(Version, 100, []byte("short"), Command)[:len(Version)+8+5], ErrCodeTimeout},
+		{"oversized", true, frameBytes(Version, 1<<40, nil, Command)[:len(Version)+8], ErrCodeFrameTooLarge},
+		{"bad version", true, frameBytes([]uint8{9, 9, 9}, 0, nil, Command), ErrCodeVersionMismatch},
+		{"unknown action", true, frameBytes(Version, 0, nil, 99), ErrCodeUnknownAction},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, reader := connect(t, test.handshake)
+			// the server may close before reading everything, so the write
+			// must not hold up the test
+			go client.Write(test.frame)
+			expectError(t, reader, test.code)
+			if _, err := reader.ReadByte(); !errors.Is(err, io.EOF) {
+				t.Fatalf("expected the connection to be closed, reading gave %v", err)
+			}
+		})
+	}
+}
+
+func TestMalformedValuesKeepTheConnection(t *testing.T) {
+	client, reader := connect(t, true)
+	go client.Write(frameBytes(Version, 3, []byte{7, 0, 1}, Command))
+	expectError(t, reader, ErrCodeMalformedFrame)
+	go client.Write(commandFrame("PING"))
+	frame, err := readFrame(reader, 1<<20)
+	if err != nil || frame.Action != Reply {
+		t.Fatalf("expected a reply to PING, found %v, %v", frame, err)
+	}
+}
+
+func TestHelloAdvertisesTheMaxFrameSize(t *testing.T) {
+	conn, reader := connect(t, false)
+	hello, err := ClientHandshake(reader, conn, [][]uint8{Version}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hello.MaxFrameSize != 1024 {
+		t.Fatalf("expected the server to advertise 1024 bytes, found %d", hello.MaxFrameSize)
+	}
+	client := &Client{conn: conn, reader: reader, hello: hello, timeout: 5 * time.Second}
+	// a request above the limit is not sent and keeps the connection
+	if _, err := client.Do("SET", "k", string(make([]byte, 1024))); !errors.Is(err, ErrNotSent) {
+		t.Fatalf("expected an oversized request not to be sent, found %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := client.Do("RPUSH", "l", string(make([]byte, 500))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// neither is a reply above it sent
+	_, err = client.Do("LRANGE", "l", int64(0), int64(-1))
+	var protocolError *ProtocolError
+	if !errors.As(err, &protocolError) || protocolError.Code != ErrCodeFrameTooLarge {
+		t.Fatalf("expected an oversized reply to be refused, found %v", err)
+	}
+	if reply, err := client.Do("LLEN", "l"); err != nil || reply != int64(3) {
+		t.Fatalf("expected the connection to stay usable, found %v, %v", reply, err)
+	}
+}
@@ -0,0 +1,73 @@
+package protocol
+
+import (
+	"fmt"
+)
+
+func newProtocolError(code uint16, format string, args ...interface{}) *ProtocolError {
+	return &ProtocolError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func decodeKey(values []interface{}) (string, error) {
+	if len(values) == 0 {
+		return "", newProtocolError(ErrCodeMalformedFrame, "missing key")
+	}
+	key, ok := values[0].(string)
+	if !ok {
+		return "", newProtocolError(ErrCodeMalformedFrame, "key must be a string, found %T", values[0])
+	}
+	return key, nil
+}
+
+func handleCreate(server *Server, session *clientSession, values []interface{}) (interface{}, error) {
+	if _, err := decodeKey(values); err != nil {
+		return nil, err
+	}
+	if len(values) != 2 {
+		return nil, newProtocolError(ErrCodeMalformedFrame, "create expects a key and a value, found %d values", len(values))
+	}
+	if err := server.checkMemory(); err != nil {
+		return nil, err
+	}
+	return setCommand(server, session, values)
+}
+
+func handleDelete(server *Server, session *clientSession, values []interface{}) (interface{}, error) {
+	if _, err := decodeKey(values); err != nil {
+		return nil, err
+	}
+	if len(values) != 1 {
+		return nil, newProtocolError(ErrCodeMalformedFrame, "delete expects only a key, found %d values", len(values))
+	}
+	return delCommand(server, session, values)
+}
+
+// handleFrame executes a request frame. Errors of type *ProtocolError are
+// sent back to the client and the connection stays open, the frame having
+// been fully consumed.
+func handleFrame(server *Server, session *clientSession, frame *Frame) (*Frame, error) {
+	var handler handlerFunc
+	switch frame.Action {
+	case Create:
+		handler = handleCreate
+	case Delete:
+		handler = handleDelete
+	case Command:
+		handler = handleCommand
+	default:
+		return nil, newProtocolError(ErrCodeUnknownAction, "unknown action %d", frame.Action)
+	}
+	values, err := DecodeValues(frame.Content)
+	if err != nil {
+		return nil, newProtocolError(ErrCodeMalformedFrame, "%s", err.Error())
+	}
+	result, err := handler(server, session, values)
+	if err != nil {
+		return nil, err
+	}
+	content, err := EncodeValues(result)
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{Version: frame.Version, Action: Reply, Content: content}, nil
+}
@@ -0,0 +1,189 @@
+package protocol
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Capability flags exchanged during the HELLO handshake.
+const (
+	CapCompression uint32 = 1 << 0
+	CapAuth        uint32 = 1 << 1
+	CapPipelining  uint32 = 1 << 2
+	CapPush        uint32 = 1 << 3
+)
+
+// SupportedVersions lists every protocol version the server speaks,
+// ordered from oldest to newest.
+var SupportedVersions = [][]uint8{Version}
+
+// ServerCapabilities are the capabilities the server is willing to enable.
+var ServerCapabilities = CapPipelining
+
+// HandshakeVersion is the version written on HELLO frames, which are sent
+// before a protocol version has been agreed on.
+var HandshakeVersion = []uint8{0, 0, 0}
+
+// maxHelloFrameSize bounds the server's answer to HELLO, which is read
+// before the server advertised its own limit. It leaves room for the
+// message of an error frame.
+const maxHelloFrameSize = 64 * 1024
+
+// Hello is what both sides know about the connection once the handshake is done.
+type Hello struct {
+	Version      []uint8
+	Capabilities uint32
+	// MaxFrameSize is the largest frame content the server accepts and
+	// sends on the connection, as advertised in its HELLO.
+	MaxFrameSize uint64
+}
+
+// EncodeHelloRequest builds the content of a client HELLO frame:
+// version count (1 byte) | versions (3 bytes each) | capabilities (4 bytes).
+func EncodeHelloRequest(versions [][]uint8, capabilities uint32) []byte {
+	content := []byte{uint8(len(versions))}
+	for _, version := range versions {
+		content = append(content, version...)
+	}
+	return binary.BigEndian.AppendUint32(content, capabilities)
+}
+
+func decodeHelloRequest(content []byte) ([][]uint8, uint32, error) {
+	if len(content) < 1 {
+		return nil, 0, fmt.Errorf("empty hello request")
+	}
+	count := int(content[0])
+	expected := 1 + count*len(Version) + 4
+	if len(content) != expected {
+		return nil, 0, fmt.Errorf("hello request expected %d bytes found %d", expected, len(content))
+	}
+	versions := [][]uint8{}
+	for i := 0; i < count; i++ {
+		start := 1 + i*len(Version)
+		versions = append(versions, convertBytesToUnit8(content[start:start+len(Version)]))
+	}
+	capabilities := binary.BigEndian.Uint32(content[expected-4:])
+	return versions, capabilities, nil
+}
+
+// DecodeHelloResponse reads the content of a server HELLO frame:
+// agreed version (3 bytes) | agreed capabilities (4 bytes) | max frame
+// size (8 bytes).
+func DecodeHelloResponse(content []byte) (*Hello, error) {
+	if len(content) != len(Version)+4+8 {
+		return nil, fmt.Errorf("hello response expected %d bytes found %d", len(Version)+4+8, len(content))
+	}
+	return &Hello{
+		Version:      convertBytesToUnit8(content[:len(Version)]),
+		Capabilities: binary.BigEndian.Uint32(content[len(Version):]),
+		MaxFrameSize: binary.BigEndian.Uint64(content[len(Version)+4:]),
+	}, nil
+}
+
+func versionLess(a []uint8, b []uint8) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+func containsVersion(versions [][]uint8, version []uint8) bool {
+	for _, v := range versions {
+		if compareVersion(v, version) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateVersion returns the highest version present in both lists, or nil.
+func negotiateVersion(serverVersions [][]uint8, clientVersions [][]uint8) []uint8 {
+	var best []uint8
+	for _, version := range clientVersions {
+		if !containsVersion(serverVersions, version) {
+			continue
+		}
+		if best == nil || versionLess(best, version) {
+			best = version
+		}
+	}
+	return best
+}
+
+func versionsToString(versions [][]uint8) string {
+	versionString := ""
+	for i, version := range versions {
+		if i > 0 {
+			versionString += ", "
+		}
+		versionString += convertVersionToString(version)
+	}
+	return versionString
+}
+
+// serverHandshake expects a HELLO frame from the client and answers with
+// the agreed version and capabilities and maxFrameSize, which then holds
+// for the rest of the connection. When there is nothing in common an
+// error frame is sent and an error is returned so the caller closes the
+// connection.
+func serverHandshake(reader *bufio.Reader, writer io.Writer, maxFrameSize uint64) (*Hello, error) {
+	frame, err := readFrame(reader, maxFrameSize)
+	if err != nil {
+		if !isConnectionClosed(err) {
+			sendError(writer, HandshakeVersion, err)
+		}
+		return nil, err
+	}
+	if frame.Action != HelloAction {
+		writeErrorFrame(writer, HandshakeVersion, ErrCodeHandshakeRequired, "expected HELLO as the first frame")
+		return nil, fmt.Errorf("expected hello frame, found action %d", frame.Action)
+	}
+	clientVersions, clientCapabilities, err := decodeHelloRequest(frame.Content)
+	if err != nil {
+		writeErrorFrame(writer, HandshakeVersion, ErrCodeMalformedFrame, err.Error())
+		return nil, err
+	}
+	version := negotiateVersion(SupportedVersions, clientVersions)
+	if version == nil {
+		message := fmt.Sprintf("no compatible protocol version, server supports %s, client supports %s",
+			versionsToString(SupportedVersions), versionsToString(clientVersions))
+		writeErrorFrame(writer, HandshakeVersion, ErrCodeVersionMismatch, message)
+		return nil, fmt.Errorf("%s", message)
+	}
+	hello := &Hello{
+		Version:      version,
+		Capabilities: clientCapabilities & ServerCapabilities,
+		MaxFrameSize: maxFrameSize,
+	}
+	content := append([]byte{}, hello.Version...)
+	content = binary.BigEndian.AppendUint32(content, hello.Capabilities)
+	content = binary.BigEndian.AppendUint64(content, hello.MaxFrameSize)
+	if err := writeFrame(writer, &Frame{Version: HandshakeVersion, Action: HelloAction, Content: content}); err != nil {
+		return nil, err
+	}
+	return hello, nil
+}
+
+// ClientHandshake sends a HELLO frame offering versions and capabilities and
+// waits for the server's answer.
+func ClientHandshake(reader *bufio.Reader, writer io.Writer, versions [][]uint8, capabilities uint32) (*Hello, error) {
+	request := &Frame{Version: HandshakeVersion, Action: HelloAction, Content: EncodeHelloRequest(versions, capabilities)}
+	if err := writeFrame(writer, request); err != nil {
+		return nil, err
+	}
+	frame, err := readFrame(reader, maxHelloFrameSize)
+	if err != nil {
+		return nil, err
+	}
+	if frame.Action == Error {
+		return nil, DecodeErrorFrame(frame.Content)
+	}
+	if frame.Action != HelloAction {
+		return nil, fmt.Errorf("expected hello response, found action %d", frame.Action)
+	}
+	return DecodeHelloResponse(frame.Content)
+}
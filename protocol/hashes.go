@@ -0,0 +1,216 @@
+package protocol
+
+import (
+	"in-memory-store/schemas"
+	"strings"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "HSET", minArgs: 3, maxArgs: -1, denyOOM: true, handler: hsetCommand},
+		&command{name: "HGET", minArgs: 2, maxArgs: 2, handler: hgetCommand},
+		&command{name: "HMGET", minArgs: 2, maxArgs: -1, handler: hmgetCommand},
+		&command{name: "HDEL", minArgs: 2, maxArgs: -1, handler: hdelCommand},
+		&command{name: "HGETALL", minArgs: 1, maxArgs: 1, handler: hgetallCommand},
+		&command{name: "HINCRBY", minArgs: 3, maxArgs: 3, denyOOM: true, handler: hincrbyCommand},
+		&command{name: "HLEN", minArgs: 1, maxArgs: 1, handler: hlenCommand},
+		&command{name: "HSCAN", minArgs: 2, maxArgs: 6, handler: hscanCommand},
+	)
+}
+
+const defaultScanCount = 10
+
+// scanOptions holds the optional MATCH, COUNT and, for SCAN, TYPE
+// arguments of the scan commands.
+type scanOptions struct {
+	match    string
+	count    int
+	typeName string
+}
+
+func parseScanOptions(args []interface{}, allowType bool) (*scanOptions, error) {
+	options := &scanOptions{count: defaultScanCount}
+	for i := 0; i < len(args); i += 2 {
+		name, err := stringArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		if i+1 >= len(args) {
+			return nil, newProtocolError(ErrCodeInvalidArgument, "%s needs a value", name)
+		}
+		switch strings.ToUpper(name) {
+		case "MATCH":
+			if options.match, err = stringArg(args, i+1); err != nil {
+				return nil, err
+			}
+		case "COUNT":
+			count, err := integerArg(args, i+1)
+			if err != nil {
+				return nil, err
+			}
+			if count <= 0 {
+				return nil, newProtocolError(ErrCodeInvalidArgument, "COUNT must be positive, found %d", count)
+			}
+			options.count = int(count)
+		case "TYPE":
+			if !allowType {
+				return nil, newProtocolError(ErrCodeInvalidArgument, "unknown scan option %q", name)
+			}
+			if options.typeName, err = stringArg(args, i+1); err != nil {
+				return nil, err
+			}
+			options.typeName = strings.ToLower(options.typeName)
+		default:
+			return nil, newProtocolError(ErrCodeInvalidArgument, "unknown scan option %q", name)
+		}
+	}
+	return options, nil
+}
+
+// hsetCommand implements HSET key field value [field value ...] and
+// replies with the number of new fields.
+func hsetCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(args)%2 != 1 {
+		return nil, newProtocolError(ErrCodeInvalidArgument, "HSET needs a value for every field")
+	}
+	fields := map[string]interface{}{}
+	for i := 1; i < len(args); i += 2 {
+		field, err := stringArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		fields[field] = args[i+1]
+	}
+	var added int
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		added, err = mainMap.HashSet(key, fields)
+		return err == nil, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return int64(added), nil
+}
+
+func hgetCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	keys, err := keyArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	server.read(session, func(mainMap *schemas.MainMap) {
+		value, err = mainMap.HashGet(keys[0], keys[1])
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	return value, nil
+}
+
+func hmgetCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	keys, err := keyArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	server.read(session, func(mainMap *schemas.MainMap) {
+		values, err = mainMap.HashGetMany(keys[0], keys[1:]...)
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	return values, nil
+}
+
+func hdelCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	keys, err := keyArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	var removed int
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		removed, err = mainMap.HashDelete(keys[0], keys[1:]...)
+		return removed > 0, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return int64(removed), nil
+}
+
+func hgetallCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var pairs []interface{}
+	server.read(session, func(mainMap *schemas.MainMap) {
+		pairs, err = mainMap.HashGetAll(key)
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	return pairs, nil
+}
+
+func hincrbyCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	keys, err := keyArgs(args[:2])
+	if err != nil {
+		return nil, err
+	}
+	delta, err := integerArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	var result int64
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		result, err = mainMap.HashIncrement(keys[0], keys[1], delta)
+		return err == nil, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func hlenCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var length int
+	server.read(session, func(mainMap *schemas.MainMap) {
+		length, err = mainMap.HashLength(key)
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	return int64(length), nil
+}
+
+// hscanCommand implements HSCAN key cursor [MATCH pattern] [COUNT count]
+// and replies with the next cursor followed by field, value pairs. A scan
+// starts and ends with cursor "0".
+func hscanCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	keys, err := keyArgs(args[:2])
+	if err != nil {
+		return nil, err
+	}
+	options, err := parseScanOptions(args[2:], false)
+	if err != nil {
+		return nil, err
+	}
+	var pairs []interface{}
+	var next string
+	server.read(session, func(mainMap *schemas.MainMap) {
+		pairs, next, err = mainMap.HashScan(keys[0], keys[1], options.match, options.count)
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	return []interface{}{next, pairs}, nil
+}
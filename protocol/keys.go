@@ -0,0 +1,157 @@
+package protocol
+
+import (
+	"in-memory-store/schemas"
+	"strings"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "GET", minArgs: 1, maxArgs: 1, handler: getCommand},
+		&command{name: "SET", minArgs: 2, maxArgs: 3, denyOOM: true, handler: setCommand},
+		&command{name: "DEL", minArgs: 1, maxArgs: -1, handler: delCommand},
+		&command{name: "EXISTS", minArgs: 1, maxArgs: -1, handler: existsCommand},
+		&command{name: "TYPE", minArgs: 1, maxArgs: 1, handler: typeCommand},
+		&command{name: "SCAN", minArgs: 1, maxArgs: 7, handler: scanCommand},
+	)
+}
+
+func keyArgs(args []interface{}) ([]string, error) {
+	keys := []string{}
+	for i := range args {
+		key, err := stringArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func getCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	server.read(session, func(mainMap *schemas.MainMap) {
+		value = mainMap.GetValue(key)
+	})
+	return value, nil
+}
+
+// setCommand implements SET key value [NX|XX]. With NX the key is only
+// set when absent and with XX only when present; the reply is then nil
+// when nothing was stored.
+func setCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	set := (*schemas.MainMap).SetIfAbsent
+	condition := ""
+	if len(args) == 3 {
+		if condition, err = stringArg(args, 2); err != nil {
+			return nil, err
+		}
+		switch strings.ToUpper(condition) {
+		case "NX":
+		case "XX":
+			set = (*schemas.MainMap).SetIfPresent
+		default:
+			return nil, newProtocolError(ErrCodeInvalidArgument, "expected NX or XX, found %q", condition)
+		}
+	}
+	stored := false
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		if condition == "" {
+			err = mainMap.SetValue(key, args[1])
+			stored = err == nil
+		} else {
+			stored, err = set(mainMap, key, args[1])
+		}
+		if err != nil {
+			return false, invalidArgument(err)
+		}
+		if stored {
+			server.waiters.serve(mainMap, key)
+		}
+		return stored, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !stored {
+		return nil, nil
+	}
+	return "OK", nil
+}
+
+func delCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	keys, err := keyArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	deleted := int64(0)
+	server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		for _, key := range keys {
+			if mainMap.Delete(key) {
+				deleted++
+			}
+		}
+		return deleted > 0, nil
+	})
+	return deleted, nil
+}
+
+func existsCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	keys, err := keyArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	found := int64(0)
+	server.read(session, func(mainMap *schemas.MainMap) {
+		for _, key := range keys {
+			if mainMap.GetValue(key) != nil {
+				found++
+			}
+		}
+	})
+	return found, nil
+}
+
+func typeCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var typeName string
+	server.read(session, func(mainMap *schemas.MainMap) {
+		typeName = mainMap.TypeOf(key)
+	})
+	return typeName, nil
+}
+
+// scanCommand implements SCAN cursor [MATCH pattern] [COUNT count] [TYPE
+// type] and replies with the next cursor followed by the matching keys. A
+// scan starts and ends with cursor "0"; COUNT is how many keys are
+// examined per call, so a page may hold fewer keys or none.
+func scanCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	cursor, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	options, err := parseScanOptions(args[1:], true)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	var next string
+	server.read(session, func(mainMap *schemas.MainMap) {
+		keys, next, err = mainMap.Scan(cursor, options.match, options.typeName, options.count)
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	return []interface{}{next, keys}, nil
+}
@@ -0,0 +1,266 @@
+package protocol
+
+import (
+	"in-memory-store/schemas"
+	"strings"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "LPUSH", minArgs: 2, maxArgs: -1, denyOOM: true, handler: lpushCommand},
+		&command{name: "RPUSH", minArgs: 2, maxArgs: -1, denyOOM: true, handler: rpushCommand},
+		&command{name: "LPOP", minArgs: 1, maxArgs: 2, handler: lpopCommand},
+		&command{name: "RPOP", minArgs: 1, maxArgs: 2, handler: rpopCommand},
+		&command{name: "LLEN", minArgs: 1, maxArgs: 1, handler: llenCommand},
+		&command{name: "LINDEX", minArgs: 2, maxArgs: 2, handler: lindexCommand},
+		&command{name: "LSET", minArgs: 3, maxArgs: 3, denyOOM: true, handler: lsetCommand},
+		&command{name: "LRANGE", minArgs: 3, maxArgs: 3, handler: lrangeCommand},
+		&command{name: "LINSERT", minArgs: 4, maxArgs: 4, denyOOM: true, handler: linsertCommand},
+		&command{name: "LREM", minArgs: 3, maxArgs: 3, handler: lremCommand},
+		&command{name: "LTRIM", minArgs: 3, maxArgs: 3, handler: ltrimCommand},
+	)
+}
+
+// invalidArgument reports an error of the map operations to the client.
+func invalidArgument(err error) error {
+	if err == nil {
+		return nil
+	}
+	return newProtocolError(ErrCodeInvalidArgument, "%s", err.Error())
+}
+
+// firstElement returns the first element of a popped slice, nil when it is
+// empty.
+func firstElement(values interface{}) interface{} {
+	switch v := values.(type) {
+	case []int64:
+		if len(v) > 0 {
+			return v[0]
+		}
+	case []float64:
+		if len(v) > 0 {
+			return v[0]
+		}
+	case []string:
+		if len(v) > 0 {
+			return v[0]
+		}
+	}
+	return nil
+}
+
+func pushCommand(server *Server, session *clientSession, args []interface{}, left bool) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var length int
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		length, err = mainMap.ListPush(key, left, args[1:]...)
+		if err != nil {
+			return false, invalidArgument(err)
+		}
+		server.waiters.serve(mainMap, key)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return int64(length), nil
+}
+
+func lpushCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	return pushCommand(server, session, args, true)
+}
+
+func rpushCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	return pushCommand(server, session, args, false)
+}
+
+// popCommand pops a single element, or an array of up to count elements
+// when a count is given.
+func popCommand(server *Server, session *clientSession, args []interface{}, left bool) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	count := int64(1)
+	if len(args) == 2 {
+		if count, err = integerArg(args, 1); err != nil {
+			return nil, err
+		}
+	}
+	var popped interface{}
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		popped, err = mainMap.ListPop(key, int(count), left)
+		return popped != nil && count > 0, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		return firstElement(popped), nil
+	}
+	return popped, nil
+}
+
+func lpopCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	return popCommand(server, session, args, true)
+}
+
+func rpopCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	return popCommand(server, session, args, false)
+}
+
+func llenCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var length int
+	server.read(session, func(mainMap *schemas.MainMap) {
+		length, err = mainMap.ListLength(key)
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	return int64(length), nil
+}
+
+func lindexCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	index, err := integerArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	server.read(session, func(mainMap *schemas.MainMap) {
+		value, err = mainMap.ListIndex(key, int(index))
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	return value, nil
+}
+
+func lsetCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	index, err := integerArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		err := mainMap.ListSet(key, int(index), args[2])
+		return err == nil, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return "OK", nil
+}
+
+func lrangeCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	start, err := integerArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	stop, err := integerArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	var values interface{}
+	server.read(session, func(mainMap *schemas.MainMap) {
+		values, err = mainMap.ListRange(key, int(start), int(stop))
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	return values, nil
+}
+
+// linsertCommand implements LINSERT key BEFORE|AFTER pivot value.
+func linsertCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	where, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var before bool
+	switch strings.ToUpper(where) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+	default:
+		return nil, newProtocolError(ErrCodeInvalidArgument, "expected BEFORE or AFTER, found %q", where)
+	}
+	var length int
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		length, err = mainMap.ListInsert(key, args[2], args[3], before)
+		if err != nil {
+			return false, invalidArgument(err)
+		}
+		server.waiters.serve(mainMap, key)
+		return length > 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return int64(length), nil
+}
+
+// lremCommand implements LREM key count value.
+func lremCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	count, err := integerArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var removed int
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		removed, err = mainMap.ListRemove(key, int(count), args[2])
+		return removed > 0, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return int64(removed), nil
+}
+
+func ltrimCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	start, err := integerArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	stop, err := integerArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		err := mainMap.ListTrim(key, int(start), int(stop))
+		return err == nil, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return "OK", nil
+}
@@ -2,20 +2,44 @@ package protocol
 
 import (
 	"bufio"
-	"encoding/binary"
+	"errors"
 	"fmt"
+	"in-memory-store/constants"
 	"io"
 	"net"
+	"syscall"
+	"time"
 
 	"go.uber.org/zap"
 )
 
 const (
-	Create uint64 = 0
-	Delete uint64 = 1
+	Create      uint64 = 0
+	Delete      uint64 = 1
+	HelloAction uint64 = 2
+	Error       uint64 = 3
+	Reply       uint64 = 4
+	Command     uint64 = 5
 )
 
-var Version = []uint8{0, 0, 0}
+// Error codes carried in error frames.
+const (
+	ErrCodeVersionMismatch   uint16 = 1
+	ErrCodeHandshakeRequired uint16 = 2
+	ErrCodeMalformedFrame    uint16 = 3
+	ErrCodeFrameTooLarge     uint16 = 4
+	ErrCodeUnknownAction     uint16 = 5
+	ErrCodeInvalidArgument   uint16 = 6
+	ErrCodeInternal          uint16 = 7
+	ErrCodeTimeout           uint16 = 8
+	ErrCodeTooManyClients    uint16 = 9
+	ErrCodeOutOfMemory       uint16 = 10
+	ErrCodeUnknownCommand    uint16 = 11
+	ErrCodeScript            uint16 = 12
+	ErrCodeNoScript          uint16 = 13
+)
+
+var Version = []uint8{0, 1, 0}
 
 func convertBytesToUnit8(bytes []byte) []uint8 {
 	uint8Array := []uint8{}
@@ -38,11 +62,11 @@ func convertVersionToString(version []uint8) string {
 
 }
 
-func compareVersion(incomingVersion []uint8) bool {
-	if len(Version) != len(incomingVersion) {
+func compareVersion(version []uint8, incomingVersion []uint8) bool {
+	if len(version) != len(incomingVersion) {
 		return false
 	}
-	for index, val := range Version {
+	for index, val := range version {
 		if val != incomingVersion[index] {
 			return false
 		}
@@ -61,65 +85,117 @@ func readExactBytes(reader *bufio.Reader, n int) ([]byte, error) {
 
 }
 
-func acceptConnection(client net.Conn) {
+// isConnectionClosed tells a client going away apart from a protocol error.
+func isConnectionClosed(err error) bool {
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// acceptConnection serves a client until it disconnects or breaks framing.
+// Errors inside a fully read frame are answered with an error frame and the
+// connection continues at the next frame. Errors in the frame header leave
+// the stream position unknown, so an error frame is sent and the connection
+// is closed. A frame of an unknown action closes it too, as the client
+// does not speak this protocol.
+func (server *Server) acceptConnection(client net.Conn) {
+	defer func() {
+		if r := recover(); r != nil {
+			zap.L().Error("Recovered from panic while serving client",
+				zap.String("Remote address", client.RemoteAddr().String()),
+				zap.Any("panic", r),
+			)
+		}
+	}()
 	reader := bufio.NewReader(client)
+	writer := &deadlineWriter{conn: client, timeout: func() time.Duration { return server.Config().WriteTimeout() }}
+	if readTimeout := server.Config().ReadTimeout(); readTimeout > 0 {
+		client.SetReadDeadline(time.Now().Add(readTimeout))
+	}
+	hello, err := serverHandshake(reader, writer, server.Config().MaxFrameSize)
+	if err != nil {
+		server.metrics.HandshakeFailures.Add(1)
+		if isConnectionClosed(err) {
+			zap.L().Info("Client disconnected during handshake")
+		} else {
+			zap.L().Error("Handshake with client failed", zap.Error(err))
+		}
+		return
+	}
+	session := &clientSession{hello: hello, conn: client, reader: reader, database: constants.DEFAULT_DATABASE}
+	defer server.unwatch(session)
+	zap.L().Info("Client connected",
+		zap.String("Protocol version", convertVersionToString(hello.Version)),
+		zap.Uint32("Capabilities", hello.Capabilities),
+	)
 	for {
-		versionBuffer, err := readExactBytes(reader, 3)
+		if !server.waitForFrame(client, reader) {
+			return
+		}
+		// the limit advertised in HELLO holds for the whole connection
+		frame, err := readFrame(reader, hello.MaxFrameSize)
 		if err != nil {
-			zap.L().Error("Failed reading version from client", zap.Error(err))
-			continue
+			if isConnectionClosed(err) {
+				zap.L().Info("Client disconnected")
+				return
+			}
+			if isTimeout(err) {
+				server.metrics.ReadTimeouts.Add(1)
+				err = &ProtocolError{Code: ErrCodeTimeout, Message: "timed out reading frame"}
+			}
+			if errorCodeOf(err) == ErrCodeFrameTooLarge {
+				server.metrics.OversizedFrames.Add(1)
+			}
+			zap.L().Error("Failed reading frame from client", zap.Error(err))
+			sendError(writer, hello.Version, err)
+			return
 		}
-
-		versionUint8 := convertBytesToUnit8(versionBuffer)
-		if !compareVersion(versionUint8) {
-			zap.L().Error("Client and server version mismatch",
-				zap.String("Server version", convertVersionToString(Version)),
-				zap.String("Client version", convertVersionToString(versionUint8)),
+		if !compareVersion(hello.Version, frame.Version) {
+			zap.L().Error("Frame version does not match negotiated version",
+				zap.String("Negotiated version", convertVersionToString(hello.Version)),
+				zap.String("Frame version", convertVersionToString(frame.Version)),
 			)
-			continue
+			writeErrorFrame(writer, hello.Version, ErrCodeVersionMismatch, "frame version does not match negotiated version")
+			return
 		}
-
-		contentLengthBuffer, err := readExactBytes(reader, 8)
+		reply, err := handleFrame(server, session, frame)
 		if err != nil {
-			zap.L().Error("Failed reading content length", zap.Error(err))
-			continue
+			zap.L().Warn("Failed handling frame", zap.Uint64("Action type", frame.Action), zap.Error(err))
+			if errorCodeOf(err) == ErrCodeUnknownAction {
+				sendError(writer, hello.Version, err)
+				return
+			}
+			err = sendError(writer, hello.Version, err)
+		} else if uint64(len(reply.Content)) > hello.MaxFrameSize {
+			// the client reads no more than the advertised limit either
+			err = writeErrorFrame(writer, hello.Version, ErrCodeFrameTooLarge,
+				fmt.Sprintf("reply of %d bytes exceeds limit of %d bytes", len(reply.Content), hello.MaxFrameSize))
+		} else {
+			err = writeFrame(writer, reply)
 		}
-		contentLength := binary.BigEndian.Uint64(contentLengthBuffer)
-
-		content, err := readExactBytes(reader, int(contentLength))
 		if err != nil {
-			zap.L().Error("Failed reading content",
-				zap.Uint64("Expected content length", contentLength),
-				zap.Error(err),
-			)
+			zap.L().Error("Failed writing reply to client", zap.Error(err))
 			return
 		}
-		actionType, err := readExactBytes(reader, 8)
-		if err != nil {
-			zap.L().Error("Failed reading action type",
-				zap.Uint8("Expected action type length", 8),
-				zap.Error(err),
-			)
-			continue
-		}
 	}
 }
 
-func startServer() {
-	server, err := net.Listen("tcp4", "localhost:4444")
-	if err != nil {
-		zap.L().Error("failed starting server", zap.Error(err))
-		return
+// sendError answers a failed frame with an error frame carrying the code of
+// a *ProtocolError, or ErrCodeInternal for anything else.
+func sendError(writer io.Writer, version []uint8, err error) error {
+	var protocolError *ProtocolError
+	if errors.As(err, &protocolError) {
+		return writeErrorFrame(writer, version, protocolError.Code, protocolError.Message)
 	}
-	defer server.Close()
-	for {
-		client, err := server.Accept()
-		if err != nil {
-			zap.L().Error("failed accepting connection from client", zap.Error(err))
-		}
-		go func() {
-			acceptConnection(client)
-			defer client.Close()
-		}()
+	return writeErrorFrame(writer, version, ErrCodeInternal, err.Error())
+}
+
+func errorCodeOf(err error) uint16 {
+	var protocolError *ProtocolError
+	if errors.As(err, &protocolError) {
+		return protocolError.Code
 	}
+	return ErrCodeInternal
 }
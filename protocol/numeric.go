@@ -0,0 +1,62 @@
+package protocol
+
+import (
+	"in-memory-store/schemas"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "INCRBY", minArgs: 2, maxArgs: 2, denyOOM: true, handler: incrByCommand},
+		&command{name: "DECRBY", minArgs: 2, maxArgs: 2, denyOOM: true, handler: decrByCommand},
+		&command{name: "INCRBYFLOAT", minArgs: 2, maxArgs: 2, denyOOM: true, handler: incrByFloatCommand},
+	)
+}
+
+// incrementInteger runs INCRBY or DECRBY depending on the operation given.
+func incrementInteger(server *Server, session *clientSession, args []interface{}, operation func(mainMap *schemas.MainMap, key string, delta int64) (int64, error)) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	delta, err := integerArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var result int64
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		result, err = operation(mainMap, key, delta)
+		return err == nil, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func incrByCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	return incrementInteger(server, session, args, (*schemas.MainMap).IncrementInteger)
+}
+
+func decrByCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	return incrementInteger(server, session, args, (*schemas.MainMap).DecrementInteger)
+}
+
+func incrByFloatCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	delta, err := floatArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var result float64
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		result, err = mainMap.IncrementFloat(key, delta)
+		return err == nil, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,142 @@
+package protocol
+
+import (
+	"in-memory-store/schemas"
+	"in-memory-store/snapshots"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// database returns the database session selected.
+func (server *Server) database(session *clientSession) *schemas.MainMap {
+	mainMap, _ := server.databases.Get(session.database)
+	return mainMap
+}
+
+// countWrite records a write to mainMap while its lock is held and reports
+// whether a snapshot is due. Writes to every database count towards
+// SnapshotEveryOperations. The snapshot itself must run after the lock is
+// released.
+func (server *Server) countWrite(mainMap *schemas.MainMap) bool {
+	mainMap.TotalNoOfOperations++
+	writes := server.writes.Add(1)
+	every := server.Config().SnapshotEveryOperations
+	return every > 0 && writes%int64(every) == 0
+}
+
+// write runs fn under the write lock of the database session selected.
+// When fn reports a change the write is counted, and a due snapshot is
+// taken once the lock is released.
+func (server *Server) write(session *clientSession, fn func(mainMap *schemas.MainMap) (bool, error)) error {
+	return server.writeDatabase(session, server.database(session), fn)
+}
+
+// writeDatabase is write for a given database. Inside EXEC or a script
+// the lock is already held. The lock is released even when fn panics, so
+// a failing command does not leave the database locked.
+func (server *Server) writeDatabase(session *clientSession, mainMap *schemas.MainMap, fn func(mainMap *schemas.MainMap) (bool, error)) error {
+	if session.locked {
+		changed, err := fn(mainMap)
+		if changed && server.countWrite(mainMap) {
+			session.snapshotDue = true
+		}
+		return err
+	}
+	runSnapshot, err := func() (bool, error) {
+		mainMap.Lock()
+		defer mainMap.Unlock()
+		changed, err := fn(mainMap)
+		return changed && server.countWrite(mainMap), err
+	}()
+	if runSnapshot {
+		server.takeSnapshot()
+	}
+	return err
+}
+
+// read runs fn under the read lock of the database session selected.
+func (server *Server) read(session *clientSession, fn func(mainMap *schemas.MainMap)) {
+	server.readDatabase(session, server.database(session), fn)
+}
+
+// readDatabase is read for a given database.
+func (server *Server) readDatabase(session *clientSession, mainMap *schemas.MainMap, fn func(mainMap *schemas.MainMap)) {
+	if !session.locked {
+		mainMap.RLock()
+		defer mainMap.RUnlock()
+	}
+	fn(mainMap)
+}
+
+func (server *Server) takeSnapshot() {
+	snapshots.RunSnapShotTaker(server.databases, server.Config().SnapshotPath)
+}
+
+// runSnapshotSchedule takes a snapshot every SnapshotInterval as long as
+// keys changed since the previous one, until stop is closed. The interval
+// is read again after every tick so reloads take effect.
+func (server *Server) runSnapshotSchedule(stop <-chan struct{}) {
+	lastWrites := server.writes.Load()
+	for {
+		interval := server.Config().SnapshotInterval()
+		enabled := interval > 0
+		if !enabled {
+			interval = time.Second
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+		if !enabled {
+			continue
+		}
+		writes := server.writes.Load()
+		if writes == lastWrites {
+			continue
+		}
+		lastWrites = writes
+		server.takeSnapshot()
+	}
+}
+
+// heapSampler reads the live heap size at most once per sampleEvery so
+// checking the memory limit on every write stays cheap.
+type heapSampler struct {
+	mu          sync.Mutex
+	sampledAt   time.Time
+	heapBytes   uint64
+	sampleEvery time.Duration
+}
+
+func (sampler *heapSampler) heapSize() uint64 {
+	sampler.mu.Lock()
+	defer sampler.mu.Unlock()
+	if time.Since(sampler.sampledAt) < sampler.sampleEvery {
+		return sampler.heapBytes
+	}
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() == metrics.KindUint64 {
+		sampler.heapBytes = sample[0].Value.Uint64()
+	}
+	sampler.sampledAt = time.Now()
+	return sampler.heapBytes
+}
+
+// checkMemory rejects writes while the heap is above MaxMemoryBytes.
+func (server *Server) checkMemory() error {
+	limit := server.Config().MaxMemoryBytes
+	if limit <= 0 {
+		return nil
+	}
+	heapSize := server.heap.heapSize()
+	if heapSize > uint64(limit) {
+		zap.L().Warn("Rejecting write, memory limit reached", zap.Uint64("heap bytes", heapSize), zap.Int64("limit", limit))
+		return newProtocolError(ErrCodeOutOfMemory, "memory limit of %d bytes reached", limit)
+	}
+	return nil
+}
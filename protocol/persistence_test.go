@@ -0,0 +1,80 @@
+package protocol
+
+import (
+	"in-memory-store/config"
+	"in-memory-store/schemas"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestServer creates a server of databases numbered databases that
+// snapshots into a temporary directory.
+func newTestServer(t *testing.T, databases int) *Server {
+	serverConfig := config.Default()
+	serverConfig.SnapshotPath = filepath.Join(t.TempDir(), "snapshot")
+	return NewServer(serverConfig, nil, schemas.NewDatabases(databases, nil))
+}
+
+// do runs one command for session as if it had arrived in a frame.
+func do(server *Server, session *clientSession, values ...interface{}) (interface{}, error) {
+	return handleCommand(server, session, values)
+}
+
+// panicking runs fn and swallows its panic, as acceptConnection does.
+func panicking(fn func()) {
+	defer func() { recover() }()
+	fn()
+}
+
+func TestPanickingWritesReleaseTheLocks(t *testing.T) {
+	server := newTestServer(t, 2)
+	session := &clientSession{database: "0"}
+	panicking(func() {
+		server.write(session, func(mainMap *schemas.MainMap) (bool, error) { panic("failed") })
+	})
+	panicking(func() {
+		server.writeBoth(session, "1", func(source *schemas.MainMap, destination *schemas.MainMap) (bool, error) {
+			panic("failed")
+		})
+	})
+	for _, name := range []string{"0", "1"} {
+		mainMap, _ := server.databases.Get(name)
+		if !mainMap.TryLock() {
+			t.Fatalf("database %s is still locked after a command panicked", name)
+		}
+		mainMap.Unlock()
+	}
+}
+
+func TestConcurrentConfigUpdatesAreNotLost(t *testing.T) {
+	server := newTestServer(t, 1)
+	entered, release := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		server.updateConfig(func(running *config.Config) (*config.Config, error) {
+			close(entered)
+			<-release
+			updated := running.Clone()
+			updated.MaxConnections = 7
+			return updated, nil
+		})
+	}()
+	<-entered
+	go func() {
+		defer wg.Done()
+		if _, err := do(server, &clientSession{database: "0"}, "CONFIG", "SET", "script_max_steps", "5"); err != nil {
+			t.Error(err)
+		}
+	}()
+	// give CONFIG SET the chance to run while the first update is pending
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if running := server.Config(); running.MaxConnections != 7 || running.ScriptMaxSteps != 5 {
+		t.Fatalf("expected both updates to apply, found max_connections %d and script_max_steps %d", running.MaxConnections, running.ScriptMaxSteps)
+	}
+}
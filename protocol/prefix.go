@@ -0,0 +1,81 @@
+package protocol
+
+import (
+	"in-memory-store/schemas"
+	"strings"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "PREFIXKEYS", minArgs: 1, maxArgs: 5, handler: prefixkeysCommand},
+		&command{name: "PREFIXCOUNT", minArgs: 1, maxArgs: 1, handler: prefixcountCommand},
+		&command{name: "PREFIXDEL", minArgs: 1, maxArgs: 1, handler: prefixdelCommand},
+	)
+}
+
+// prefixkeysCommand implements PREFIXKEYS prefix [AFTER key] [LIMIT count]
+// and replies with the keys starting with prefix in ascending order. Pass
+// the last key of a page as AFTER to get the next one.
+func prefixkeysCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	prefix, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	after, fromStart, limit := "", true, -1
+	for i := 1; i < len(args); i += 2 {
+		name, err := stringArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		if i+1 >= len(args) {
+			return nil, newProtocolError(ErrCodeInvalidArgument, "%s needs a value", name)
+		}
+		switch strings.ToUpper(name) {
+		case "AFTER":
+			if after, err = stringArg(args, i+1); err != nil {
+				return nil, err
+			}
+			fromStart = false
+		case "LIMIT":
+			count, err := integerArg(args, i+1)
+			if err != nil {
+				return nil, err
+			}
+			limit = int(count)
+		default:
+			return nil, newProtocolError(ErrCodeInvalidArgument, "unexpected argument %q", name)
+		}
+	}
+	var keys []string
+	server.read(session, func(mainMap *schemas.MainMap) {
+		keys = mainMap.KeysWithPrefix(prefix, after, fromStart, limit)
+	})
+	return keys, nil
+}
+
+func prefixcountCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	prefix, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var count int
+	server.read(session, func(mainMap *schemas.MainMap) {
+		count = mainMap.CountPrefix(prefix)
+	})
+	return int64(count), nil
+}
+
+// prefixdelCommand implements PREFIXDEL prefix and replies with the number
+// of keys deleted, all of them under one lock.
+func prefixdelCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	prefix, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var deleted int
+	server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		deleted = mainMap.DeletePrefix(prefix)
+		return deleted > 0, nil
+	})
+	return int64(deleted), nil
+}
@@ -0,0 +1,95 @@
+package protocol
+
+import (
+	"in-memory-store/schemas"
+	"strings"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "IDXCREATE", minArgs: 3, maxArgs: 3, denyOOM: true, handler: idxcreateCommand},
+		&command{name: "IDXDROP", minArgs: 1, maxArgs: 1, handler: idxdropCommand},
+		&command{name: "IDXLIST", minArgs: 0, maxArgs: 0, handler: idxlistCommand},
+		&command{name: "IDXRANGE", minArgs: 3, maxArgs: 7, handler: idxrangeCommand},
+	)
+}
+
+// idxcreateCommand implements IDXCREATE name prefix INTEGER|FLOAT.
+func idxcreateCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	names, err := keyArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		err := mainMap.CreateRangeIndex(names[0], names[1], names[2])
+		return err == nil, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return "OK", nil
+}
+
+// idxdropCommand implements IDXDROP name and replies 1 when the index
+// existed.
+func idxdropCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	name, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var dropped bool
+	server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		dropped = mainMap.DropRangeIndex(name)
+		return dropped, nil
+	})
+	if dropped {
+		return int64(1), nil
+	}
+	return int64(0), nil
+}
+
+// idxlistCommand replies with one [name, prefix, type, size] entry per
+// index.
+func idxlistCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	entries := []interface{}{}
+	server.read(session, func(mainMap *schemas.MainMap) {
+		for _, index := range mainMap.RangeIndexes() {
+			entries = append(entries, []interface{}{index.Name, index.Prefix, index.Type, int64(index.Size())})
+		}
+	})
+	return entries, nil
+}
+
+// idxrangeCommand implements IDXRANGE name min max [REV] [LIMIT offset
+// count] and replies with key, value pairs ordered by value. Bounds are
+// values, ( before a value excludes it, and -inf and +inf leave an end
+// open; min comes first even with REV.
+func idxrangeCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	bounds, err := keyArgs(args[:3])
+	if err != nil {
+		return nil, err
+	}
+	reverse := false
+	rest := args[3:]
+	if len(rest) > 0 {
+		if option, err := stringArg(rest, 0); err == nil && strings.EqualFold(option, "REV") {
+			reverse, rest = true, rest[1:]
+		}
+	}
+	options, err := parseRangeOptions(rest, false, true)
+	if err != nil {
+		return nil, err
+	}
+	var found []schemas.IndexedValue
+	server.read(session, func(mainMap *schemas.MainMap) {
+		found, err = mainMap.RangeQuery(bounds[0], bounds[1], bounds[2], reverse, options.offset, options.count)
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	pairs := make([]interface{}, 0, 2*len(found))
+	for _, entry := range found {
+		pairs = append(pairs, entry.Key, entry.Value)
+	}
+	return pairs, nil
+}
@@ -0,0 +1,189 @@
+package protocol
+
+import (
+	"errors"
+	"in-memory-store/scripting"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "EVAL", minArgs: 2, maxArgs: -1, noScript: true, handler: evalCommand},
+		&command{name: "EVALSHA", minArgs: 2, maxArgs: -1, noScript: true, handler: evalshaCommand},
+		&command{name: "SCRIPT", minArgs: 1, maxArgs: -1, noScript: true, handler: scriptCommand},
+	)
+}
+
+// scriptCache holds compiled scripts by hash until SCRIPT FLUSH.
+type scriptCache struct {
+	mu      sync.Mutex
+	scripts map[string]*scripting.Script
+}
+
+// load compiles source unless a script with its hash is cached already.
+func (cache *scriptCache) load(source string) (*scripting.Script, error) {
+	hash := scripting.Hash(source)
+	cache.mu.Lock()
+	script, ok := cache.scripts[hash]
+	cache.mu.Unlock()
+	if ok {
+		return script, nil
+	}
+	script, err := scripting.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	cache.mu.Lock()
+	cache.scripts[hash] = script
+	cache.mu.Unlock()
+	return script, nil
+}
+
+func (cache *scriptCache) get(hash string) *scripting.Script {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return cache.scripts[strings.ToLower(hash)]
+}
+
+func (cache *scriptCache) flush() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.scripts = make(map[string]*scripting.Script)
+}
+
+// evalCommand implements EVAL script numkeys [key ...] [arg ...]. The
+// script is cached for EVALSHA too.
+func evalCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	source, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	script, err := server.scripts.load(source)
+	if err != nil {
+		return nil, scriptError(err)
+	}
+	return server.runScript(session, script, args[1:])
+}
+
+// evalshaCommand implements EVALSHA hash numkeys [key ...] [arg ...] for a
+// script loaded before.
+func evalshaCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	hash, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	script := server.scripts.get(hash)
+	if script == nil {
+		return nil, newProtocolError(ErrCodeNoScript, "no script with hash %s, use EVAL or SCRIPT LOAD", hash)
+	}
+	return server.runScript(session, script, args[1:])
+}
+
+// runScript runs script with every database write locked, so no other
+// client and no snapshot sees the database between two commands it calls.
+// args is numkeys followed by the keys and the arguments. A SELECT inside
+// the script only lasts until it ends.
+func (server *Server) runScript(session *clientSession, script *scripting.Script, args []interface{}) (interface{}, error) {
+	count, err := integerArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 || count > int64(len(args)-1) {
+		return nil, newProtocolError(ErrCodeInvalidArgument, "numkeys must be between 0 and %d, found %d", len(args)-1, count)
+	}
+	keys, err := keyArgs(args[1 : 1+count])
+	if err != nil {
+		return nil, err
+	}
+	call := func(name string, callArgs []interface{}) (interface{}, error) {
+		command, callArgs, err := lookupCommand(append([]interface{}{name}, callArgs...))
+		if err != nil {
+			return nil, err
+		}
+		if command.noScript {
+			return nil, newProtocolError(ErrCodeScript, "%s can not be called from a script", command.name)
+		}
+		reply, err := runCommand(server, session, command, callArgs)
+		if err != nil {
+			return nil, asProtocolError(err)
+		}
+		return reply, nil
+	}
+	limits := scripting.Limits{MaxSteps: int64(server.Config().ScriptMaxSteps), Timeout: server.Config().ScriptTimeout()}
+	zap.L().Debug("Running script", zap.String("hash", script.Hash), zap.Strings("keys", keys))
+	var reply interface{}
+	database := session.database
+	run := func() {
+		reply, err = script.Run(keys, args[1+count:], call, limits)
+	}
+	if session.locked {
+		run()
+	} else {
+		server.runLocked(session, run)
+		server.snapshotIfDue(session)
+	}
+	session.database = database
+	if err != nil {
+		return nil, scriptError(err)
+	}
+	return reply, nil
+}
+
+// scriptError reports err of a script to the client. A failing command
+// keeps its error code, with the line that called it added.
+func scriptError(err error) error {
+	var failed *scripting.Error
+	if !errors.As(err, &failed) {
+		return newProtocolError(ErrCodeScript, "%s", err.Error())
+	}
+	var protocolError *ProtocolError
+	if errors.As(failed.Err, &protocolError) {
+		return newProtocolError(protocolError.Code, "script line %d: %s", failed.Line, protocolError.Message)
+	}
+	return newProtocolError(ErrCodeScript, "script line %d: %s", failed.Line, failed.Err.Error())
+}
+
+// scriptCommand implements SCRIPT LOAD script, SCRIPT EXISTS hash [hash
+// ...] and SCRIPT FLUSH.
+func scriptCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	subcommand, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToUpper(subcommand) {
+	case "LOAD":
+		if len(args) != 2 {
+			return nil, newProtocolError(ErrCodeInvalidArgument, "SCRIPT LOAD expects a script")
+		}
+		source, err := stringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		script, err := server.scripts.load(source)
+		if err != nil {
+			return nil, scriptError(err)
+		}
+		return script.Hash, nil
+	case "EXISTS":
+		hashes, err := keyArgs(args[1:])
+		if err != nil {
+			return nil, err
+		}
+		found := make([]int64, 0, len(hashes))
+		for _, hash := range hashes {
+			if server.scripts.get(hash) != nil {
+				found = append(found, 1)
+			} else {
+				found = append(found, 0)
+			}
+		}
+		return found, nil
+	case "FLUSH":
+		server.scripts.flush()
+		return "OK", nil
+	}
+	return nil, newProtocolError(ErrCodeInvalidArgument, "unknown SCRIPT subcommand %q", subcommand)
+}
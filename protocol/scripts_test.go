@@ -0,0 +1,111 @@
+package protocol
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScriptsCallCommands(t *testing.T) {
+	server := newTestServer(t, 2)
+	session := &clientSession{database: "0"}
+	source := `call("SET", KEYS[1], ARGV[1]) call("INCRBY", KEYS[1], 2) return {call("GET", KEYS[1]), #KEYS}`
+	reply, err := do(server, session, "EVAL", source, int64(1), "counter", int64(40))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []interface{}{int64(42), int64(1)}; !reflect.DeepEqual(reply, expected) {
+		t.Fatalf("expected %#v, found %#v", expected, reply)
+	}
+	hash, err := do(server, session, "SCRIPT", "LOAD", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := do(server, session, "EVALSHA", hash, int64(1), "counter", int64(1)); err != nil || !reflect.DeepEqual(reply, []interface{}{int64(3), int64(1)}) {
+		t.Fatalf("expected EVALSHA to run the loaded script, found %#v, %v", reply, err)
+	}
+	if exists, _ := do(server, session, "SCRIPT", "EXISTS", hash, "missing"); !reflect.DeepEqual(exists, []int64{1, 0}) {
+		t.Fatalf("expected SCRIPT EXISTS to find only the loaded script, found %#v", exists)
+	}
+	do(server, session, "SCRIPT", "FLUSH")
+	_, err = do(server, session, "EVALSHA", hash, int64(0))
+	expectCode(t, err, ErrCodeNoScript)
+}
+
+// expectCode fails unless err is a protocol error with code.
+func expectCode(t *testing.T, err error, code uint16) {
+	t.Helper()
+	var protocolError *ProtocolError
+	if !errors.As(err, &protocolError) || protocolError.Code != code {
+		t.Fatalf("expected error code %d, found %v", code, err)
+	}
+}
+
+func TestScriptCommandErrorsKeepTheirCode(t *testing.T) {
+	server := newTestServer(t, 1)
+	session := &clientSession{database: "0"}
+	_, err := do(server, session, "EVAL", "call(\"SET\", \"k\", \"text\")\ncall(\"INCRBY\", \"k\", 1)", int64(0))
+	expectCode(t, err, ErrCodeInvalidArgument)
+	if message := err.(*ProtocolError).Message; !strings.HasPrefix(message, "script line 2") {
+		t.Fatalf("expected the error to name line 2, found %q", message)
+	}
+	// the command before the failing one keeps its effect
+	if value, _ := do(server, session, "GET", "k"); value != "text" {
+		t.Fatalf("expected k to stay set, found %#v", value)
+	}
+	_, err = do(server, session, "EVAL", `call("NOSUCHCOMMAND")`, int64(0))
+	expectCode(t, err, ErrCodeUnknownCommand)
+	_, err = do(server, session, "EVAL", "return 1 +", int64(0))
+	expectCode(t, err, ErrCodeScript)
+	_, err = do(server, session, "EVAL", "return 1", int64(2), "only one")
+	expectCode(t, err, ErrCodeInvalidArgument)
+}
+
+func TestScriptsCanNotCallNoScriptCommands(t *testing.T) {
+	server := newTestServer(t, 1)
+	session := &clientSession{database: "0"}
+	for _, source := range []string{
+		`call("STATS")`,
+		`call("CONFIG", "GET", "*")`,
+		`call("EVAL", "return 1", 0)`,
+		`call("MULTI")`,
+		`call("WATCH", "k")`,
+		`call("SRANDMEMBER", "k")`,
+	} {
+		_, err := do(server, session, "EVAL", source, int64(0))
+		expectCode(t, err, ErrCodeScript)
+	}
+}
+
+func TestScriptSelectLastsUntilTheScriptEnds(t *testing.T) {
+	server := newTestServer(t, 2)
+	session := &clientSession{database: "0"}
+	if _, err := do(server, session, "EVAL", `call("SELECT", "1") call("SET", "k", 1)`, int64(0)); err != nil {
+		t.Fatal(err)
+	}
+	if session.database != "0" {
+		t.Fatalf("expected the session to be back in database 0, found %s", session.database)
+	}
+	if value, _ := do(server, session, "GET", "k"); value != nil {
+		t.Fatalf("expected k to be set in database 1 only, found %#v in 0", value)
+	}
+	do(server, session, "SELECT", "1")
+	if value, _ := do(server, session, "GET", "k"); value != int64(1) {
+		t.Fatalf("expected k in database 1, found %#v", value)
+	}
+}
+
+func TestScriptLimitsComeFromTheConfig(t *testing.T) {
+	server := newTestServer(t, 1)
+	session := &clientSession{database: "0"}
+	if _, err := do(server, session, "CONFIG", "SET", "script_max_steps", "100"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := do(server, session, "EVAL", "while true do end", int64(0))
+	expectCode(t, err, ErrCodeScript)
+	// the databases are unlocked again after the script was stopped
+	if _, err := do(server, session, "SET", "k", int64(1)); err != nil {
+		t.Fatal(err)
+	}
+}
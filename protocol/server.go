@@ -0,0 +1,347 @@
+package protocol
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"in-memory-store/config"
+	"in-memory-store/schemas"
+	"in-memory-store/scripting"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ServerMetrics counts connections and the reasons clients were dropped.
+type ServerMetrics struct {
+	AcceptedConnections atomic.Int64
+	ActiveConnections   atomic.Int64
+	RejectedConnections atomic.Int64
+	HandshakeFailures   atomic.Int64
+	IdleTimeouts        atomic.Int64
+	ReadTimeouts        atomic.Int64
+	OversizedFrames     atomic.Int64
+	BlockedClients      atomic.Int64
+}
+
+type Server struct {
+	config atomic.Pointer[config.Config]
+	// configMu serializes config updates, so two of them can not both
+	// derive from the same running config and one be lost
+	configMu    sync.Mutex
+	source      *config.Source
+	configHook  func(*config.Config)
+	databases   *schemas.Databases
+	writes      atomic.Int64
+	listeners   []net.Listener
+	metrics     ServerMetrics
+	heap        heapSampler
+	mu          sync.Mutex
+	connections map[net.Conn]struct{}
+	wg          sync.WaitGroup
+	closing     atomic.Bool
+	stop        chan struct{}
+	waiters     popWaiters
+	scripts     scriptCache
+}
+
+// NewServer creates a server for databases, which must hold the default
+// database. source is used to reload the configuration and may be nil.
+func NewServer(serverConfig *config.Config, source *config.Source, databases *schemas.Databases) *Server {
+	server := &Server{
+		source:      source,
+		databases:   databases,
+		heap:        heapSampler{sampleEvery: 100 * time.Millisecond},
+		connections: make(map[net.Conn]struct{}),
+		stop:        make(chan struct{}),
+		waiters:     popWaiters{queues: make(map[waitKey][]*popWaiter)},
+		scripts:     scriptCache{scripts: make(map[string]*scripting.Script)},
+	}
+	server.config.Store(serverConfig)
+	return server
+}
+
+// Config returns the running configuration. It is replaced as a whole on
+// reload and must not be modified.
+func (server *Server) Config() *config.Config {
+	return server.config.Load()
+}
+
+func (server *Server) Metrics() *ServerMetrics {
+	return &server.metrics
+}
+
+// Pairs lists every counter by name followed by its value, as STATS
+// replies.
+func (metrics *ServerMetrics) Pairs() []interface{} {
+	return []interface{}{
+		"accepted_connections", metrics.AcceptedConnections.Load(),
+		"active_connections", metrics.ActiveConnections.Load(),
+		"rejected_connections", metrics.RejectedConnections.Load(),
+		"handshake_failures", metrics.HandshakeFailures.Load(),
+		"idle_timeouts", metrics.IdleTimeouts.Load(),
+		"read_timeouts", metrics.ReadTimeouts.Load(),
+		"oversized_frames", metrics.OversizedFrames.Load(),
+		"blocked_clients", metrics.BlockedClients.Load(),
+	}
+}
+
+// ListenAndServe listens on every configured address and accepts clients
+// until a listener fails or Shutdown is called. Clients above
+// MaxConnections get an ErrCodeTooManyClients error frame and are closed.
+func (server *Server) ListenAndServe() error {
+	listeners := []net.Listener{}
+	for _, address := range server.Config().ListenAddresses {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, listener)
+	}
+	server.mu.Lock()
+	if server.closing.Load() {
+		server.mu.Unlock()
+		for _, listener := range listeners {
+			listener.Close()
+		}
+		return nil
+	}
+	server.listeners = listeners
+	server.mu.Unlock()
+
+	go server.runSnapshotSchedule(server.stop)
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func() {
+			errs <- server.serveListener(listener)
+		}()
+	}
+	var firstError error
+	for range listeners {
+		if err := <-errs; err != nil && firstError == nil {
+			firstError = err
+			server.closeListeners()
+		}
+	}
+	return firstError
+}
+
+func (server *Server) closeListeners() {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	for _, listener := range server.listeners {
+		listener.Close()
+	}
+}
+
+func (server *Server) serveListener(listener net.Listener) error {
+	defer listener.Close()
+	zap.L().Info("Server listening", zap.String("address", listener.Addr().String()))
+	for {
+		client, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			var netError net.Error
+			if errors.As(err, &netError) && netError.Timeout() {
+				zap.L().Error("failed accepting connection from client", zap.Error(err))
+				continue
+			}
+			return err
+		}
+		// counting the connection before checking keeps connections accepted
+		// at the same time from all passing the limit
+		active := server.metrics.ActiveConnections.Add(1)
+		if maxConnections := server.Config().MaxConnections; maxConnections > 0 && active > int64(maxConnections) {
+			server.metrics.ActiveConnections.Add(-1)
+			server.rejectConnection(client)
+			continue
+		}
+		if !server.trackConnection(client) {
+			server.metrics.ActiveConnections.Add(-1)
+			client.Close()
+			return nil
+		}
+		server.metrics.AcceptedConnections.Add(1)
+		go func() {
+			defer server.untrackConnection(client)
+			defer server.metrics.ActiveConnections.Add(-1)
+			defer client.Close()
+			server.acceptConnection(client)
+		}()
+	}
+}
+
+func (server *Server) trackConnection(client net.Conn) bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.closing.Load() {
+		return false
+	}
+	server.connections[client] = struct{}{}
+	server.wg.Add(1)
+	return true
+}
+
+func (server *Server) untrackConnection(client net.Conn) {
+	server.mu.Lock()
+	delete(server.connections, client)
+	server.mu.Unlock()
+	server.wg.Done()
+}
+
+// Shutdown stops accepting clients and wakes every connection waiting for
+// its next frame. Commands already being executed finish and get their
+// reply. If ctx expires first the remaining connections are closed forcibly
+// and ctx's error is returned.
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.mu.Lock()
+	if !server.closing.Swap(true) {
+		close(server.stop)
+	}
+	for _, listener := range server.listeners {
+		listener.Close()
+	}
+	for client := range server.connections {
+		client.SetReadDeadline(time.Now())
+	}
+	server.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		server.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		server.mu.Lock()
+		for client := range server.connections {
+			client.Close()
+		}
+		server.mu.Unlock()
+		<-drained
+		return ctx.Err()
+	}
+}
+
+func (server *Server) rejectConnection(client net.Conn) {
+	server.metrics.RejectedConnections.Add(1)
+	zap.L().Warn("Rejecting connection, too many clients",
+		zap.String("Remote address", client.RemoteAddr().String()),
+		zap.Int("Max connections", server.Config().MaxConnections),
+	)
+	writer := &deadlineWriter{conn: client, timeout: func() time.Duration { return time.Second }}
+	writeErrorFrame(writer, HandshakeVersion, ErrCodeTooManyClients, "too many connections")
+	client.Close()
+}
+
+// waitForFrame blocks until the next frame starts under the idle timeout,
+// then arms the read timeout for the rest of the frame. It returns false
+// when the connection should be closed.
+func (server *Server) waitForFrame(client net.Conn, reader *bufio.Reader) bool {
+	// the deadline is armed under the server lock so a concurrent Shutdown
+	// either sees this connection waiting or is seen by it
+	server.mu.Lock()
+	if server.closing.Load() {
+		server.mu.Unlock()
+		return false
+	}
+	if idleTimeout := server.Config().IdleTimeout(); idleTimeout > 0 {
+		client.SetReadDeadline(time.Now().Add(idleTimeout))
+	} else {
+		client.SetReadDeadline(time.Time{})
+	}
+	server.mu.Unlock()
+	if _, err := reader.Peek(1); err != nil {
+		if server.closing.Load() {
+			zap.L().Info("Closing client, server is shutting down", zap.String("Remote address", client.RemoteAddr().String()))
+		} else if isTimeout(err) {
+			server.metrics.IdleTimeouts.Add(1)
+			zap.L().Info("Closing idle client", zap.String("Remote address", client.RemoteAddr().String()))
+		} else if isConnectionClosed(err) {
+			zap.L().Info("Client disconnected")
+		} else {
+			zap.L().Error("Failed waiting for frame", zap.Error(err))
+		}
+		return false
+	}
+	if readTimeout := server.Config().ReadTimeout(); readTimeout > 0 {
+		client.SetReadDeadline(time.Now().Add(readTimeout))
+	} else {
+		client.SetReadDeadline(time.Time{})
+	}
+	return true
+}
+
+func isTimeout(err error) bool {
+	var netError net.Error
+	return errors.As(err, &netError) && netError.Timeout()
+}
+
+// deadlineWriter arms the write deadline before every write so a client
+// that stops reading cannot block the server forever.
+type deadlineWriter struct {
+	conn    net.Conn
+	timeout func() time.Duration
+}
+
+func (writer *deadlineWriter) Write(bytes []byte) (int, error) {
+	if timeout := writer.timeout(); timeout > 0 {
+		writer.conn.SetWriteDeadline(time.Now().Add(timeout))
+	}
+	return writer.conn.Write(bytes)
+}
+
+// OnConfigChange registers a hook called with every configuration that
+// replaces the running one, for settings applied outside the server such
+// as the log level.
+func (server *Server) OnConfigChange(hook func(*config.Config)) {
+	server.configHook = hook
+}
+
+// updateConfig replaces the running config with what update derives from
+// it, unless update fails. Updates run one at a time.
+func (server *Server) updateConfig(update func(running *config.Config) (*config.Config, error)) error {
+	server.configMu.Lock()
+	defer server.configMu.Unlock()
+	updated, err := update(server.Config())
+	if err != nil {
+		return err
+	}
+	server.config.Store(updated)
+	if server.configHook != nil {
+		server.configHook(updated)
+	}
+	zap.L().Info("Configuration updated")
+	return nil
+}
+
+// ReloadConfig loads the configuration again from its source and applies
+// the hot settings. Settings that changed but need a restart are logged
+// and keep their running value.
+func (server *Server) ReloadConfig() error {
+	if server.source == nil {
+		return fmt.Errorf("no configuration source to reload from")
+	}
+	reloaded, err := server.source.Reload()
+	if err != nil {
+		return err
+	}
+	return server.updateConfig(func(running *config.Config) (*config.Config, error) {
+		merged, ignored := running.MergeReloaded(reloaded)
+		if len(ignored) > 0 {
+			zap.L().Warn("Settings changed but need a restart", zap.Strings("settings", ignored))
+		}
+		return merged, nil
+	})
+}
@@ -0,0 +1,195 @@
+package protocol
+
+import (
+	"in-memory-store/schemas"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "SADD", minArgs: 2, maxArgs: -1, denyOOM: true, handler: saddCommand},
+		&command{name: "SREM", minArgs: 2, maxArgs: -1, handler: sremCommand},
+		&command{name: "SISMEMBER", minArgs: 2, maxArgs: 2, handler: sismemberCommand},
+		&command{name: "SCARD", minArgs: 1, maxArgs: 1, handler: scardCommand},
+		&command{name: "SMEMBERS", minArgs: 1, maxArgs: 1, handler: smembersCommand},
+		&command{name: "SRANDMEMBER", minArgs: 1, maxArgs: 2, noScript: true, handler: srandmemberCommand},
+		&command{name: "SUNION", minArgs: 1, maxArgs: -1, handler: combineCommand(schemas.SetUnion)},
+		&command{name: "SINTER", minArgs: 1, maxArgs: -1, handler: combineCommand(schemas.SetIntersection)},
+		&command{name: "SDIFF", minArgs: 1, maxArgs: -1, handler: combineCommand(schemas.SetDifference)},
+		&command{name: "SUNIONSTORE", minArgs: 2, maxArgs: -1, denyOOM: true, handler: combineStoreCommand(schemas.SetUnion)},
+		&command{name: "SINTERSTORE", minArgs: 2, maxArgs: -1, denyOOM: true, handler: combineStoreCommand(schemas.SetIntersection)},
+		&command{name: "SDIFFSTORE", minArgs: 2, maxArgs: -1, denyOOM: true, handler: combineStoreCommand(schemas.SetDifference)},
+	)
+}
+
+// memberArgs reads set members from args, expanding string arrays so a
+// whole array can be added at once.
+func memberArgs(args []interface{}) ([]string, error) {
+	members := []string{}
+	for i, arg := range args {
+		if values, ok := arg.([]string); ok {
+			members = append(members, values...)
+			continue
+		}
+		member, err := stringArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func saddCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	members, err := memberArgs(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	var added int
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		added, err = mainMap.SetAdd(key, members...)
+		return added > 0, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return int64(added), nil
+}
+
+func sremCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	members, err := memberArgs(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	var removed int
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		removed, err = mainMap.SetRemove(key, members...)
+		return removed > 0, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return int64(removed), nil
+}
+
+func sismemberCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	member, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var found bool
+	server.read(session, func(mainMap *schemas.MainMap) {
+		found, err = mainMap.SetIsMember(key, member)
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	if found {
+		return int64(1), nil
+	}
+	return int64(0), nil
+}
+
+func scardCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var size int
+	server.read(session, func(mainMap *schemas.MainMap) {
+		size, err = mainMap.SetCardinality(key)
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	return int64(size), nil
+}
+
+func smembersCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var members []string
+	server.read(session, func(mainMap *schemas.MainMap) {
+		members, err = mainMap.SetMembers(key)
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	return members, nil
+}
+
+// srandmemberCommand implements SRANDMEMBER key [count]. Without a count
+// it replies with a single member, nil for an empty set.
+func srandmemberCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	count := int64(1)
+	if len(args) == 2 {
+		if count, err = integerArg(args, 1); err != nil {
+			return nil, err
+		}
+	}
+	var members []string
+	server.read(session, func(mainMap *schemas.MainMap) {
+		members, err = mainMap.SetRandomMembers(key, int(count))
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	if len(args) == 1 {
+		return firstElement(members), nil
+	}
+	return members, nil
+}
+
+func combineCommand(operation schemas.SetOperation) handlerFunc {
+	return func(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+		keys, err := keyArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		var members []string
+		server.read(session, func(mainMap *schemas.MainMap) {
+			members, err = mainMap.SetCombine(operation, keys...)
+		})
+		if err != nil {
+			return nil, invalidArgument(err)
+		}
+		return members, nil
+	}
+}
+
+// combineStoreCommand builds the handler of the *STORE variants, taking
+// the destination key first.
+func combineStoreCommand(operation schemas.SetOperation) handlerFunc {
+	return func(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+		keys, err := keyArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		var size int
+		err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+			size, err = mainMap.SetCombineStore(operation, keys[0], keys[1:]...)
+			return err == nil, invalidArgument(err)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return int64(size), nil
+	}
+}
@@ -0,0 +1,336 @@
+package protocol
+
+import (
+	"in-memory-store/schemas"
+	"strings"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "ZADD", minArgs: 3, maxArgs: -1, denyOOM: true, handler: zaddCommand},
+		&command{name: "ZINCRBY", minArgs: 3, maxArgs: 3, denyOOM: true, handler: zincrbyCommand},
+		&command{name: "ZSCORE", minArgs: 2, maxArgs: 2, handler: zscoreCommand},
+		&command{name: "ZREM", minArgs: 2, maxArgs: -1, handler: zremCommand},
+		&command{name: "ZCARD", minArgs: 1, maxArgs: 1, handler: zcardCommand},
+		&command{name: "ZRANK", minArgs: 2, maxArgs: 2, handler: zrankCommand(false)},
+		&command{name: "ZREVRANK", minArgs: 2, maxArgs: 2, handler: zrankCommand(true)},
+		&command{name: "ZRANGE", minArgs: 3, maxArgs: 4, handler: zrangeCommand(false)},
+		&command{name: "ZREVRANGE", minArgs: 3, maxArgs: 4, handler: zrangeCommand(true)},
+		&command{name: "ZRANGEBYSCORE", minArgs: 3, maxArgs: 7, handler: zrangeByScoreCommand(false)},
+		&command{name: "ZREVRANGEBYSCORE", minArgs: 3, maxArgs: 7, handler: zrangeByScoreCommand(true)},
+		&command{name: "ZRANGEBYLEX", minArgs: 3, maxArgs: 6, handler: zrangeByLexCommand(false)},
+		&command{name: "ZREVRANGEBYLEX", minArgs: 3, maxArgs: 6, handler: zrangeByLexCommand(true)},
+		&command{name: "ZPOPMIN", minArgs: 1, maxArgs: 2, handler: zpopCommand(false)},
+		&command{name: "ZPOPMAX", minArgs: 1, maxArgs: 2, handler: zpopCommand(true)},
+	)
+}
+
+// rangeOptions holds the optional WITHSCORES and LIMIT offset count
+// arguments of the range commands.
+type rangeOptions struct {
+	withScores bool
+	offset     int
+	count      int
+}
+
+func parseRangeOptions(args []interface{}, allowScores bool, allowLimit bool) (*rangeOptions, error) {
+	options := &rangeOptions{count: -1}
+	for i := 0; i < len(args); i++ {
+		name, err := stringArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case allowScores && strings.EqualFold(name, "WITHSCORES"):
+			options.withScores = true
+		case allowLimit && strings.EqualFold(name, "LIMIT") && i+2 < len(args):
+			offset, err := integerArg(args, i+1)
+			if err != nil {
+				return nil, err
+			}
+			count, err := integerArg(args, i+2)
+			if err != nil {
+				return nil, err
+			}
+			if offset < 0 {
+				return nil, newProtocolError(ErrCodeInvalidArgument, "LIMIT offset must not be negative, found %d", offset)
+			}
+			options.offset, options.count = int(offset), int(count)
+			i += 2
+		default:
+			return nil, newProtocolError(ErrCodeInvalidArgument, "unexpected argument %q", name)
+		}
+	}
+	return options, nil
+}
+
+// scoredReply replies with the members, followed by their scores when
+// withScores is set.
+func scoredReply(members []schemas.ScoredMember, withScores bool) interface{} {
+	if !withScores {
+		names := make([]string, 0, len(members))
+		for _, scored := range members {
+			names = append(names, scored.Member)
+		}
+		return names
+	}
+	pairs := make([]interface{}, 0, 2*len(members))
+	for _, scored := range members {
+		pairs = append(pairs, scored.Member, scored.Score)
+	}
+	return pairs
+}
+
+// zaddCommand implements ZADD key score member [score member ...] and
+// replies with the number of new members.
+func zaddCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(args)%2 != 1 {
+		return nil, newProtocolError(ErrCodeInvalidArgument, "ZADD needs a member for every score")
+	}
+	scores := map[string]float64{}
+	for i := 1; i < len(args); i += 2 {
+		score, err := floatArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		member, err := stringArg(args, i+1)
+		if err != nil {
+			return nil, err
+		}
+		scores[member] = score
+	}
+	var added int
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		added, err = mainMap.SortedSetAdd(key, scores)
+		return err == nil, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return int64(added), nil
+}
+
+// zincrbyCommand implements ZINCRBY key delta member.
+func zincrbyCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	delta, err := floatArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	member, err := stringArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	var score float64
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		score, err = mainMap.SortedSetIncrement(key, member, delta)
+		return err == nil, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return score, nil
+}
+
+func zscoreCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	keys, err := keyArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	var score float64
+	var found bool
+	server.read(session, func(mainMap *schemas.MainMap) {
+		score, found, err = mainMap.SortedSetScore(keys[0], keys[1])
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return score, nil
+}
+
+func zremCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	keys, err := keyArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	var removed int
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		removed, err = mainMap.SortedSetRemove(keys[0], keys[1:]...)
+		return removed > 0, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return int64(removed), nil
+}
+
+func zcardCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var size int
+	server.read(session, func(mainMap *schemas.MainMap) {
+		size, err = mainMap.SortedSetCardinality(key)
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	return int64(size), nil
+}
+
+func zrankCommand(reverse bool) handlerFunc {
+	return func(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+		keys, err := keyArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		var rank int
+		var found bool
+		server.read(session, func(mainMap *schemas.MainMap) {
+			rank, found, err = mainMap.SortedSetRank(keys[0], keys[1], reverse)
+		})
+		if err != nil {
+			return nil, invalidArgument(err)
+		}
+		if !found {
+			return nil, nil
+		}
+		return int64(rank), nil
+	}
+}
+
+// zrangeCommand implements ZRANGE and ZREVRANGE key start stop
+// [WITHSCORES].
+func zrangeCommand(reverse bool) handlerFunc {
+	return func(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+		key, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		start, stop, err := rangeArgs(args[:3], 1)
+		if err != nil {
+			return nil, err
+		}
+		options, err := parseRangeOptions(args[3:], true, false)
+		if err != nil {
+			return nil, err
+		}
+		var members []schemas.ScoredMember
+		server.read(session, func(mainMap *schemas.MainMap) {
+			members, err = mainMap.SortedSetRangeByRank(key, start, stop, reverse)
+		})
+		if err != nil {
+			return nil, invalidArgument(err)
+		}
+		return scoredReply(members, options.withScores), nil
+	}
+}
+
+// zrangeByScoreCommand implements ZRANGEBYSCORE key min max and
+// ZREVRANGEBYSCORE key max min, both with [WITHSCORES] [LIMIT offset
+// count]. Bounds are numbers, (number for exclusive ones, -inf or +inf.
+func zrangeByScoreCommand(reverse bool) handlerFunc {
+	return func(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+		texts, err := keyArgs(args[:3])
+		if err != nil {
+			return nil, err
+		}
+		first, err := schemas.ParseScoreBound(texts[1])
+		if err != nil {
+			return nil, invalidArgument(err)
+		}
+		second, err := schemas.ParseScoreBound(texts[2])
+		if err != nil {
+			return nil, invalidArgument(err)
+		}
+		min, max := first, second
+		if reverse {
+			min, max = second, first
+		}
+		options, err := parseRangeOptions(args[3:], true, true)
+		if err != nil {
+			return nil, err
+		}
+		var members []schemas.ScoredMember
+		server.read(session, func(mainMap *schemas.MainMap) {
+			members, err = mainMap.SortedSetRangeByScore(texts[0], min, max, reverse, options.offset, options.count)
+		})
+		if err != nil {
+			return nil, invalidArgument(err)
+		}
+		return scoredReply(members, options.withScores), nil
+	}
+}
+
+// zrangeByLexCommand implements ZRANGEBYLEX key min max and
+// ZREVRANGEBYLEX key max min, both with [LIMIT offset count]. Bounds are
+// [member, (member, - or +.
+func zrangeByLexCommand(reverse bool) handlerFunc {
+	return func(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+		texts, err := keyArgs(args[:3])
+		if err != nil {
+			return nil, err
+		}
+		first, err := schemas.ParseLexBound(texts[1])
+		if err != nil {
+			return nil, invalidArgument(err)
+		}
+		second, err := schemas.ParseLexBound(texts[2])
+		if err != nil {
+			return nil, invalidArgument(err)
+		}
+		min, max := first, second
+		if reverse {
+			min, max = second, first
+		}
+		options, err := parseRangeOptions(args[3:], false, true)
+		if err != nil {
+			return nil, err
+		}
+		var members []schemas.ScoredMember
+		server.read(session, func(mainMap *schemas.MainMap) {
+			members, err = mainMap.SortedSetRangeByLex(texts[0], min, max, reverse, options.offset, options.count)
+		})
+		if err != nil {
+			return nil, invalidArgument(err)
+		}
+		return scoredReply(members, false), nil
+	}
+}
+
+// zpopCommand implements ZPOPMIN and ZPOPMAX key [count], replying with
+// member, score pairs.
+func zpopCommand(max bool) handlerFunc {
+	return func(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+		key, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		count := int64(1)
+		if len(args) == 2 {
+			if count, err = integerArg(args, 1); err != nil {
+				return nil, err
+			}
+		}
+		var popped []schemas.ScoredMember
+		err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+			popped, err = mainMap.SortedSetPop(key, int(count), max)
+			return len(popped) > 0, invalidArgument(err)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return scoredReply(popped, true), nil
+	}
+}
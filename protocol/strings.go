@@ -0,0 +1,156 @@
+package protocol
+
+import (
+	"in-memory-store/schemas"
+	"strings"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "APPEND", minArgs: 2, maxArgs: 2, denyOOM: true, handler: appendCommand},
+		&command{name: "STRLEN", minArgs: 1, maxArgs: 2, handler: strlenCommand},
+		&command{name: "GETRANGE", minArgs: 3, maxArgs: 3, handler: getrangeCommand},
+		&command{name: "SETRANGE", minArgs: 3, maxArgs: 3, denyOOM: true, handler: setrangeCommand},
+		&command{name: "GETSET", minArgs: 2, maxArgs: 2, denyOOM: true, handler: getsetCommand},
+		&command{name: "GETDEL", minArgs: 1, maxArgs: 1, handler: getdelCommand},
+		&command{name: "SETNX", minArgs: 2, maxArgs: 2, denyOOM: true, handler: setnxCommand},
+	)
+}
+
+func appendCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	value, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var length int
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		length, err = mainMap.AppendString(key, value)
+		return err == nil, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return int64(length), nil
+}
+
+// strlenCommand implements STRLEN key [BYTES|RUNES], counting bytes by
+// default.
+func strlenCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	unit := "BYTES"
+	if len(args) == 2 {
+		if unit, err = stringArg(args, 1); err != nil {
+			return nil, err
+		}
+		unit = strings.ToUpper(unit)
+		if unit != "BYTES" && unit != "RUNES" {
+			return nil, newProtocolError(ErrCodeInvalidArgument, "expected BYTES or RUNES, found %q", unit)
+		}
+	}
+	var bytes, runes int
+	server.read(session, func(mainMap *schemas.MainMap) {
+		bytes, runes, err = mainMap.StringLength(key)
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	if unit == "RUNES" {
+		return int64(runes), nil
+	}
+	return int64(bytes), nil
+}
+
+func getrangeCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	start, stop, err := rangeArgs(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var value string
+	server.read(session, func(mainMap *schemas.MainMap) {
+		value, err = mainMap.GetRange(key, start, stop)
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	return value, nil
+}
+
+func setrangeCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := integerArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	value, err := stringArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	var length int
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		length, err = mainMap.SetRange(key, int(offset), value)
+		return err == nil && value != "", invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return int64(length), nil
+}
+
+func getsetCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var previous interface{}
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		previous, err = mainMap.GetSet(key, args[1])
+		if err != nil {
+			return false, invalidArgument(err)
+		}
+		server.waiters.serve(mainMap, key)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+func getdelCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var previous interface{}
+	server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		previous = mainMap.GetDelete(key)
+		return previous != nil, nil
+	})
+	return previous, nil
+}
+
+// setnxCommand replies 1 when the key was set and 0 when it existed.
+func setnxCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	reply, err := setCommand(server, session, []interface{}{args[0], args[1], "NX"})
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return int64(0), nil
+	}
+	return int64(1), nil
+}
@@ -0,0 +1,97 @@
+package protocol
+
+import (
+	"in-memory-store/schemas"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "FTCREATE", minArgs: 2, maxArgs: -1, denyOOM: true, handler: ftcreateCommand},
+		&command{name: "FTDROP", minArgs: 1, maxArgs: 1, handler: ftdropCommand},
+		&command{name: "FTLIST", minArgs: 0, maxArgs: 0, handler: ftlistCommand},
+		&command{name: "FTSEARCH", minArgs: 2, maxArgs: 5, handler: ftsearchCommand},
+	)
+}
+
+// ftcreateCommand implements FTCREATE name prefix [prefix ...].
+func ftcreateCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	names, err := keyArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		err := mainMap.CreateTextIndex(names[0], names[1:])
+		return err == nil, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return "OK", nil
+}
+
+// ftdropCommand implements FTDROP name and replies 1 when the index
+// existed.
+func ftdropCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	name, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var dropped bool
+	server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		dropped = mainMap.DropTextIndex(name)
+		return dropped, nil
+	})
+	if dropped {
+		return int64(1), nil
+	}
+	return int64(0), nil
+}
+
+// ftlistCommand replies with one [name, prefixes, documents, terms] entry
+// per index.
+func ftlistCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	entries := []interface{}{}
+	server.read(session, func(mainMap *schemas.MainMap) {
+		for _, index := range mainMap.TextIndexes() {
+			prefixes := make([]interface{}, 0, len(index.Prefixes))
+			for _, prefix := range index.Prefixes {
+				prefixes = append(prefixes, prefix)
+			}
+			entries = append(entries, []interface{}{index.Name, prefixes, int64(index.Size()), int64(index.Terms())})
+		}
+	})
+	return entries, nil
+}
+
+// ftsearchCommand implements FTSEARCH name query [LIMIT offset count] and
+// replies with the number of matches and key, score pairs, best first.
+// Words in the query must all match unless joined by OR or |, NOT or -
+// excludes a word, "quoted words" must appear in order and parentheses
+// group.
+func ftsearchCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	name, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	query, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	options, err := parseRangeOptions(args[2:], false, true)
+	if err != nil {
+		return nil, err
+	}
+	var found []schemas.TextMatch
+	var total int
+	server.read(session, func(mainMap *schemas.MainMap) {
+		found, total, err = mainMap.TextSearch(name, query, options.offset, options.count)
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	pairs := make([]interface{}, 0, 2*len(found))
+	for _, match := range found {
+		pairs = append(pairs, match.Key, match.Score)
+	}
+	return []interface{}{int64(total), pairs}, nil
+}
@@ -0,0 +1,164 @@
+package protocol
+
+import (
+	"in-memory-store/schemas"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "MULTI", minArgs: 0, maxArgs: 0, immediate: true, noScript: true, handler: multiCommand},
+		&command{name: "EXEC", minArgs: 0, maxArgs: 0, immediate: true, noScript: true, handler: execCommand},
+		&command{name: "DISCARD", minArgs: 0, maxArgs: 0, immediate: true, noScript: true, handler: discardCommand},
+		&command{name: "WATCH", minArgs: 1, maxArgs: -1, immediate: true, noScript: true, handler: watchCommand},
+		&command{name: "UNWATCH", minArgs: 0, maxArgs: 0, immediate: true, noScript: true, handler: unwatchCommand},
+	)
+}
+
+// transaction is what a client queued between MULTI and EXEC.
+type transaction struct {
+	queued []queuedCommand
+	// failed is set when a command could not be queued
+	failed bool
+}
+
+type queuedCommand struct {
+	command *command
+	args    []interface{}
+}
+
+// multiCommand implements MULTI. Commands up to EXEC or DISCARD are
+// queued and answered with QUEUED.
+func multiCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	if session.transaction != nil {
+		return nil, newProtocolError(ErrCodeInvalidArgument, "MULTI calls can not be nested")
+	}
+	session.transaction = &transaction{}
+	return "OK", nil
+}
+
+// execCommand implements EXEC. The queued commands run one after the other
+// while every database is write locked, so no other client and no
+// snapshot sees the database between two of them. The reply holds the
+// reply or error of every command, or is nil when a watched key changed
+// and nothing ran. Commands that failed do not undo the others.
+func execCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	queued := session.transaction
+	if queued == nil {
+		return nil, newProtocolError(ErrCodeInvalidArgument, "EXEC without MULTI")
+	}
+	session.transaction = nil
+	if queued.failed {
+		server.unwatch(session)
+		return nil, newProtocolError(ErrCodeInvalidArgument, "transaction discarded because a command could not be queued")
+	}
+	changed := false
+	replies := []interface{}{}
+	server.runLocked(session, func() {
+		changed = session.watch != nil && session.watch.Changed()
+		releaseWatches(session)
+		if changed {
+			return
+		}
+		for _, next := range queued.queued {
+			reply, err := runCommand(server, session, next.command, next.args)
+			if err != nil {
+				reply = asProtocolError(err)
+			}
+			replies = append(replies, reply)
+		}
+	})
+	server.snapshotIfDue(session)
+	if changed {
+		return nil, nil
+	}
+	return replies, nil
+}
+
+// runLocked runs fn with every database write locked for session, and
+// releases them even when fn panics.
+func (server *Server) runLocked(session *clientSession, fn func()) {
+	server.databases.LockAll()
+	session.locked = true
+	defer func() {
+		session.locked = false
+		server.databases.UnlockAll()
+	}()
+	fn()
+}
+
+// snapshotIfDue takes the snapshot that became due while session held
+// every database lock.
+func (server *Server) snapshotIfDue(session *clientSession) {
+	if session.snapshotDue {
+		session.snapshotDue = false
+		server.takeSnapshot()
+	}
+}
+
+// asProtocolError turns err into the error value sent inside a reply.
+func asProtocolError(err error) *ProtocolError {
+	if protocolError, ok := err.(*ProtocolError); ok {
+		return protocolError
+	}
+	return &ProtocolError{Code: ErrCodeInternal, Message: err.Error()}
+}
+
+// discardCommand implements DISCARD, dropping the queued commands and
+// every watch.
+func discardCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	if session.transaction == nil {
+		return nil, newProtocolError(ErrCodeInvalidArgument, "DISCARD without MULTI")
+	}
+	session.transaction = nil
+	server.unwatch(session)
+	return "OK", nil
+}
+
+// watchCommand implements WATCH key [key ...] on keys of the selected
+// database. The next EXEC runs nothing once any of them changed after
+// WATCH, whoever changed it.
+func watchCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	if session.transaction != nil {
+		return nil, newProtocolError(ErrCodeInvalidArgument, "WATCH inside MULTI is not allowed")
+	}
+	keys, err := keyArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if session.watch == nil {
+		session.watch = &schemas.Watch{}
+	}
+	server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		for _, key := range keys {
+			mainMap.Watch(key, session.watch)
+			session.watched = append(session.watched, waitKey{mainMap, key})
+		}
+		return false, nil
+	})
+	return "OK", nil
+}
+
+// unwatchCommand implements UNWATCH, forgetting every watched key.
+func unwatchCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	server.unwatch(session)
+	return "OK", nil
+}
+
+// unwatch forgets the keys session watches.
+func (server *Server) unwatch(session *clientSession) {
+	if session.watch == nil {
+		return
+	}
+	server.databases.LockAll()
+	releaseWatches(session)
+	server.databases.UnlockAll()
+}
+
+// releaseWatches forgets the keys session watches while the caller holds
+// every database lock.
+func releaseWatches(session *clientSession) {
+	for _, watched := range session.watched {
+		watched.mainMap.Unwatch(watched.key, session.watch)
+	}
+	session.watch, session.watched = nil, nil
+}
@@ -0,0 +1,153 @@
+package protocol
+
+import (
+	"reflect"
+	"testing"
+)
+
+// mustDo runs a command that must succeed and returns its reply.
+func mustDo(t *testing.T, server *Server, session *clientSession, values ...interface{}) interface{} {
+	t.Helper()
+	reply, err := do(server, session, values...)
+	if err != nil {
+		t.Fatalf("%v failed: %v", values, err)
+	}
+	return reply
+}
+
+func TestExecRunsTheQueuedCommands(t *testing.T) {
+	server := newTestServer(t, 1)
+	session := &clientSession{database: "0"}
+	mustDo(t, server, session, "MULTI")
+	if reply := mustDo(t, server, session, "SET", "k", "v"); reply != "QUEUED" {
+		t.Fatalf("expected QUEUED, found %v", reply)
+	}
+	mustDo(t, server, session, "GET", "k")
+	// nothing runs before EXEC
+	if value := mustDo(t, server, &clientSession{database: "0"}, "GET", "k"); value != nil {
+		t.Fatalf("a queued command ran before EXEC, k is %v", value)
+	}
+	if replies := mustDo(t, server, session, "EXEC"); !reflect.DeepEqual(replies, []interface{}{"OK", "v"}) {
+		t.Fatalf("expected [OK v], found %v", replies)
+	}
+}
+
+func TestFailedCommandsDoNotUndoTheOthers(t *testing.T) {
+	server := newTestServer(t, 1)
+	session := &clientSession{database: "0"}
+	mustDo(t, server, session, "SET", "text", "x")
+	mustDo(t, server, session, "MULTI")
+	mustDo(t, server, session, "INCRBY", "text", int64(1))
+	mustDo(t, server, session, "SET", "k", "v")
+	replies := mustDo(t, server, session, "EXEC").([]interface{})
+	if protocolError, ok := replies[0].(*ProtocolError); !ok || protocolError.Code != ErrCodeInvalidArgument {
+		t.Fatalf("expected the INCRBY error in the reply, found %#v", replies[0])
+	}
+	if value := mustDo(t, server, session, "GET", "k"); value != "v" {
+		t.Fatalf("expected the SET after the failed INCRBY to run, k is %v", value)
+	}
+}
+
+func TestCommandsThatCanNotBeQueuedFailTheExec(t *testing.T) {
+	server := newTestServer(t, 1)
+	session := &clientSession{database: "0"}
+	mustDo(t, server, session, "MULTI")
+	mustDo(t, server, session, "SET", "k", "v")
+	_, err := do(server, session, "NOSUCHCOMMAND")
+	expectCode(t, err, ErrCodeUnknownCommand)
+	_, err = do(server, session, "GET")
+	expectCode(t, err, ErrCodeInvalidArgument)
+	_, err = do(server, session, "EXEC")
+	expectCode(t, err, ErrCodeInvalidArgument)
+	if value := mustDo(t, server, session, "GET", "k"); value != nil || session.transaction != nil {
+		t.Fatalf("expected the transaction to be discarded, k is %v", value)
+	}
+}
+
+func TestWatchedChangesAbortTheExec(t *testing.T) {
+	server := newTestServer(t, 1)
+	session, other := &clientSession{database: "0"}, &clientSession{database: "0"}
+	mustDo(t, server, session, "SET", "k", "1")
+	mustDo(t, server, session, "WATCH", "k", "absent")
+	mustDo(t, server, other, "SET", "k", "2")
+	mustDo(t, server, session, "MULTI")
+	mustDo(t, server, session, "SET", "x", "1")
+	if reply := mustDo(t, server, session, "EXEC"); reply != nil {
+		t.Fatalf("expected a nil reply from an aborted EXEC, found %v", reply)
+	}
+	if value := mustDo(t, server, session, "GET", "x"); value != nil {
+		t.Fatal("the aborted transaction ran")
+	}
+	if session.watch != nil {
+		t.Fatal("expected EXEC to forget the watched keys")
+	}
+
+	// creating a watched key counts as a change too, even by the watcher
+	mustDo(t, server, session, "WATCH", "absent")
+	mustDo(t, server, session, "SET", "absent", "now")
+	mustDo(t, server, session, "MULTI")
+	mustDo(t, server, session, "SET", "x", "1")
+	if reply := mustDo(t, server, session, "EXEC"); reply != nil {
+		t.Fatalf("expected creating a watched key to abort, found %v", reply)
+	}
+}
+
+func TestUnchangedWatchesLetTheExecRun(t *testing.T) {
+	server := newTestServer(t, 2)
+	session, other := &clientSession{database: "0"}, &clientSession{database: "1"}
+	mustDo(t, server, session, "WATCH", "k")
+	// the same name in another database is another key, and reads and
+	// failed writes change nothing
+	mustDo(t, server, other, "SET", "k", "elsewhere")
+	mustDo(t, server, session, "GET", "k")
+	mustDo(t, server, session, "SET", "k", "x", "XX")
+	mustDo(t, server, session, "MULTI")
+	mustDo(t, server, session, "SET", "k", "v")
+	if replies := mustDo(t, server, session, "EXEC"); !reflect.DeepEqual(replies, []interface{}{"OK"}) {
+		t.Fatalf("expected the transaction to run, found %v", replies)
+	}
+}
+
+func TestDiscardAndUnwatch(t *testing.T) {
+	server := newTestServer(t, 1)
+	session, other := &clientSession{database: "0"}, &clientSession{database: "0"}
+	mustDo(t, server, session, "WATCH", "k")
+	mustDo(t, server, session, "MULTI")
+	mustDo(t, server, session, "SET", "x", "1")
+	mustDo(t, server, session, "DISCARD")
+	mustDo(t, server, other, "SET", "k", "changed")
+	// DISCARD dropped the watch along with the queued commands
+	mustDo(t, server, session, "MULTI")
+	if replies := mustDo(t, server, session, "EXEC"); !reflect.DeepEqual(replies, []interface{}{}) {
+		t.Fatalf("expected an empty transaction to run, found %v", replies)
+	}
+	if value := mustDo(t, server, session, "GET", "x"); value != nil {
+		t.Fatal("a discarded command ran")
+	}
+
+	mustDo(t, server, session, "WATCH", "k")
+	mustDo(t, server, session, "UNWATCH")
+	mustDo(t, server, other, "SET", "k", "again")
+	mustDo(t, server, session, "MULTI")
+	if reply := mustDo(t, server, session, "EXEC"); reply == nil {
+		t.Fatal("expected UNWATCH to forget the watched keys")
+	}
+}
+
+func TestTransactionMisuse(t *testing.T) {
+	server := newTestServer(t, 1)
+	session := &clientSession{database: "0"}
+	_, err := do(server, session, "EXEC")
+	expectCode(t, err, ErrCodeInvalidArgument)
+	_, err = do(server, session, "DISCARD")
+	expectCode(t, err, ErrCodeInvalidArgument)
+	mustDo(t, server, session, "MULTI")
+	_, err = do(server, session, "MULTI")
+	expectCode(t, err, ErrCodeInvalidArgument)
+	_, err = do(server, session, "WATCH", "k")
+	expectCode(t, err, ErrCodeInvalidArgument)
+	// the misuse inside MULTI does not discard the transaction
+	if replies := mustDo(t, server, session, "EXEC"); replies == nil {
+		t.Fatal("expected the transaction to run")
+	}
+}
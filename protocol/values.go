@@ -0,0 +1,231 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"in-memory-store/constants"
+	"math"
+)
+
+// EncodeValues writes each value as a type tag (1 byte) followed by its
+// payload. Lengths and numbers are big endian, strings are length prefixed.
+// A []interface{} is written as a list of tagged values, which is how
+// replies mixing types are sent. A *ProtocolError is written as its code
+// (2 bytes) and message, for replies carrying the outcome of several
+// commands.
+func EncodeValues(values ...interface{}) ([]byte, error) {
+	content := []byte{}
+	for _, value := range values {
+		var err error
+		content, err = appendValue(content, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return content, nil
+}
+
+func appendString(content []byte, value string) []byte {
+	content = binary.BigEndian.AppendUint64(content, uint64(len(value)))
+	return append(content, value...)
+}
+
+func appendValue(content []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		content = append(content, uint8(constants.NIL_TYPE))
+	case int64:
+		content = append(content, uint8(constants.INTEGER_TYPE))
+		content = binary.BigEndian.AppendUint64(content, uint64(v))
+	case float64:
+		content = append(content, uint8(constants.FLOAT_TYPE))
+		content = binary.BigEndian.AppendUint64(content, math.Float64bits(v))
+	case string:
+		content = append(content, uint8(constants.STRING_TYPE))
+		content = appendString(content, v)
+	case []int64:
+		content = append(content, uint8(constants.INTEGER_ARRAY_TYPE))
+		content = binary.BigEndian.AppendUint64(content, uint64(len(v)))
+		for _, item := range v {
+			content = binary.BigEndian.AppendUint64(content, uint64(item))
+		}
+	case []float64:
+		content = append(content, uint8(constants.FLOAT_ARRAY_TYPE))
+		content = binary.BigEndian.AppendUint64(content, uint64(len(v)))
+		for _, item := range v {
+			content = binary.BigEndian.AppendUint64(content, math.Float64bits(item))
+		}
+	case []string:
+		content = append(content, uint8(constants.STRING_ARRAY_TYPE))
+		content = binary.BigEndian.AppendUint64(content, uint64(len(v)))
+		for _, item := range v {
+			content = appendString(content, item)
+		}
+	case []interface{}:
+		content = append(content, uint8(constants.LIST_TYPE))
+		content = binary.BigEndian.AppendUint64(content, uint64(len(v)))
+		for _, item := range v {
+			var err error
+			content, err = appendValue(content, item)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case *ProtocolError:
+		content = append(content, uint8(constants.ERROR_TYPE))
+		content = binary.BigEndian.AppendUint16(content, v.Code)
+		content = appendString(content, v.Message)
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", value)
+	}
+	return content, nil
+}
+
+// maxListDepth bounds how deeply lists may nest, so a hostile frame cannot
+// recurse the decoder out of stack.
+const maxListDepth = 32
+
+type valueDecoder struct {
+	content []byte
+	offset  int
+	depth   int
+}
+
+func (decoder *valueDecoder) remaining() int {
+	return len(decoder.content) - decoder.offset
+}
+
+func (decoder *valueDecoder) readUint64() (uint64, error) {
+	if decoder.remaining() < 8 {
+		return 0, fmt.Errorf("expected 8 bytes at offset %d found %d", decoder.offset, decoder.remaining())
+	}
+	value := binary.BigEndian.Uint64(decoder.content[decoder.offset:])
+	decoder.offset += 8
+	return value, nil
+}
+
+// readLength reads an element count and makes sure the content can hold
+// that many elements of at least elementSize bytes, so a hostile length
+// never turns into a huge allocation.
+func (decoder *valueDecoder) readLength(elementSize int) (int, error) {
+	length, err := decoder.readUint64()
+	if err != nil {
+		return 0, err
+	}
+	if length > uint64(decoder.remaining()/elementSize) {
+		return 0, fmt.Errorf("length %d at offset %d exceeds remaining %d bytes", length, decoder.offset, decoder.remaining())
+	}
+	return int(length), nil
+}
+
+func (decoder *valueDecoder) readString() (string, error) {
+	length, err := decoder.readLength(1)
+	if err != nil {
+		return "", err
+	}
+	value := string(decoder.content[decoder.offset : decoder.offset+length])
+	decoder.offset += length
+	return value, nil
+}
+
+func (decoder *valueDecoder) readValue() (interface{}, error) {
+	if decoder.remaining() < 1 {
+		return nil, fmt.Errorf("expected type tag at offset %d", decoder.offset)
+	}
+	valueType := int64(decoder.content[decoder.offset])
+	decoder.offset++
+	switch valueType {
+	case constants.NIL_TYPE:
+		return nil, nil
+	case constants.INTEGER_TYPE:
+		value, err := decoder.readUint64()
+		return int64(value), err
+	case constants.FLOAT_TYPE:
+		value, err := decoder.readUint64()
+		return math.Float64frombits(value), err
+	case constants.STRING_TYPE:
+		return decoder.readString()
+	case constants.INTEGER_ARRAY_TYPE:
+		length, err := decoder.readLength(8)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]int64, 0, length)
+		for i := 0; i < length; i++ {
+			value, _ := decoder.readUint64()
+			values = append(values, int64(value))
+		}
+		return values, nil
+	case constants.FLOAT_ARRAY_TYPE:
+		length, err := decoder.readLength(8)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]float64, 0, length)
+		for i := 0; i < length; i++ {
+			value, _ := decoder.readUint64()
+			values = append(values, math.Float64frombits(value))
+		}
+		return values, nil
+	case constants.STRING_ARRAY_TYPE:
+		length, err := decoder.readLength(8)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]string, 0, length)
+		for i := 0; i < length; i++ {
+			value, err := decoder.readString()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case constants.LIST_TYPE:
+		if decoder.depth >= maxListDepth {
+			return nil, fmt.Errorf("lists nested deeper than %d at offset %d", maxListDepth, decoder.offset-1)
+		}
+		length, err := decoder.readLength(1)
+		if err != nil {
+			return nil, err
+		}
+		decoder.depth++
+		defer func() { decoder.depth-- }()
+		values := make([]interface{}, 0, length)
+		for i := 0; i < length; i++ {
+			value, err := decoder.readValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case constants.ERROR_TYPE:
+		if decoder.remaining() < 2 {
+			return nil, fmt.Errorf("expected error code at offset %d", decoder.offset)
+		}
+		code := binary.BigEndian.Uint16(decoder.content[decoder.offset:])
+		decoder.offset += 2
+		message, err := decoder.readString()
+		if err != nil {
+			return nil, err
+		}
+		return &ProtocolError{Code: code, Message: message}, nil
+	}
+	return nil, fmt.Errorf("unknown value type 0x%02x at offset %d", valueType, decoder.offset-1)
+}
+
+// DecodeValues is the inverse of EncodeValues. A truncated or unknown value
+// anywhere in the content is an error.
+func DecodeValues(content []byte) ([]interface{}, error) {
+	decoder := &valueDecoder{content: content}
+	values := []interface{}{}
+	for decoder.remaining() > 0 {
+		value, err := decoder.readValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
@@ -0,0 +1,190 @@
+package protocol
+
+import (
+	"in-memory-store/schemas"
+	"strings"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "VCREATE", minArgs: 3, maxArgs: 12, denyOOM: true, handler: vcreateCommand},
+		&command{name: "VDROP", minArgs: 1, maxArgs: 1, handler: vdropCommand},
+		&command{name: "VLIST", minArgs: 0, maxArgs: 0, handler: vlistCommand},
+		&command{name: "KNN", minArgs: 3, maxArgs: 8, handler: knnCommand},
+	)
+}
+
+// vectorArg reads a float or integer array argument.
+func vectorArg(args []interface{}, index int) ([]float64, error) {
+	switch v := args[index].(type) {
+	case []float64:
+		return v, nil
+	case []int64:
+		vector := make([]float64, len(v))
+		for i, component := range v {
+			vector[i] = float64(component)
+		}
+		return vector, nil
+	}
+	return nil, newProtocolError(ErrCodeInvalidArgument, "argument %d must be a numeric array, found %v", index+1, args[index])
+}
+
+// positiveArg reads an integer argument that must be above zero.
+func positiveArg(args []interface{}, index int) (int, error) {
+	value, err := integerArg(args, index)
+	if err != nil {
+		return 0, err
+	}
+	if value <= 0 {
+		return 0, newProtocolError(ErrCodeInvalidArgument, "argument %d must be positive, found %d", index+1, value)
+	}
+	return int(value), nil
+}
+
+// vcreateCommand implements VCREATE name prefix dimension [METRIC
+// COSINE|DOT|L2] [HNSW] [M n] [EF_CONSTRUCTION n] [EF_SEARCH n]. Without
+// HNSW the index answers every query with an exact scan.
+func vcreateCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	index := schemas.VectorIndex{}
+	var err error
+	if index.Name, err = stringArg(args, 0); err != nil {
+		return nil, err
+	}
+	if index.Prefix, err = stringArg(args, 1); err != nil {
+		return nil, err
+	}
+	if index.Dimension, err = positiveArg(args, 2); err != nil {
+		return nil, err
+	}
+	for i := 3; i < len(args); i++ {
+		name, err := stringArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		option := strings.ToUpper(name)
+		if option == "HNSW" {
+			index.HNSW = true
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, newProtocolError(ErrCodeInvalidArgument, "%s needs a value", name)
+		}
+		i++
+		switch option {
+		case "METRIC":
+			metric, err := stringArg(args, i)
+			if err != nil {
+				return nil, err
+			}
+			if index.Metric, err = schemas.ParseVectorMetric(metric); err != nil {
+				return nil, invalidArgument(err)
+			}
+		case "M":
+			index.M, err = positiveArg(args, i)
+		case "EF_CONSTRUCTION":
+			index.EfConstruction, err = positiveArg(args, i)
+		case "EF_SEARCH":
+			index.EfSearch, err = positiveArg(args, i)
+		default:
+			return nil, newProtocolError(ErrCodeInvalidArgument, "unknown vector index option %q", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		err := mainMap.CreateVectorIndex(index)
+		return err == nil, invalidArgument(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return "OK", nil
+}
+
+// vdropCommand implements VDROP name and replies 1 when the index existed.
+func vdropCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	name, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var dropped bool
+	server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		dropped = mainMap.DropVectorIndex(name)
+		return dropped, nil
+	})
+	if dropped {
+		return int64(1), nil
+	}
+	return int64(0), nil
+}
+
+// vlistCommand replies with one [name, prefix, dimension, metric,
+// algorithm, size] entry per index, size being -1 for exact indexes.
+func vlistCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	entries := []interface{}{}
+	server.read(session, func(mainMap *schemas.MainMap) {
+		for _, index := range mainMap.VectorIndexes() {
+			algorithm := "FLAT"
+			if index.HNSW {
+				algorithm = "HNSW"
+			}
+			entries = append(entries, []interface{}{
+				index.Name, index.Prefix, int64(index.Dimension), index.Metric.String(), algorithm, int64(index.Size()),
+			})
+		}
+	})
+	return entries, nil
+}
+
+// knnCommand implements KNN name k vector [FILTER prefix] [EXACT] [EF n]
+// and replies with key, distance pairs, nearest first.
+func knnCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	name, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	k, err := integerArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	query, err := vectorArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	options := schemas.VectorQuery{}
+	for i := 3; i < len(args); i++ {
+		option, err := stringArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case strings.EqualFold(option, "EXACT"):
+			options.Exact = true
+		case strings.EqualFold(option, "FILTER") && i+1 < len(args):
+			if options.Filter, err = stringArg(args, i+1); err != nil {
+				return nil, err
+			}
+			i++
+		case strings.EqualFold(option, "EF") && i+1 < len(args):
+			if options.EfSearch, err = positiveArg(args, i+1); err != nil {
+				return nil, err
+			}
+			i++
+		default:
+			return nil, newProtocolError(ErrCodeInvalidArgument, "unexpected argument %q", option)
+		}
+	}
+	var matches []schemas.VectorMatch
+	server.read(session, func(mainMap *schemas.MainMap) {
+		matches, err = mainMap.VectorSearch(name, query, int(k), options)
+	})
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	pairs := make([]interface{}, 0, 2*len(matches))
+	for _, match := range matches {
+		pairs = append(pairs, match.Key, match.Distance)
+	}
+	return pairs, nil
+}
@@ -0,0 +1,95 @@
+package protocol
+
+import (
+	"in-memory-store/schemas"
+)
+
+func init() {
+	registerCommands(
+		&command{name: "VERSION", minArgs: 1, maxArgs: 1, handler: versionCommand},
+		&command{name: "GETV", minArgs: 1, maxArgs: 1, handler: getvCommand},
+		&command{name: "SETCAS", minArgs: 3, maxArgs: 3, denyOOM: true, handler: setcasCommand},
+		&command{name: "DELCAS", minArgs: 2, maxArgs: 2, handler: delcasCommand},
+	)
+}
+
+// versionCommand replies with the version of a key, 0 when it is absent.
+func versionCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var version int64
+	server.read(session, func(mainMap *schemas.MainMap) {
+		version = mainMap.Version(key)
+	})
+	return version, nil
+}
+
+// getvCommand replies with [value, version] read together.
+func getvCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	var version int64
+	server.read(session, func(mainMap *schemas.MainMap) {
+		value, version = mainMap.GetValue(key), mainMap.Version(key)
+	})
+	return []interface{}{value, version}, nil
+}
+
+// setcasCommand implements SETCAS key value version. The value is stored
+// only when the key is at version, 0 meaning absent, and the reply is the
+// new version, or nil when the version did not match.
+func setcasCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	version, err := integerArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	var stored bool
+	err = server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		version, stored, err = mainMap.SetIfVersion(key, args[1], version)
+		if err != nil {
+			return false, invalidArgument(err)
+		}
+		if stored {
+			server.waiters.serve(mainMap, key)
+		}
+		return stored, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !stored {
+		return nil, nil
+	}
+	return version, nil
+}
+
+// delcasCommand implements DELCAS key version and replies 1 when the key
+// was at version and is deleted.
+func delcasCommand(server *Server, session *clientSession, args []interface{}) (interface{}, error) {
+	key, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	version, err := integerArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var deleted bool
+	server.write(session, func(mainMap *schemas.MainMap) (bool, error) {
+		deleted = mainMap.DeleteIfVersion(key, version)
+		return deleted, nil
+	})
+	if deleted {
+		return int64(1), nil
+	}
+	return int64(0), nil
+}
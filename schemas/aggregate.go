@@ -0,0 +1,164 @@
+package schemas
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// ArrayStats summarizes the numbers of an integer or float array. Sum,
+// Min and Max have the array's element type; Min, Max, Mean and Variance
+// are nil for an empty range.
+type ArrayStats struct {
+	Count    int
+	Sum      interface{}
+	Min      interface{}
+	Max      interface{}
+	Mean     interface{}
+	Variance interface{}
+}
+
+// int128 accumulates integer sums that may pass the int64 range on the way.
+type int128 struct {
+	hi int64
+	lo uint64
+}
+
+func (sum *int128) add(value int64) {
+	var carry uint64
+	sum.lo, carry = bits.Add64(sum.lo, uint64(value), 0)
+	// a negative value is sign extended into the high word
+	sum.hi += int64(carry) + value>>63
+}
+
+func (sum int128) int64() (int64, bool) {
+	fits := (sum.hi == 0 && sum.lo < 1<<63) || (sum.hi == -1 && sum.lo >= 1<<63)
+	return int64(sum.lo), fits
+}
+
+func (sum int128) float64() float64 {
+	return float64(sum.hi)*math.Exp2(64) + float64(sum.lo)
+}
+
+// numbersAt returns the elements from start to stop inclusive of the
+// integer or float array at key, clamped like ListRange. Exactly one of
+// the results is non nil unless the key is absent.
+func (m *MainMap) numbersAt(key string, start int, stop int) ([]int64, []float64, error) {
+	if integers, ok := m.INTEGER_ARRAY_MAP[key]; ok {
+		from, to := normalizeRange(start, stop, len(integers))
+		return integers[from:to], nil, nil
+	}
+	if floats, ok := m.FLOAT_ARRAY_MAP[key]; ok {
+		from, to := normalizeRange(start, stop, len(floats))
+		return nil, floats[from:to], nil
+	}
+	if found := m.TypeOf(key); found != "none" {
+		return nil, nil, fmt.Errorf("%w: %s is %s, not a numeric array", ErrWrongType, key, found)
+	}
+	return nil, nil, nil
+}
+
+// meanAndVariance computes the mean and population variance with
+// Welford's method.
+func meanAndVariance(count int, value func(i int) float64) (float64, float64) {
+	mean, squares := 0.0, 0.0
+	for i := 0; i < count; i++ {
+		delta := value(i) - mean
+		mean += delta / float64(i+1)
+		squares += delta * (value(i) - mean)
+	}
+	return mean, squares / float64(count)
+}
+
+// ArrayStats computes count, sum, min, max, mean and variance over the
+// elements from start to stop inclusive of a numeric array; use 0 and -1
+// for the whole array. Integer sums are exact and ErrOverflow is returned
+// when the total does not fit in int64, float sums are compensated.
+func (m *MainMap) ArrayStats(key string, start int, stop int) (*ArrayStats, error) {
+	integers, floats, err := m.numbersAt(key, start, stop)
+	if err != nil {
+		return nil, err
+	}
+	if floats != nil {
+		return floatStats(floats), nil
+	}
+	stats := &ArrayStats{Count: len(integers), Sum: int64(0)}
+	if len(integers) == 0 {
+		return stats, nil
+	}
+	var sum int128
+	minimum, maximum := integers[0], integers[0]
+	for _, value := range integers {
+		sum.add(value)
+		minimum = min(minimum, value)
+		maximum = max(maximum, value)
+	}
+	total, fits := sum.int64()
+	if !fits {
+		return nil, fmt.Errorf("%w: sum of %s is %g", ErrOverflow, key, sum.float64())
+	}
+	_, variance := meanAndVariance(len(integers), func(i int) float64 { return float64(integers[i]) })
+	stats.Sum, stats.Min, stats.Max = total, minimum, maximum
+	stats.Mean = sum.float64() / float64(len(integers))
+	stats.Variance = variance
+	return stats, nil
+}
+
+func floatStats(floats []float64) *ArrayStats {
+	stats := &ArrayStats{Count: len(floats), Sum: 0.0}
+	if len(floats) == 0 {
+		return stats
+	}
+	// Neumaier's compensated sum keeps the error independent of the length
+	sum, compensation := 0.0, 0.0
+	minimum, maximum := floats[0], floats[0]
+	for _, value := range floats {
+		total := sum + value
+		if math.Abs(sum) >= math.Abs(value) {
+			compensation += (sum - total) + value
+		} else {
+			compensation += (value - total) + sum
+		}
+		sum = total
+		minimum = math.Min(minimum, value)
+		maximum = math.Max(maximum, value)
+	}
+	mean, variance := meanAndVariance(len(floats), func(i int) float64 { return floats[i] })
+	stats.Sum, stats.Min, stats.Max = sum+compensation, minimum, maximum
+	stats.Mean, stats.Variance = mean, variance
+	return stats
+}
+
+// ArrayPercentiles returns the given percentiles, each between 0 and 100,
+// of the elements from start to stop inclusive of a numeric array,
+// interpolating linearly between the closest ranks. It returns nil for an
+// empty range.
+func (m *MainMap) ArrayPercentiles(key string, start int, stop int, percentiles []float64) ([]float64, error) {
+	for _, percentile := range percentiles {
+		if !(percentile >= 0 && percentile <= 100) {
+			return nil, fmt.Errorf("percentile must be between 0 and 100, found %v", percentile)
+		}
+	}
+	integers, floats, err := m.numbersAt(key, start, stop)
+	if err != nil {
+		return nil, err
+	}
+	sorted := make([]float64, 0, len(integers)+len(floats))
+	for _, value := range integers {
+		sorted = append(sorted, float64(value))
+	}
+	sorted = append(sorted, floats...)
+	if len(sorted) == 0 {
+		return nil, nil
+	}
+	sort.Float64s(sorted)
+	results := make([]float64, 0, len(percentiles))
+	for _, percentile := range percentiles {
+		rank := percentile / 100 * float64(len(sorted)-1)
+		lower := int(math.Floor(rank))
+		upper := int(math.Ceil(rank))
+		results = append(results, sorted[lower]+(sorted[upper]-sorted[lower])*(rank-float64(lower)))
+	}
+	return results, nil
+}
@@ -0,0 +1,78 @@
+package schemas
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestIntegerArrayStats(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.SetIntegerArray("a", []int64{2, 4, 4, 4, 5, 5, 7, 9})
+	stats, err := mainMap.ArrayStats("a", 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &ArrayStats{Count: 8, Sum: int64(40), Min: int64(2), Max: int64(9), Mean: 5.0, Variance: 4.0}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Fatalf("expected %+v, found %+v", expected, stats)
+	}
+	// ranges are clamped like list ranges
+	if stats, _ := mainMap.ArrayStats("a", -2, 100); stats.Count != 2 || stats.Sum != int64(16) {
+		t.Fatalf("expected the last two elements, found %+v", stats)
+	}
+	if stats, _ := mainMap.ArrayStats("missing", 0, -1); stats.Count != 0 || stats.Min != nil || stats.Mean != nil {
+		t.Fatalf("expected empty stats for an absent key, found %+v", stats)
+	}
+}
+
+func TestIntegerSumsAreExact(t *testing.T) {
+	mainMap := CreateMainMap()
+	// the running sum passes MaxInt64 but the total fits
+	mainMap.SetIntegerArray("a", []int64{math.MaxInt64, 1, -2})
+	if stats, err := mainMap.ArrayStats("a", 0, -1); err != nil || stats.Sum != int64(math.MaxInt64-1) {
+		t.Fatalf("expected the sum %d, found %+v, %v", int64(math.MaxInt64-1), stats, err)
+	}
+	mainMap.SetIntegerArray("b", []int64{math.MinInt64, -1})
+	if _, err := mainMap.ArrayStats("b", 0, -1); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("expected an overflow, found %v", err)
+	}
+}
+
+func TestFloatArrayStats(t *testing.T) {
+	mainMap := CreateMainMap()
+	// a naive sum loses the ones next to 1e100
+	mainMap.SetFloatArray("f", []float64{1, 1e100, 1, -1e100})
+	stats, err := mainMap.ArrayStats("f", 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sum != 2.0 || stats.Min != -1e100 || stats.Max != 1e100 {
+		t.Fatalf("expected a compensated sum of 2, found %+v", stats)
+	}
+	mainMap.SetString("s", "text")
+	if _, err := mainMap.ArrayStats("s", 0, -1); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("expected a wrong type error, found %v", err)
+	}
+}
+
+func TestArrayPercentiles(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.SetIntegerArray("a", []int64{40, 10, 30, 20})
+	percentiles, err := mainMap.ArrayPercentiles("a", 0, -1, []float64{0, 50, 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(percentiles, []float64{10, 25, 40}) {
+		t.Fatalf("expected [10 25 40], found %v", percentiles)
+	}
+	for _, percentile := range []float64{-1, 101, math.NaN()} {
+		if _, err := mainMap.ArrayPercentiles("a", 0, -1, []float64{percentile}); err == nil {
+			t.Fatalf("expected percentile %v to be rejected", percentile)
+		}
+	}
+	if percentiles, _ := mainMap.ArrayPercentiles("missing", 0, -1, []float64{50}); percentiles != nil {
+		t.Fatalf("expected no percentiles of an empty range, found %v", percentiles)
+	}
+}
@@ -0,0 +1,183 @@
+package schemas
+
+import (
+	"go.uber.org/zap"
+	"sort"
+	"strconv"
+)
+
+// Databases holds the independent MainMaps of a server by name. Numbered
+// databases are named "0", "1" and so on. The set of databases is fixed
+// once the server starts serving, so it needs no lock of its own.
+type Databases struct {
+	maps map[string]*MainMap
+}
+
+// NewDatabases creates count numbered databases and one per name.
+func NewDatabases(count int, names []string) *Databases {
+	databases := &Databases{maps: make(map[string]*MainMap, count+len(names))}
+	for i := 0; i < count; i++ {
+		databases.Open(strconv.Itoa(i))
+	}
+	for _, name := range names {
+		databases.Open(name)
+	}
+	return databases
+}
+
+// Get returns the database called name and whether it exists.
+func (databases *Databases) Get(name string) (*MainMap, bool) {
+	mainMap, ok := databases.maps[name]
+	return mainMap, ok
+}
+
+// Open returns the database called name, creating it when absent. It must
+// not be called once the databases are shared.
+func (databases *Databases) Open(name string) *MainMap {
+	mainMap, ok := databases.maps[name]
+	if !ok {
+		mainMap = CreateMainMap()
+		databases.maps[name] = mainMap
+	}
+	return mainMap
+}
+
+// Names returns the databases in lock order, see Less.
+func (databases *Databases) Names() []string {
+	names := make([]string, 0, len(databases.maps))
+	for name := range databases.maps {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return databases.Less(names[i], names[j]) })
+	return names
+}
+
+// Less orders numbered databases by number before named ones sorted by
+// name. Code holding several database locks takes them in this order.
+func (databases *Databases) Less(left string, right string) bool {
+	leftNumber, leftErr := strconv.Atoi(left)
+	rightNumber, rightErr := strconv.Atoi(right)
+	switch {
+	case leftErr == nil && rightErr == nil:
+		return leftNumber < rightNumber
+	case leftErr == nil || rightErr == nil:
+		return leftErr == nil
+	}
+	return left < right
+}
+
+// LockAll write locks every database in lock order.
+func (databases *Databases) LockAll() {
+	for _, name := range databases.Names() {
+		databases.maps[name].Lock()
+	}
+}
+
+func (databases *Databases) UnlockAll() {
+	for _, name := range databases.Names() {
+		databases.maps[name].Unlock()
+	}
+}
+
+// RLockAll read locks every database in lock order.
+func (databases *Databases) RLockAll() {
+	for _, name := range databases.Names() {
+		databases.maps[name].RLock()
+	}
+}
+
+func (databases *Databases) RUnlockAll() {
+	for _, name := range databases.Names() {
+		databases.maps[name].RUnlock()
+	}
+}
+
+// KeyCount returns how many keys the database holds.
+func (m *MainMap) KeyCount() int {
+	return len(m.INTEGER_MAP) + len(m.INTEGER_ARRAY_MAP) + len(m.STRING_MAP) + len(m.STRING_ARRAY_MAP) +
+		len(m.FLOAT_MAP) + len(m.FLOAT_ARRAY_MAP) + len(m.SET_MAP) + len(m.HASH_MAP) + len(m.SORTED_SET_MAP)
+}
+
+// CopyTo stores a copy of the value at key under destination in target,
+// which may be m itself. An existing destination is only replaced with
+// replace. It reports whether the value was copied.
+func (m *MainMap) CopyTo(key string, target *MainMap, destination string, replace bool) bool {
+	typeName := m.TypeOf(key)
+	if typeName == "none" || (m == target && key == destination) {
+		return false
+	}
+	if target.TypeOf(destination) != "none" {
+		if !replace {
+			return false
+		}
+		target.removeValue(destination)
+	}
+	switch typeName {
+	case "string":
+		target.SetString(destination, m.STRING_MAP[key])
+	case "string_array":
+		target.SetStringArray(destination, append([]string{}, m.STRING_ARRAY_MAP[key]...))
+	case "integer":
+		target.SetInteger(destination, m.INTEGER_MAP[key])
+	case "integer_array":
+		target.SetIntegerArray(destination, append([]int64{}, m.INTEGER_ARRAY_MAP[key]...))
+	case "float":
+		target.SetFloat(destination, m.FLOAT_MAP[key])
+	case "float_array":
+		target.SetFloatArray(destination, append([]float64{}, m.FLOAT_ARRAY_MAP[key]...))
+	case "set":
+		target.SetSet(destination, sortedMembers(m.SET_MAP[key]))
+	case "hash":
+		fields := make(map[string]interface{}, len(m.HASH_MAP[key]))
+		for field, value := range m.HASH_MAP[key] {
+			fields[field] = value
+		}
+		target.SetHash(destination, fields)
+	case "sorted_set":
+		set := NewSortedSet()
+		for _, scored := range m.SORTED_SET_MAP[key].Members() {
+			set.Add(scored.Member, scored.Score)
+		}
+		target.SetSortedSet(destination, set)
+	}
+	return true
+}
+
+// Flush removes every key and returns how many there were. Index
+// definitions and the version clock are kept.
+func (m *MainMap) Flush() int {
+	count := m.KeyCount()
+	for key := range m.watches {
+		if m.TypeOf(key) != "none" {
+			m.notifyWatches(key)
+		}
+	}
+	m.INTEGER_MAP = make(map[string]int64)
+	m.STRING_MAP = make(map[string]string)
+	m.INTEGER_ARRAY_MAP = make(map[string][]int64)
+	m.STRING_ARRAY_MAP = make(map[string][]string)
+	m.FLOAT_MAP = make(map[string]float64)
+	m.FLOAT_ARRAY_MAP = make(map[string][]float64)
+	m.SET_MAP = make(map[string]map[string]struct{})
+	m.HASH_MAP = make(map[string]map[string]interface{})
+	m.SORTED_SET_MAP = make(map[string]*SortedSet)
+	m.versions = make(map[string]int64)
+	if m.KEY_INDEX != nil {
+		m.KEY_INDEX = NewRadixTree()
+	}
+	// recreating the indexes over the empty maps empties them
+	for _, index := range m.VectorIndexes() {
+		delete(m.VECTOR_INDEXES, index.Name)
+		m.CreateVectorIndex(*index)
+	}
+	for _, index := range m.RangeIndexes() {
+		delete(m.RANGE_INDEXES, index.Name)
+		m.CreateRangeIndex(index.Name, index.Prefix, index.Type)
+	}
+	for _, index := range m.TextIndexes() {
+		delete(m.TEXT_INDEXES, index.Name)
+		m.CreateTextIndex(index.Name, index.Prefixes)
+	}
+	zap.L().Info("Flushing database", zap.Int("keys", count))
+	return count
+}
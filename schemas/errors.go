@@ -0,0 +1,35 @@
+package schemas
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrWrongType is returned when an operation finds the key in a map of
+	// another type.
+	ErrWrongType = errors.New("key holds a value of another type")
+	// ErrOverflow is returned when an integer result does not fit in int64.
+	ErrOverflow = errors.New("integer overflow")
+	// ErrNotFinite is returned when a float result would be NaN or infinite.
+	ErrNotFinite = errors.New("result is not a finite number")
+	// ErrNoSuchKey is returned when an operation needs an existing key.
+	ErrNoSuchKey = errors.New("no such key")
+	// ErrIndexOutOfRange is returned for array indexes past either end.
+	ErrIndexOutOfRange = errors.New("index out of range")
+	// ErrIndexExists is returned when creating an index under a taken name.
+	ErrIndexExists = errors.New("index already exists")
+	// ErrNoSuchIndex is returned when a named index is not found.
+	ErrNoSuchIndex = errors.New("no such index")
+	// ErrDimensionMismatch is returned for vectors of the wrong length.
+	ErrDimensionMismatch = errors.New("vector dimension mismatch")
+)
+
+// checkType returns ErrWrongType when key exists with a type other than
+// want, as named by TypeOf.
+func (m *MainMap) checkType(key string, want string) error {
+	if found := m.TypeOf(key); found != "none" && found != want {
+		return fmt.Errorf("%w: %s is %s, not %s", ErrWrongType, key, found, want)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package schemas
+
+import (
+	"container/heap"
+	"encoding/base64"
+	"fmt"
+)
+
+// matchGlob reports whether text matches a redis style glob pattern: *
+// matches any run of bytes, ? a single byte, [abc], [^abc] and [a-z] a
+// byte from a class, and \ escapes the next byte. Only the last * is
+// backtracked to, letting it take one more byte each time, so matching
+// takes O(len(pattern) * len(text)).
+func matchGlob(pattern string, text string) bool {
+	p, t := 0, 0
+	star, starText := -1, 0
+	for t < len(text) {
+		if p < len(pattern) && pattern[p] == '*' {
+			star, starText = p, t
+			p++
+			continue
+		}
+		if p < len(pattern) {
+			if width, matched := matchToken(pattern[p:], text[t]); matched {
+				p, t = p+width, t+1
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		starText++
+		p, t = star+1, starText
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchToken matches c against the token pattern starts with, which is
+// not a *, and returns how many bytes of pattern the token takes.
+func matchToken(pattern string, c byte) (int, bool) {
+	switch pattern[0] {
+	case '?':
+		return 1, true
+	case '[':
+		matched, rest, ok := matchClass(pattern[1:], c)
+		if !ok {
+			// an unterminated class is matched literally
+			return 1, c == '['
+		}
+		return len(pattern) - len(rest), matched
+	case '\\':
+		if len(pattern) > 1 {
+			return 2, pattern[1] == c
+		}
+	}
+	return 1, pattern[0] == c
+}
+
+// matchClass matches c against the class starting after '[' and returns
+// the pattern after the closing ']'. ok is false when there is none.
+func matchClass(class string, c byte) (matched bool, rest string, ok bool) {
+	negate := false
+	if len(class) > 0 && class[0] == '^' {
+		negate, class = true, class[1:]
+	}
+	for i := 0; i < len(class); i++ {
+		switch {
+		case class[i] == ']' && i > 0:
+			return matched != negate, class[i+1:], true
+		case class[i] == '\\' && i+1 < len(class):
+			i++
+			matched = matched || class[i] == c
+		case i+2 < len(class) && class[i+1] == '-' && class[i+2] != ']':
+			low, high := class[i], class[i+2]
+			if low > high {
+				low, high = high, low
+			}
+			matched = matched || (c >= low && c <= high)
+			i += 2
+		default:
+			matched = matched || class[i] == c
+		}
+	}
+	return false, "", false
+}
+
+// ScanStart is the cursor that starts a scan and the one returned once it
+// is complete.
+const ScanStart = "0"
+
+// encodeCursor turns the last name a scan returned into an opaque cursor.
+// Names are iterated in sorted order and a scan resumes after the cursor,
+// so names present for the whole scan are returned exactly once.
+func encodeCursor(last string) string {
+	return "c" + base64.RawURLEncoding.EncodeToString([]byte(last))
+}
+
+func decodeCursor(cursor string) (string, bool, error) {
+	if cursor == ScanStart {
+		return "", true, nil
+	}
+	if len(cursor) == 0 || cursor[0] != 'c' {
+		return "", false, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	last, err := base64.RawURLEncoding.DecodeString(cursor[1:])
+	if err != nil {
+		return "", false, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return string(last), false, nil
+}
+
+// nameHeap is a max heap of names.
+type nameHeap []string
+
+func (h nameHeap) Len() int            { return len(h) }
+func (h nameHeap) Less(i, j int) bool  { return h[i] > h[j] }
+func (h nameHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *nameHeap) Push(x interface{}) { *h = append(*h, x.(string)) }
+func (h *nameHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// scanPage examines up to count of names, in sorted order after cursor,
+// and returns those matching pattern together with the cursor to continue
+// from, ScanStart once every name has been examined. An empty pattern
+// matches everything.
+func scanPage(names []string, cursor string, pattern string, count int) ([]string, string, error) {
+	last, fromStart, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	if count <= 0 {
+		return nil, "", fmt.Errorf("count must be positive, found %d", count)
+	}
+	// keep the count smallest names after the cursor in a max heap so a
+	// page costs O(n log count) rather than sorting every name
+	candidates := &nameHeap{}
+	more := false
+	for _, name := range names {
+		if !fromStart && name <= last {
+			continue
+		}
+		if candidates.Len() < count {
+			heap.Push(candidates, name)
+			continue
+		}
+		more = true
+		if name < (*candidates)[0] {
+			(*candidates)[0] = name
+			heap.Fix(candidates, 0)
+		}
+	}
+	page := make([]string, candidates.Len())
+	for i := len(page) - 1; i >= 0; i-- {
+		page[i] = heap.Pop(candidates).(string)
+	}
+	next := ScanStart
+	if more {
+		next = encodeCursor(page[len(page)-1])
+	}
+	return matchingNames(page, pattern), next, nil
+}
+
+// matchingNames keeps the names matching pattern, all of them when it is
+// empty.
+func matchingNames(names []string, pattern string) []string {
+	matched := []string{}
+	for _, name := range names {
+		if pattern == "" || matchGlob(pattern, name) {
+			matched = append(matched, name)
+		}
+	}
+	return matched
+}
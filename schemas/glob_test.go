@@ -0,0 +1,57 @@
+package schemas
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, text string
+		matches       bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"user:*", "user:1", true},
+		{"user:*", "users:1", false},
+		{"*:name", "user:1:name", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[c-a]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{`[\]]`, "]", true},
+		{"[]]", "]", true},
+		{"a[b", "a[b", true},
+		{"a[b", "ab", false},
+		{"a*b*c", "aXbYc", true},
+		{"a*b*c", "aXbY", false},
+		{"**a", "ba", true},
+		{"", "", true},
+		{"", "a", false},
+	}
+	for _, test := range tests {
+		if matches := matchGlob(test.pattern, test.text); matches != test.matches {
+			t.Errorf("%q against %q: expected %v, found %v", test.pattern, test.text, test.matches, matches)
+		}
+	}
+}
+
+func TestMatchGlobDoesNotBacktrackExponentially(t *testing.T) {
+	pattern := strings.Repeat("a*", 30) + "b"
+	text := strings.Repeat("a", 10000)
+	start := time.Now()
+	if matchGlob(pattern, text) {
+		t.Fatal("expected no match without a b")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("matching took %s", elapsed)
+	}
+}
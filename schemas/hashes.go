@@ -0,0 +1,175 @@
+package schemas
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"sort"
+)
+
+// flattenFields returns the fields of a hash as field, value pairs sorted
+// by field.
+func flattenFields(hash map[string]interface{}) []interface{} {
+	fields := make([]string, 0, len(hash))
+	for field := range hash {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	pairs := make([]interface{}, 0, 2*len(fields))
+	for _, field := range fields {
+		pairs = append(pairs, field, hash[field])
+	}
+	return pairs
+}
+
+// checkFieldValue accepts the value types a hash field can hold.
+func checkFieldValue(field string, value interface{}) error {
+	switch value.(type) {
+	case string, int64, float64:
+		return nil
+	}
+	return fmt.Errorf("%w: field %s must be a string, integer or float, found %T", ErrWrongType, field, value)
+}
+
+// hashAt returns the hash stored at key, nil when the key is absent.
+func (m *MainMap) hashAt(key string) (map[string]interface{}, error) {
+	if err := m.checkType(key, "hash"); err != nil {
+		return nil, err
+	}
+	return m.HASH_MAP[key], nil
+}
+
+// HashSet sets fields of the hash at key, creating it when absent, and
+// returns how many fields are new. Values are strings, integers or floats.
+func (m *MainMap) HashSet(key string, fields map[string]interface{}) (int, error) {
+	hash, err := m.hashAt(key)
+	if err != nil {
+		return 0, err
+	}
+	for field, value := range fields {
+		if err := checkFieldValue(field, value); err != nil {
+			return 0, err
+		}
+	}
+	if hash == nil {
+		hash = make(map[string]interface{}, len(fields))
+		m.HASH_MAP[key] = hash
+	}
+	added := 0
+	for field, value := range fields {
+		if _, ok := hash[field]; !ok {
+			added++
+		}
+		hash[field] = value
+	}
+	zap.L().Info("Setting Hash fields", zap.String("key", key), zap.Int("fields", len(fields)))
+	m.touched(key)
+	return added, nil
+}
+
+// HashGet returns the value of field, nil when the field or key is absent.
+func (m *MainMap) HashGet(key string, field string) (interface{}, error) {
+	hash, err := m.hashAt(key)
+	if err != nil {
+		return nil, err
+	}
+	return hash[field], nil
+}
+
+// HashGetMany returns the values of fields in order, nil for absent ones.
+func (m *MainMap) HashGetMany(key string, fields ...string) ([]interface{}, error) {
+	hash, err := m.hashAt(key)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		values = append(values, hash[field])
+	}
+	return values, nil
+}
+
+// HashGetAll returns every field, value pair of the hash sorted by field.
+func (m *MainMap) HashGetAll(key string) ([]interface{}, error) {
+	hash, err := m.hashAt(key)
+	if err != nil {
+		return nil, err
+	}
+	return flattenFields(hash), nil
+}
+
+// HashDelete removes fields and returns how many existed. A hash left
+// empty is deleted.
+func (m *MainMap) HashDelete(key string, fields ...string) (int, error) {
+	hash, err := m.hashAt(key)
+	if err != nil || hash == nil {
+		return 0, err
+	}
+	removed := 0
+	for _, field := range fields {
+		if _, ok := hash[field]; ok {
+			delete(hash, field)
+			removed++
+		}
+	}
+	if len(hash) == 0 {
+		delete(m.HASH_MAP, key)
+	}
+	zap.L().Info("Deleting Hash fields", zap.String("key", key), zap.Int("removed", removed))
+	if removed > 0 {
+		m.touched(key)
+	}
+	return removed, nil
+}
+
+func (m *MainMap) HashLength(key string) (int, error) {
+	hash, err := m.hashAt(key)
+	return len(hash), err
+}
+
+// HashIncrement adds delta to the integer in field, starting from zero
+// when the field is absent, and returns the new value.
+func (m *MainMap) HashIncrement(key string, field string, delta int64) (int64, error) {
+	hash, err := m.hashAt(key)
+	if err != nil {
+		return 0, err
+	}
+	current, ok := hash[field].(int64)
+	if !ok && hash[field] != nil {
+		return 0, fmt.Errorf("%w: field %s of %s is %T, not an integer", ErrWrongType, field, key, hash[field])
+	}
+	result := current + delta
+	if (delta > 0 && result < current) || (delta < 0 && result > current) {
+		return 0, fmt.Errorf("%w: %d + %d", ErrOverflow, current, delta)
+	}
+	if hash == nil {
+		hash = map[string]interface{}{}
+		m.HASH_MAP[key] = hash
+	}
+	zap.L().Info("Incrementing Hash field", zap.String("key", key), zap.String("field", field), zap.Int64("value", result))
+	hash[field] = result
+	m.touched(key)
+	return result, nil
+}
+
+// HashScan returns field, value pairs of up to count fields after cursor
+// whose names match pattern, and the cursor to continue from. Start with
+// ScanStart; ScanStart is returned again once every field was examined.
+func (m *MainMap) HashScan(key string, cursor string, pattern string, count int) ([]interface{}, string, error) {
+	hash, err := m.hashAt(key)
+	if err != nil {
+		return nil, "", err
+	}
+	fields := make([]string, 0, len(hash))
+	for field := range hash {
+		fields = append(fields, field)
+	}
+	matched, next, err := scanPage(fields, cursor, pattern, count)
+	if err != nil {
+		return nil, "", err
+	}
+	pairs := make([]interface{}, 0, 2*len(matched))
+	for _, field := range matched {
+		pairs = append(pairs, field, hash[field])
+	}
+	return pairs, next, nil
+}
@@ -0,0 +1,85 @@
+package schemas
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestHashSetAndGet(t *testing.T) {
+	mainMap := CreateMainMap()
+	added, err := mainMap.HashSet("h", map[string]interface{}{"name": "x", "count": int64(1), "ratio": 0.5})
+	if err != nil || added != 3 {
+		t.Fatalf("expected 3 new fields, found %d, %v", added, err)
+	}
+	if added, _ := mainMap.HashSet("h", map[string]interface{}{"name": "y", "other": "z"}); added != 1 {
+		t.Fatalf("expected 1 new field, found %d", added)
+	}
+	if values, _ := mainMap.HashGetMany("h", "name", "missing"); !reflect.DeepEqual(values, []interface{}{"y", nil}) {
+		t.Fatalf("expected [y <nil>], found %v", values)
+	}
+	expected := []interface{}{"count", int64(1), "name", "y", "other", "z", "ratio", 0.5}
+	if pairs, _ := mainMap.HashGetAll("h"); !reflect.DeepEqual(pairs, expected) {
+		t.Fatalf("expected the pairs sorted by field %v, found %v", expected, pairs)
+	}
+	// a field of an unsupported type rejects the whole call
+	if _, err := mainMap.HashSet("h", map[string]interface{}{"a": "ok", "b": []int64{1}}); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("expected a wrong type error, found %v", err)
+	}
+	if value, _ := mainMap.HashGet("h", "a"); value != nil {
+		t.Fatalf("expected a rejected call to set nothing, found %v", value)
+	}
+}
+
+func TestHashDelete(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.HashSet("h", map[string]interface{}{"a": "1", "b": "2"})
+	if removed, _ := mainMap.HashDelete("h", "a", "missing"); removed != 1 {
+		t.Fatalf("expected 1 removed field, found %d", removed)
+	}
+	if length, _ := mainMap.HashLength("h"); length != 1 {
+		t.Fatalf("expected 1 field left, found %d", length)
+	}
+	mainMap.HashDelete("h", "b")
+	if kind := mainMap.TypeOf("h"); kind != "none" {
+		t.Fatalf("expected an emptied hash to be deleted, found a %s", kind)
+	}
+}
+
+func TestHashIncrement(t *testing.T) {
+	mainMap := CreateMainMap()
+	if value, err := mainMap.HashIncrement("h", "n", 3); err != nil || value != 3 {
+		t.Fatalf("expected an absent field to start from 0, found %d, %v", value, err)
+	}
+	mainMap.HashSet("h", map[string]interface{}{"text": "x", "max": int64(math.MaxInt64)})
+	if _, err := mainMap.HashIncrement("h", "text", 1); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("expected a wrong type error, found %v", err)
+	}
+	if _, err := mainMap.HashIncrement("h", "max", 1); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("expected an overflow, found %v", err)
+	}
+	if value, _ := mainMap.HashGet("h", "max"); value != int64(math.MaxInt64) {
+		t.Fatalf("an overflowing increment changed the field to %v", value)
+	}
+}
+
+func TestHashScan(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.HashSet("h", map[string]interface{}{"a:1": "x", "a:2": "y", "b:1": "z"})
+	pairs := []interface{}{}
+	cursor := ScanStart
+	for pages := 0; pages < 10; pages++ {
+		page, next, err := mainMap.HashScan("h", cursor, "a:*", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pairs = append(pairs, page...)
+		if cursor = next; cursor == ScanStart {
+			break
+		}
+	}
+	if expected := []interface{}{"a:1", "x", "a:2", "y"}; !reflect.DeepEqual(pairs, expected) {
+		t.Fatalf("expected %v, found %v", expected, pairs)
+	}
+}
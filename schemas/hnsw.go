@@ -0,0 +1,244 @@
+package schemas
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+)
+
+// hnswGraph is a hierarchical navigable small world graph for approximate
+// nearest neighbour search. Removed or replaced vectors are tombstoned:
+// they keep routing searches but are never returned, and the graph is
+// rebuilt once tombstones outnumber live nodes.
+type hnswGraph struct {
+	nodes          []*hnswNode
+	ids            map[string]int
+	entry          int
+	maxLevel       int
+	m              int
+	efConstruction int
+	levelFactor    float64
+	distance       func(a []float64, b []float64) float64
+	deleted        int
+	random         *rand.Rand
+}
+
+type hnswNode struct {
+	key     string
+	vector  []float64
+	deleted bool
+	// neighbors holds the ids linked to on every level the node is on
+	neighbors [][]int
+}
+
+func newHNSWGraph(m int, efConstruction int, distance func(a []float64, b []float64) float64) *hnswGraph {
+	return &hnswGraph{
+		ids:            map[string]int{},
+		entry:          -1,
+		m:              m,
+		efConstruction: efConstruction,
+		levelFactor:    1 / math.Log(float64(m)),
+		distance:       distance,
+		random:         rand.New(rand.NewSource(rand.Int63())),
+	}
+}
+
+type candidate struct {
+	id       int
+	distance float64
+}
+
+// candidateHeap is a min heap on distance, or a max heap when farthest.
+type candidateHeap struct {
+	items    []candidate
+	farthest bool
+}
+
+func (h *candidateHeap) Len() int { return len(h.items) }
+func (h *candidateHeap) Less(i, j int) bool {
+	if h.farthest {
+		return h.items[i].distance > h.items[j].distance
+	}
+	return h.items[i].distance < h.items[j].distance
+}
+func (h *candidateHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *candidateHeap) Push(x interface{}) { h.items = append(h.items, x.(candidate)) }
+func (h *candidateHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// maxNeighbors is twice m on the bottom level, which holds every node.
+func (graph *hnswGraph) maxNeighbors(level int) int {
+	if level == 0 {
+		return 2 * graph.m
+	}
+	return graph.m
+}
+
+// searchLayer returns up to ef nodes of level closest to query, nearest
+// first, starting from entries.
+func (graph *hnswGraph) searchLayer(query []float64, entries []candidate, ef int, level int) []candidate {
+	visited := map[int]bool{}
+	candidates := &candidateHeap{}
+	results := &candidateHeap{farthest: true}
+	for _, entry := range entries {
+		visited[entry.id] = true
+		heap.Push(candidates, entry)
+		heap.Push(results, entry)
+	}
+	for results.Len() > ef {
+		heap.Pop(results)
+	}
+	for candidates.Len() > 0 {
+		closest := heap.Pop(candidates).(candidate)
+		if results.Len() >= ef && closest.distance > results.items[0].distance {
+			break
+		}
+		for _, neighbor := range graph.nodes[closest.id].neighbors[level] {
+			if visited[neighbor] {
+				continue
+			}
+			visited[neighbor] = true
+			distance := graph.distance(query, graph.nodes[neighbor].vector)
+			if results.Len() < ef || distance < results.items[0].distance {
+				heap.Push(candidates, candidate{neighbor, distance})
+				heap.Push(results, candidate{neighbor, distance})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+	sorted := make([]candidate, results.Len())
+	for i := len(sorted) - 1; i >= 0; i-- {
+		sorted[i] = heap.Pop(results).(candidate)
+	}
+	return sorted
+}
+
+// closestIDs keeps the ids of the limit candidates nearest to vector.
+func (graph *hnswGraph) closestIDs(vector []float64, ids []int, limit int) []int {
+	scored := make([]candidate, 0, len(ids))
+	for _, id := range ids {
+		scored = append(scored, candidate{id, graph.distance(vector, graph.nodes[id].vector)})
+	}
+	results := &candidateHeap{farthest: true}
+	for _, item := range scored {
+		heap.Push(results, item)
+		if results.Len() > limit {
+			heap.Pop(results)
+		}
+	}
+	kept := make([]int, 0, results.Len())
+	for _, item := range results.items {
+		kept = append(kept, item.id)
+	}
+	return kept
+}
+
+func (graph *hnswGraph) insert(key string, vector []float64) {
+	graph.remove(key)
+	level := int(math.Floor(-math.Log(1-graph.random.Float64()) * graph.levelFactor))
+	id := len(graph.nodes)
+	node := &hnswNode{key: key, vector: vector, neighbors: make([][]int, level+1)}
+	graph.nodes = append(graph.nodes, node)
+	graph.ids[key] = id
+	if graph.entry < 0 {
+		graph.entry, graph.maxLevel = id, level
+		return
+	}
+	entries := []candidate{{graph.entry, graph.distance(vector, graph.nodes[graph.entry].vector)}}
+	for current := graph.maxLevel; current > level; current-- {
+		entries = graph.searchLayer(vector, entries, 1, current)
+	}
+	for current := min(level, graph.maxLevel); current >= 0; current-- {
+		entries = graph.searchLayer(vector, entries, graph.efConstruction, current)
+		neighbors := make([]int, 0, graph.m)
+		for _, entry := range entries[:min(graph.m, len(entries))] {
+			neighbors = append(neighbors, entry.id)
+		}
+		node.neighbors[current] = neighbors
+		for _, neighbor := range neighbors {
+			linked := append(graph.nodes[neighbor].neighbors[current], id)
+			if len(linked) > graph.maxNeighbors(current) {
+				linked = graph.closestIDs(graph.nodes[neighbor].vector, linked, graph.maxNeighbors(current))
+			}
+			graph.nodes[neighbor].neighbors[current] = linked
+		}
+	}
+	if level > graph.maxLevel {
+		graph.entry, graph.maxLevel = id, level
+	}
+}
+
+func (graph *hnswGraph) remove(key string) {
+	id, ok := graph.ids[key]
+	if !ok {
+		return
+	}
+	delete(graph.ids, key)
+	graph.nodes[id].deleted = true
+	graph.deleted++
+	if id == graph.entry {
+		graph.moveEntry()
+	}
+	if graph.deleted > len(graph.ids) && graph.deleted > 64 {
+		graph.rebuild()
+	}
+}
+
+// moveEntry makes the live node on the most levels the entry point, so
+// searches do not start from a tombstone whose neighbours may all be
+// tombstones too. Without live nodes the graph is emptied.
+func (graph *hnswGraph) moveEntry() {
+	entry := -1
+	for _, id := range graph.ids {
+		if entry < 0 || len(graph.nodes[id].neighbors) > len(graph.nodes[entry].neighbors) {
+			entry = id
+		}
+	}
+	if entry < 0 {
+		graph.rebuild()
+		return
+	}
+	graph.entry, graph.maxLevel = entry, len(graph.nodes[entry].neighbors)-1
+}
+
+func (graph *hnswGraph) rebuild() {
+	live := []*hnswNode{}
+	for _, node := range graph.nodes {
+		if !node.deleted {
+			live = append(live, node)
+		}
+	}
+	graph.nodes, graph.ids, graph.entry, graph.maxLevel, graph.deleted = nil, map[string]int{}, -1, 0, 0
+	for _, node := range live {
+		graph.insert(node.key, node.vector)
+	}
+}
+
+// search returns up to k live nodes nearest to query that pass keep,
+// examining ef candidates on the bottom level.
+func (graph *hnswGraph) search(query []float64, k int, ef int, keep func(key string) bool) []candidate {
+	if graph.entry < 0 {
+		return nil
+	}
+	entries := []candidate{{graph.entry, graph.distance(query, graph.nodes[graph.entry].vector)}}
+	for current := graph.maxLevel; current > 0; current-- {
+		entries = graph.searchLayer(query, entries, 1, current)
+	}
+	found := []candidate{}
+	for _, entry := range graph.searchLayer(query, entries, max(ef, k), 0) {
+		node := graph.nodes[entry.id]
+		if node.deleted || !keep(node.key) {
+			continue
+		}
+		found = append(found, entry)
+		if len(found) == k {
+			break
+		}
+	}
+	return found
+}
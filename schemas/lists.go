@@ -0,0 +1,414 @@
+package schemas
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+)
+
+// listStore runs list operations on one of the array maps. Indexes may be
+// negative to count from the end, -1 being the last element.
+type listStore interface {
+	length(key string) int
+	push(key string, left bool, values []interface{}) (int, error)
+	pop(key string, count int, left bool) interface{}
+	index(key string, index int) (interface{}, error)
+	set(key string, index int, value interface{}) error
+	rangeOf(key string, start int, stop int) interface{}
+	insert(key string, pivot interface{}, value interface{}, before bool) (int, error)
+	remove(key string, count int, value interface{}) (int, error)
+	trim(key string, start int, stop int)
+}
+
+type typedList[T comparable] struct {
+	lists map[string][]T
+}
+
+// toElement converts value to the element type of the list. Integers are
+// accepted by float lists.
+func toElement[T comparable](value interface{}) (T, error) {
+	var zero T
+	if v, ok := value.(T); ok {
+		return v, nil
+	}
+	if integer, ok := value.(int64); ok {
+		if converted, ok := any(float64(integer)).(T); ok {
+			return converted, nil
+		}
+	}
+	return zero, fmt.Errorf("%w: cannot store %T in a %T list", ErrWrongType, value, zero)
+}
+
+// toElements flattens values, each a single element or a slice of them.
+func toElements[T comparable](values []interface{}) ([]T, error) {
+	elements := []T{}
+	for _, value := range values {
+		switch v := value.(type) {
+		case []T:
+			elements = append(elements, v...)
+		case []int64:
+			for _, integer := range v {
+				element, err := toElement[T](integer)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, element)
+			}
+		case []string, []float64:
+			var zero T
+			return nil, fmt.Errorf("%w: cannot store %T in a %T list", ErrWrongType, value, zero)
+		default:
+			element, err := toElement[T](value)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+		}
+	}
+	return elements, nil
+}
+
+// normalizeIndex turns a possibly negative index into an offset and
+// reports whether it falls inside a list of the given length.
+func normalizeIndex(index int, length int) (int, bool) {
+	if index < 0 {
+		index += length
+	}
+	return index, index >= 0 && index < length
+}
+
+// normalizeRange clamps the inclusive range start..stop to the list and
+// returns it as a half open range, empty when nothing is covered.
+func normalizeRange(start int, stop int, length int) (int, int) {
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	if start > stop {
+		return 0, 0
+	}
+	return start, stop + 1
+}
+
+func (l typedList[T]) length(key string) int {
+	return len(l.lists[key])
+}
+
+func (l typedList[T]) push(key string, left bool, values []interface{}) (int, error) {
+	elements, err := toElements[T](values)
+	if err != nil {
+		return 0, err
+	}
+	list := l.lists[key]
+	if left {
+		reversed := make([]T, 0, len(elements)+len(list))
+		for i := len(elements) - 1; i >= 0; i-- {
+			reversed = append(reversed, elements[i])
+		}
+		list = append(reversed, list...)
+	} else {
+		list = append(list, elements...)
+	}
+	l.lists[key] = list
+	return len(list), nil
+}
+
+func (l typedList[T]) pop(key string, count int, left bool) interface{} {
+	list := l.lists[key]
+	if count > len(list) {
+		count = len(list)
+	}
+	popped := make([]T, 0, count)
+	if left {
+		popped = append(popped, list[:count]...)
+		list = list[count:]
+	} else {
+		for i := len(list) - 1; i >= len(list)-count; i-- {
+			popped = append(popped, list[i])
+		}
+		list = list[:len(list)-count]
+	}
+	l.lists[key] = list
+	return popped
+}
+
+func (l typedList[T]) index(key string, index int) (interface{}, error) {
+	list := l.lists[key]
+	offset, ok := normalizeIndex(index, len(list))
+	if !ok {
+		return nil, fmt.Errorf("%w: %d in %s of length %d", ErrIndexOutOfRange, index, key, len(list))
+	}
+	return list[offset], nil
+}
+
+func (l typedList[T]) set(key string, index int, value interface{}) error {
+	element, err := toElement[T](value)
+	if err != nil {
+		return err
+	}
+	list := l.lists[key]
+	offset, ok := normalizeIndex(index, len(list))
+	if !ok {
+		return fmt.Errorf("%w: %d in %s of length %d", ErrIndexOutOfRange, index, key, len(list))
+	}
+	list[offset] = element
+	return nil
+}
+
+func (l typedList[T]) rangeOf(key string, start int, stop int) interface{} {
+	list := l.lists[key]
+	from, to := normalizeRange(start, stop, len(list))
+	return append([]T{}, list[from:to]...)
+}
+
+func (l typedList[T]) insert(key string, pivot interface{}, value interface{}, before bool) (int, error) {
+	pivotElement, err := toElement[T](pivot)
+	if err != nil {
+		return 0, err
+	}
+	element, err := toElement[T](value)
+	if err != nil {
+		return 0, err
+	}
+	list := l.lists[key]
+	for i, current := range list {
+		if current != pivotElement {
+			continue
+		}
+		if !before {
+			i++
+		}
+		list = append(list[:i], append([]T{element}, list[i:]...)...)
+		l.lists[key] = list
+		return len(list), nil
+	}
+	return -1, nil
+}
+
+func (l typedList[T]) remove(key string, count int, value interface{}) (int, error) {
+	element, err := toElement[T](value)
+	if err != nil {
+		return 0, err
+	}
+	list := l.lists[key]
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+	removed := 0
+	matches := func(i int) bool {
+		return list[i] == element && (limit == 0 || removed < limit)
+	}
+	kept := make([]T, 0, len(list))
+	if count >= 0 {
+		for i := range list {
+			if matches(i) {
+				removed++
+				continue
+			}
+			kept = append(kept, list[i])
+		}
+	} else {
+		skip := make([]bool, len(list))
+		for i := len(list) - 1; i >= 0; i-- {
+			if matches(i) {
+				removed++
+				skip[i] = true
+			}
+		}
+		for i := range list {
+			if !skip[i] {
+				kept = append(kept, list[i])
+			}
+		}
+	}
+	l.lists[key] = kept
+	return removed, nil
+}
+
+func (l typedList[T]) trim(key string, start int, stop int) {
+	list := l.lists[key]
+	from, to := normalizeRange(start, stop, len(list))
+	l.lists[key] = append([]T{}, list[from:to]...)
+}
+
+// listAt returns the list stored at key, nil when the key is absent and
+// ErrWrongType when it is not an array.
+func (m *MainMap) listAt(key string) (listStore, error) {
+	switch m.TypeOf(key) {
+	case "integer_array":
+		return typedList[int64]{m.INTEGER_ARRAY_MAP}, nil
+	case "float_array":
+		return typedList[float64]{m.FLOAT_ARRAY_MAP}, nil
+	case "string_array":
+		return typedList[string]{m.STRING_ARRAY_MAP}, nil
+	case "none":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%w: %s is %s, not an array", ErrWrongType, key, m.TypeOf(key))
+}
+
+// listFor picks the array map a new list holding value belongs to.
+func (m *MainMap) listFor(value interface{}) (listStore, error) {
+	switch value.(type) {
+	case int64, []int64:
+		return typedList[int64]{m.INTEGER_ARRAY_MAP}, nil
+	case float64, []float64:
+		return typedList[float64]{m.FLOAT_ARRAY_MAP}, nil
+	case string, []string:
+		return typedList[string]{m.STRING_ARRAY_MAP}, nil
+	}
+	return nil, fmt.Errorf("unsupported list element type %T", value)
+}
+
+// existingList is listAt for operations that need the key to exist.
+func (m *MainMap) existingList(key string) (listStore, error) {
+	list, err := m.listAt(key)
+	if err == nil && list == nil {
+		err = fmt.Errorf("%w: %s", ErrNoSuchKey, key)
+	}
+	return list, err
+}
+
+// ListLength returns the number of elements of the array at key, 0 when
+// the key is absent.
+func (m *MainMap) ListLength(key string) (int, error) {
+	list, err := m.listAt(key)
+	if err != nil || list == nil {
+		return 0, err
+	}
+	return list.length(key), nil
+}
+
+// ListPush adds values, each an element or a slice of elements, to the
+// head (left) or tail of the array at key and returns the new length.
+// Values are pushed one after another, so pushing 1, 2, 3 on the left
+// gives 3, 2, 1. An absent key becomes an array of the first value's type.
+func (m *MainMap) ListPush(key string, left bool, values ...interface{}) (int, error) {
+	if len(values) == 0 {
+		return m.ListLength(key)
+	}
+	list, err := m.listAt(key)
+	if err != nil {
+		return 0, err
+	}
+	created := list == nil
+	if created {
+		if list, err = m.listFor(values[0]); err != nil {
+			return 0, err
+		}
+	}
+	length, err := list.push(key, left, values)
+	if err != nil {
+		// a rejected push changes nothing, not even by creating the key
+		if created {
+			m.removeValue(key)
+		}
+		return 0, err
+	}
+	zap.L().Info("Pushing to list", zap.String("key", key), zap.Bool("left", left), zap.Int("values", len(values)))
+	m.touched(key)
+	return length, nil
+}
+
+// ListPop removes up to count elements from the head (left) or tail of the
+// array at key and returns them in the order they were removed, as a slice
+// of the array's type. It returns nil when the key is absent.
+func (m *MainMap) ListPop(key string, count int, left bool) (interface{}, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("count must not be negative, found %d", count)
+	}
+	list, err := m.listAt(key)
+	if err != nil || list == nil {
+		return nil, err
+	}
+	zap.L().Info("Popping from list", zap.String("key", key), zap.Bool("left", left), zap.Int("count", count))
+	popped := list.pop(key, count, left)
+	if count > 0 {
+		m.touched(key)
+	}
+	return popped, nil
+}
+
+// ListIndex returns the element at index.
+func (m *MainMap) ListIndex(key string, index int) (interface{}, error) {
+	list, err := m.existingList(key)
+	if err != nil {
+		return nil, err
+	}
+	return list.index(key, index)
+}
+
+// ListSet replaces the element at index.
+func (m *MainMap) ListSet(key string, index int, value interface{}) error {
+	list, err := m.existingList(key)
+	if err != nil {
+		return err
+	}
+	zap.L().Info("Setting list element", zap.String("key", key), zap.Int("index", index))
+	if err := list.set(key, index, value); err != nil {
+		return err
+	}
+	m.touched(key)
+	return nil
+}
+
+// ListRange returns a copy of the elements from start to stop inclusive.
+// Both ends are clamped to the array, nil is returned for absent keys.
+func (m *MainMap) ListRange(key string, start int, stop int) (interface{}, error) {
+	list, err := m.listAt(key)
+	if err != nil || list == nil {
+		return nil, err
+	}
+	return list.rangeOf(key, start, stop), nil
+}
+
+// ListInsert inserts value before or after the first element equal to
+// pivot and returns the new length, or -1 when pivot is not found.
+func (m *MainMap) ListInsert(key string, pivot interface{}, value interface{}, before bool) (int, error) {
+	list, err := m.existingList(key)
+	if err != nil {
+		return 0, err
+	}
+	zap.L().Info("Inserting into list", zap.String("key", key), zap.Bool("before", before))
+	length, err := list.insert(key, pivot, value, before)
+	if length > 0 {
+		m.touched(key)
+	}
+	return length, err
+}
+
+// ListRemove removes elements equal to value: the first count of them when
+// count is positive, the last -count when it is negative and all of them
+// when it is zero. It returns the number removed.
+func (m *MainMap) ListRemove(key string, count int, value interface{}) (int, error) {
+	list, err := m.listAt(key)
+	if err != nil || list == nil {
+		return 0, err
+	}
+	zap.L().Info("Removing from list", zap.String("key", key), zap.Int("count", count))
+	removed, err := list.remove(key, count, value)
+	if removed > 0 {
+		m.touched(key)
+	}
+	return removed, err
+}
+
+// ListTrim keeps only the elements from start to stop inclusive.
+func (m *MainMap) ListTrim(key string, start int, stop int) error {
+	list, err := m.existingList(key)
+	if err != nil {
+		return err
+	}
+	zap.L().Info("Trimming list", zap.String("key", key), zap.Int("start", start), zap.Int("stop", stop))
+	list.trim(key, start, stop)
+	m.touched(key)
+	return nil
+}
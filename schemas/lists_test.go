@@ -0,0 +1,67 @@
+package schemas
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestListPushAndPop(t *testing.T) {
+	mainMap := CreateMainMap()
+	if length, err := mainMap.ListPush("l", true, int64(1), int64(2), int64(3)); err != nil || length != 3 {
+		t.Fatalf("expected length 3, found %d, %v", length, err)
+	}
+	mainMap.ListPush("l", false, []int64{4, 5})
+	if values, _ := mainMap.ListRange("l", 0, -1); !reflect.DeepEqual(values, []int64{3, 2, 1, 4, 5}) {
+		t.Fatalf("expected [3 2 1 4 5], found %v", values)
+	}
+	if popped, _ := mainMap.ListPop("l", 2, false); !reflect.DeepEqual(popped, []int64{5, 4}) {
+		t.Fatalf("expected to pop [5 4] from the tail, found %v", popped)
+	}
+	// float lists accept integers
+	mainMap.ListPush("f", false, 1.5, int64(2))
+	if values, _ := mainMap.ListRange("f", 0, -1); !reflect.DeepEqual(values, []float64{1.5, 2}) {
+		t.Fatalf("expected [1.5 2], found %v", values)
+	}
+}
+
+func TestRejectedListPushChangesNothing(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.ListPush("l", false, int64(1))
+	version := mainMap.Version("l")
+	watch := &Watch{}
+	mainMap.Watch("l", watch)
+	if _, err := mainMap.ListPush("l", false, "text"); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("expected a wrong type error, found %v", err)
+	}
+	if mainMap.Version("l") != version || watch.Changed() {
+		t.Fatal("a rejected push counted as a change")
+	}
+	if values, _ := mainMap.ListRange("l", 0, -1); !reflect.DeepEqual(values, []int64{1}) {
+		t.Fatalf("expected the list to stay [1], found %v", values)
+	}
+
+	// the first value picks a new list's type, a later one of another type
+	// fails the push without leaving the key behind
+	if _, err := mainMap.ListPush("new", false, int64(1), "text"); err == nil {
+		t.Fatal("expected mixing types to fail")
+	}
+	if kind := mainMap.TypeOf("new"); kind != "none" || mainMap.Version("new") != 0 {
+		t.Fatalf("expected no key to be left behind, found a %s at version %d", kind, mainMap.Version("new"))
+	}
+}
+
+func TestListIndexesCountFromTheEnd(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.ListPush("l", false, "a", "b", "c")
+	if value, _ := mainMap.ListIndex("l", -1); value != "c" {
+		t.Fatalf("expected c, found %v", value)
+	}
+	if _, err := mainMap.ListIndex("l", 3); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Fatalf("expected index 3 to be out of range, found %v", err)
+	}
+	mainMap.ListTrim("l", 1, -1)
+	if values, _ := mainMap.ListRange("l", 0, -1); !reflect.DeepEqual(values, []string{"b", "c"}) {
+		t.Fatalf("expected [b c], found %v", values)
+	}
+}
@@ -4,16 +4,31 @@ import (
 	"encoding/json"
 	"fmt"
 	"go.uber.org/zap"
+	"sync"
 )
 
+// MainMap methods do not lock on their own; callers that share a MainMap
+// between goroutines hold the embedded lock around every call.
 type MainMap struct {
-	INTEGER_MAP         map[string]int64
-	INTEGER_ARRAY_MAP   map[string][]int64
-	STRING_MAP          map[string]string
-	STRING_ARRAY_MAP    map[string][]string
-	FLOAT_MAP           map[string]float64
-	FLOAT_ARRAY_MAP     map[string][]float64
+	sync.RWMutex
+	INTEGER_MAP       map[string]int64
+	INTEGER_ARRAY_MAP map[string][]int64
+	STRING_MAP        map[string]string
+	STRING_ARRAY_MAP  map[string][]string
+	FLOAT_MAP         map[string]float64
+	FLOAT_ARRAY_MAP   map[string][]float64
+	SET_MAP           map[string]map[string]struct{}
+	HASH_MAP          map[string]map[string]interface{}
+	SORTED_SET_MAP    map[string]*SortedSet
+	VECTOR_INDEXES    map[string]*VectorIndex
+	RANGE_INDEXES     map[string]*RangeIndex
+	TEXT_INDEXES      map[string]*TextIndex
+	// KEY_INDEX orders every key when enabled, nil otherwise
+	KEY_INDEX           *RadixTree
 	TotalNoOfOperations int
+	watches             map[string]map[*Watch]struct{}
+	versions            map[string]int64
+	versionClock        int64
 }
 
 func CreateMainMap() *MainMap {
@@ -24,45 +39,179 @@ func CreateMainMap() *MainMap {
 		STRING_ARRAY_MAP:    make(map[string][]string),
 		FLOAT_MAP:           make(map[string]float64),
 		FLOAT_ARRAY_MAP:     make(map[string][]float64),
+		SET_MAP:             make(map[string]map[string]struct{}),
+		HASH_MAP:            make(map[string]map[string]interface{}),
+		SORTED_SET_MAP:      make(map[string]*SortedSet),
+		VECTOR_INDEXES:      make(map[string]*VectorIndex),
+		RANGE_INDEXES:       make(map[string]*RangeIndex),
+		TEXT_INDEXES:        make(map[string]*TextIndex),
 		TotalNoOfOperations: 0,
+		watches:             make(map[string]map[*Watch]struct{}),
+		versions:            make(map[string]int64),
 	}
 }
 
 func (m *MainMap) SetInteger(key string, value int64) {
 	zap.L().Info("Setting Integer", zap.String("key", key), zap.Int64("value", value))
 	m.INTEGER_MAP[key] = value
-
+	m.touched(key)
 }
 
 func (m *MainMap) SetString(key string, value string) {
 	zap.L().Info("Setting String", zap.String("key", key), zap.String("value", value))
 	m.STRING_MAP[key] = value
-
+	m.touched(key)
 }
 
 func (m *MainMap) SetIntegerArray(key string, value []int64) {
 	zap.L().Info("Setting Integer Array", zap.String("key", key), zap.Int64s("value", value))
 	m.INTEGER_ARRAY_MAP[key] = value
-
+	m.touched(key)
 }
 
 func (m *MainMap) SetStringArray(key string, value []string) {
 	zap.L().Info("Setting String Array", zap.String("key", key), zap.Strings("value", value))
 	m.STRING_ARRAY_MAP[key] = value
-
+	m.touched(key)
 }
 func (m *MainMap) SetFloat(key string, value float64) {
 	zap.L().Info("Setting Float", zap.String("key", key), zap.Float64("value", value))
 	m.FLOAT_MAP[key] = value
-
+	m.touched(key)
 }
 
 func (m *MainMap) SetFloatArray(key string, value []float64) {
 	zap.L().Info("Setting Float Araay", zap.String("key", key), zap.Float64s("value", value))
 	m.FLOAT_ARRAY_MAP[key] = value
+	m.touched(key)
+}
+
+func (m *MainMap) SetSet(key string, members []string) {
+	zap.L().Info("Setting Set", zap.String("key", key), zap.Strings("members", members))
+	set := make(map[string]struct{}, len(members))
+	for _, member := range members {
+		set[member] = struct{}{}
+	}
+	m.SET_MAP[key] = set
+	m.touched(key)
+}
+
+func (m *MainMap) SetHash(key string, fields map[string]interface{}) {
+	zap.L().Info("Setting Hash", zap.String("key", key), zap.Int("fields", len(fields)))
+	m.HASH_MAP[key] = fields
+	m.touched(key)
+}
+
+func (m *MainMap) SetSortedSet(key string, set *SortedSet) {
+	zap.L().Info("Setting Sorted Set", zap.String("key", key), zap.Int("members", set.Len()))
+	m.SORTED_SET_MAP[key] = set
+	m.touched(key)
 }
 
-func (m *MainMap) getValue(key string) interface{} {
+// touched runs after the value at key was created, changed or removed,
+// moves its version on and brings the indexes over keys and values in
+// line with it.
+func (m *MainMap) touched(key string) {
+	exists := m.TypeOf(key) != "none"
+	m.bumpVersion(key, exists)
+	if m.KEY_INDEX != nil {
+		if exists {
+			m.KEY_INDEX.Insert(key)
+		} else {
+			m.KEY_INDEX.Delete(key)
+		}
+	}
+	m.reindexVector(key)
+	m.reindexRange(key)
+	m.reindexText(key)
+	m.notifyWatches(key)
+}
+
+// SetValue stores value under key in the map matching its type, replacing
+// whatever the key held before as one change.
+func (m *MainMap) SetValue(key string, value interface{}) error {
+	switch v := value.(type) {
+	case int64:
+		m.removeValue(key)
+		m.SetInteger(key, v)
+	case string:
+		m.removeValue(key)
+		m.SetString(key, v)
+	case float64:
+		m.removeValue(key)
+		m.SetFloat(key, v)
+	case []int64:
+		m.removeValue(key)
+		m.SetIntegerArray(key, v)
+	case []string:
+		m.removeValue(key)
+		m.SetStringArray(key, v)
+	case []float64:
+		m.removeValue(key)
+		m.SetFloatArray(key, v)
+	default:
+		return fmt.Errorf("unsupported value type %T for key %s", value, key)
+	}
+	return nil
+}
+
+// Delete removes key from every map and reports whether it existed.
+func (m *MainMap) Delete(key string) bool {
+	found := m.removeValue(key)
+	if found {
+		zap.L().Info("Deleting key", zap.String("key", key))
+		m.touched(key)
+	}
+	return found
+}
+
+// removeValue removes key from every map without counting it as a change,
+// for callers that store a new value and then call touched once.
+func (m *MainMap) removeValue(key string) bool {
+	found := false
+	if _, ok := m.STRING_MAP[key]; ok {
+		delete(m.STRING_MAP, key)
+		found = true
+	}
+	if _, ok := m.STRING_ARRAY_MAP[key]; ok {
+		delete(m.STRING_ARRAY_MAP, key)
+		found = true
+	}
+	if _, ok := m.INTEGER_MAP[key]; ok {
+		delete(m.INTEGER_MAP, key)
+		found = true
+	}
+	if _, ok := m.INTEGER_ARRAY_MAP[key]; ok {
+		delete(m.INTEGER_ARRAY_MAP, key)
+		found = true
+	}
+	if _, ok := m.FLOAT_MAP[key]; ok {
+		delete(m.FLOAT_MAP, key)
+		found = true
+	}
+	if _, ok := m.FLOAT_ARRAY_MAP[key]; ok {
+		delete(m.FLOAT_ARRAY_MAP, key)
+		found = true
+	}
+	if _, ok := m.SET_MAP[key]; ok {
+		delete(m.SET_MAP, key)
+		found = true
+	}
+	if _, ok := m.HASH_MAP[key]; ok {
+		delete(m.HASH_MAP, key)
+		found = true
+	}
+	if _, ok := m.SORTED_SET_MAP[key]; ok {
+		delete(m.SORTED_SET_MAP, key)
+		found = true
+	}
+	return found
+}
+
+// GetValue returns the value stored at key, nil when it is absent. Sets are
+// returned as their sorted members, hashes as field, value pairs sorted by
+// field and sorted sets as member, score pairs in score order.
+func (m *MainMap) GetValue(key string) interface{} {
 	if stringValue, ok := m.STRING_MAP[key]; ok {
 		return stringValue
 	}
@@ -81,9 +230,54 @@ func (m *MainMap) getValue(key string) interface{} {
 	if floatArrayValue, ok := m.FLOAT_ARRAY_MAP[key]; ok {
 		return floatArrayValue
 	}
+	if set, ok := m.SET_MAP[key]; ok {
+		return sortedMembers(set)
+	}
+	if hash, ok := m.HASH_MAP[key]; ok {
+		return flattenFields(hash)
+	}
+	if set, ok := m.SORTED_SET_MAP[key]; ok {
+		pairs := []interface{}{}
+		for _, scored := range set.Members() {
+			pairs = append(pairs, scored.Member, scored.Score)
+		}
+		return pairs
+	}
 	return nil
 }
 
+// TypeOf names the map holding key, or "none" when the key is absent.
+func (m *MainMap) TypeOf(key string) string {
+	if _, ok := m.STRING_MAP[key]; ok {
+		return "string"
+	}
+	if _, ok := m.STRING_ARRAY_MAP[key]; ok {
+		return "string_array"
+	}
+	if _, ok := m.INTEGER_MAP[key]; ok {
+		return "integer"
+	}
+	if _, ok := m.INTEGER_ARRAY_MAP[key]; ok {
+		return "integer_array"
+	}
+	if _, ok := m.FLOAT_MAP[key]; ok {
+		return "float"
+	}
+	if _, ok := m.FLOAT_ARRAY_MAP[key]; ok {
+		return "float_array"
+	}
+	if _, ok := m.SET_MAP[key]; ok {
+		return "set"
+	}
+	if _, ok := m.HASH_MAP[key]; ok {
+		return "hash"
+	}
+	if _, ok := m.SORTED_SET_MAP[key]; ok {
+		return "sorted_set"
+	}
+	return "none"
+}
+
 func (m *MainMap) Print() {
 	jsonData, err := json.MarshalIndent(m, "", "  ")
 	if err != nil {
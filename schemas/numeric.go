@@ -0,0 +1,53 @@
+package schemas
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"math"
+)
+
+// IncrementInteger adds delta to the integer stored at key, starting from
+// zero when the key is absent, and returns the new value. The stored value
+// is left unchanged when the sum overflows.
+func (m *MainMap) IncrementInteger(key string, delta int64) (int64, error) {
+	if err := m.checkType(key, "integer"); err != nil {
+		return 0, err
+	}
+	current := m.INTEGER_MAP[key]
+	result := current + delta
+	if (delta > 0 && result < current) || (delta < 0 && result > current) {
+		return 0, fmt.Errorf("%w: %d + %d", ErrOverflow, current, delta)
+	}
+	zap.L().Info("Incrementing Integer", zap.String("key", key), zap.Int64("delta", delta), zap.Int64("value", result))
+	m.INTEGER_MAP[key] = result
+	m.touched(key)
+	return result, nil
+}
+
+// DecrementInteger subtracts delta from the integer stored at key.
+func (m *MainMap) DecrementInteger(key string, delta int64) (int64, error) {
+	if delta == math.MinInt64 {
+		return 0, fmt.Errorf("%w: cannot negate %d", ErrOverflow, delta)
+	}
+	return m.IncrementInteger(key, -delta)
+}
+
+// IncrementFloat adds delta to the float stored at key, starting from zero
+// when the key is absent, and returns the new value. NaN or infinite
+// deltas and results are rejected.
+func (m *MainMap) IncrementFloat(key string, delta float64) (float64, error) {
+	if math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return 0, fmt.Errorf("%w: increment %v", ErrNotFinite, delta)
+	}
+	if err := m.checkType(key, "float"); err != nil {
+		return 0, err
+	}
+	result := m.FLOAT_MAP[key] + delta
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, fmt.Errorf("%w: %v + %v", ErrNotFinite, m.FLOAT_MAP[key], delta)
+	}
+	zap.L().Info("Incrementing Float", zap.String("key", key), zap.Float64("delta", delta), zap.Float64("value", result))
+	m.FLOAT_MAP[key] = result
+	m.touched(key)
+	return result, nil
+}
@@ -0,0 +1,52 @@
+package schemas
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestIncrementInteger(t *testing.T) {
+	mainMap := CreateMainMap()
+	if value, err := mainMap.IncrementInteger("n", 5); err != nil || value != 5 {
+		t.Fatalf("expected an absent key to start from 0, found %d, %v", value, err)
+	}
+	if value, _ := mainMap.DecrementInteger("n", 8); value != -3 {
+		t.Fatalf("expected -3, found %d", value)
+	}
+	mainMap.SetInteger("max", math.MaxInt64)
+	version := mainMap.Version("max")
+	if _, err := mainMap.IncrementInteger("max", 1); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("expected an overflow, found %v", err)
+	}
+	if mainMap.GetValue("max") != int64(math.MaxInt64) || mainMap.Version("max") != version {
+		t.Fatal("an overflowing increment changed the value")
+	}
+	if _, err := mainMap.DecrementInteger("n", math.MinInt64); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("expected negating MinInt64 to overflow, found %v", err)
+	}
+	mainMap.SetString("s", "1")
+	if _, err := mainMap.IncrementInteger("s", 1); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("expected a wrong type error, found %v", err)
+	}
+}
+
+func TestIncrementFloat(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.IncrementFloat("f", 1.5)
+	if value, err := mainMap.IncrementFloat("f", 0.25); err != nil || value != 1.75 {
+		t.Fatalf("expected 1.75, found %v, %v", value, err)
+	}
+	for _, delta := range []float64{math.NaN(), math.Inf(1)} {
+		if _, err := mainMap.IncrementFloat("f", delta); !errors.Is(err, ErrNotFinite) {
+			t.Fatalf("expected %v to be rejected, found %v", delta, err)
+		}
+	}
+	mainMap.SetFloat("big", math.MaxFloat64)
+	if _, err := mainMap.IncrementFloat("big", math.MaxFloat64); !errors.Is(err, ErrNotFinite) {
+		t.Fatalf("expected an infinite result to be rejected, found %v", err)
+	}
+	if mainMap.GetValue("big") != math.MaxFloat64 {
+		t.Fatal("a rejected increment changed the value")
+	}
+}
@@ -0,0 +1,75 @@
+package schemas
+
+import (
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+)
+
+// EnableKeyIndex starts keeping every key in a radix tree, which turns
+// prefix listing, counting and deletion from scans of every map into
+// walks of the keys concerned.
+func (m *MainMap) EnableKeyIndex() {
+	if m.KEY_INDEX != nil {
+		return
+	}
+	tree := NewRadixTree()
+	for _, key := range m.Keys() {
+		tree.Insert(key)
+	}
+	zap.L().Info("Enabling key index", zap.Int("keys", tree.Len()))
+	m.KEY_INDEX = tree
+}
+
+// KeysWithPrefix returns up to limit keys starting with prefix and
+// sorting after after, in ascending order. limit below zero returns all
+// of them and fromStart ignores after and starts from the first key.
+func (m *MainMap) KeysWithPrefix(prefix string, after string, fromStart bool, limit int) []string {
+	keys := []string{}
+	if limit == 0 {
+		return keys
+	}
+	if m.KEY_INDEX != nil {
+		m.KEY_INDEX.WalkPrefix(prefix, after, fromStart, func(key string) bool {
+			keys = append(keys, key)
+			return len(keys) != limit
+		})
+		return keys
+	}
+	for _, key := range m.Keys() {
+		if strings.HasPrefix(key, prefix) && (fromStart || key > after) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys
+}
+
+// CountPrefix returns the number of keys starting with prefix.
+func (m *MainMap) CountPrefix(prefix string) int {
+	if m.KEY_INDEX != nil {
+		return m.KEY_INDEX.CountPrefix(prefix)
+	}
+	count := 0
+	for _, key := range m.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+// DeletePrefix deletes every key starting with prefix and returns how many
+// there were. Callers hold the write lock, so no one sees a partial
+// deletion.
+func (m *MainMap) DeletePrefix(prefix string) int {
+	keys := m.KeysWithPrefix(prefix, "", true, -1)
+	for _, key := range keys {
+		m.Delete(key)
+	}
+	zap.L().Info("Deleting keys by prefix", zap.String("prefix", prefix), zap.Int("deleted", len(keys)))
+	return len(keys)
+}
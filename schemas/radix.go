@@ -0,0 +1,191 @@
+package schemas
+
+import (
+	"sort"
+	"strings"
+)
+
+// RadixTree is a compressed prefix tree of keys. Every node counts the
+// keys below it, so prefixes are counted without visiting them, and
+// children are kept sorted by their first byte, so walks are in key order.
+type RadixTree struct {
+	root *radixNode
+}
+
+type radixNode struct {
+	// prefix is the label of the edge from the parent
+	prefix   string
+	children []*radixNode
+	// leaf is set when a key ends at this node
+	leaf  bool
+	count int
+}
+
+func NewRadixTree() *RadixTree {
+	return &RadixTree{root: &radixNode{}}
+}
+
+func (tree *RadixTree) Len() int {
+	return tree.root.count
+}
+
+// child returns the position of the child whose label starts with first,
+// or where it would be inserted, and the child itself when present.
+func (node *radixNode) child(first byte) (int, *radixNode) {
+	i := sort.Search(len(node.children), func(i int) bool { return node.children[i].prefix[0] >= first })
+	if i < len(node.children) && node.children[i].prefix[0] == first {
+		return i, node.children[i]
+	}
+	return i, nil
+}
+
+func commonPrefixLength(a string, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+func (tree *RadixTree) Contains(key string) bool {
+	node := tree.root
+	for rest := key; rest != ""; {
+		_, child := node.child(rest[0])
+		if child == nil || !strings.HasPrefix(rest, child.prefix) {
+			return false
+		}
+		node, rest = child, rest[len(child.prefix):]
+	}
+	return node.leaf
+}
+
+// Insert adds key and reports whether it was not in the tree yet.
+func (tree *RadixTree) Insert(key string) bool {
+	if tree.Contains(key) {
+		return false
+	}
+	node, rest := tree.root, key
+	for {
+		node.count++
+		if rest == "" {
+			node.leaf = true
+			return true
+		}
+		i, child := node.child(rest[0])
+		if child == nil {
+			node.children = append(node.children, nil)
+			copy(node.children[i+1:], node.children[i:])
+			node.children[i] = &radixNode{prefix: rest, leaf: true, count: 1}
+			return true
+		}
+		common := commonPrefixLength(child.prefix, rest)
+		if common < len(child.prefix) {
+			// split the edge where key leaves it
+			split := &radixNode{prefix: child.prefix[:common], children: []*radixNode{child}, count: child.count}
+			child.prefix = child.prefix[common:]
+			node.children[i] = split
+			child = split
+		}
+		node, rest = child, rest[common:]
+	}
+}
+
+// Delete removes key and reports whether it was in the tree. Nodes left
+// without keys are dropped and a node left with a single child is merged
+// into it, so the tree stays compressed.
+func (tree *RadixTree) Delete(key string) bool {
+	if !tree.Contains(key) {
+		return false
+	}
+	path := []*radixNode{tree.root}
+	node := tree.root
+	for rest := key; rest != ""; {
+		_, child := node.child(rest[0])
+		node, rest = child, rest[len(child.prefix):]
+		path = append(path, node)
+	}
+	node.leaf = false
+	for _, visited := range path {
+		visited.count--
+	}
+	for i := len(path) - 1; i > 0; i-- {
+		node, parent := path[i], path[i-1]
+		position, _ := parent.child(node.prefix[0])
+		if node.count == 0 {
+			parent.children = append(parent.children[:position], parent.children[position+1:]...)
+			continue
+		}
+		if !node.leaf && len(node.children) == 1 {
+			only := node.children[0]
+			only.prefix = node.prefix + only.prefix
+			parent.children[position] = only
+		}
+		break
+	}
+	return true
+}
+
+// seek returns the highest node whose keys all start with prefix, and the
+// full path of that node, nil when no key has the prefix.
+func (tree *RadixTree) seek(prefix string) (*radixNode, string) {
+	node, path := tree.root, ""
+	for rest := prefix; rest != ""; {
+		_, child := node.child(rest[0])
+		if child == nil {
+			return nil, ""
+		}
+		if len(rest) <= len(child.prefix) {
+			if !strings.HasPrefix(child.prefix, rest) {
+				return nil, ""
+			}
+			return child, path + child.prefix
+		}
+		if !strings.HasPrefix(rest, child.prefix) {
+			return nil, ""
+		}
+		node, path, rest = child, path+child.prefix, rest[len(child.prefix):]
+	}
+	return node, path
+}
+
+// CountPrefix returns the number of keys starting with prefix.
+func (tree *RadixTree) CountPrefix(prefix string) int {
+	node, _ := tree.seek(prefix)
+	if node == nil {
+		return 0
+	}
+	return node.count
+}
+
+// WalkPrefix calls fn with every key starting with prefix and sorting
+// after after, in ascending order, until fn returns false. With fromStart
+// after is ignored and the walk starts from the first key, the empty key
+// included.
+func (tree *RadixTree) WalkPrefix(prefix string, after string, fromStart bool, fn func(key string) bool) {
+	node, path := tree.seek(prefix)
+	if node != nil {
+		walk(node, path, after, fromStart, fn)
+	}
+}
+
+// walk visits the subtree of node, whose full path is path, and reports
+// whether fn asked to go on. Until started, subtrees sorting entirely
+// before after are skipped without being visited, and after itself is.
+func walk(node *radixNode, path string, after string, started bool, fn func(key string) bool) bool {
+	if !started {
+		if path < after && !strings.HasPrefix(after, path) {
+			return true
+		}
+		// every key below a path sorting after after does too
+		started = path > after
+	}
+	if node.leaf && started && !fn(path) {
+		return false
+	}
+	for _, child := range node.children {
+		if !walk(child, path+child.prefix, after, started, fn) {
+			return false
+		}
+	}
+	return true
+}
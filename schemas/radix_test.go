@@ -0,0 +1,65 @@
+package schemas
+
+import (
+	"strconv"
+	"testing"
+)
+
+// tenantKeys returns n keys spread over 100 tenants, as the key index
+// bench lays them out.
+func tenantKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = "tenant:" + strconv.Itoa(i%100) + ":user:" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+func TestScanPastTheEmptyKey(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.EnableKeyIndex()
+	for _, key := range []string{"", "a", "b"} {
+		mainMap.SetInteger(key, 1)
+	}
+	seen := []string{}
+	cursor := ScanStart
+	for pages := 0; pages < 10; pages++ {
+		page, next, err := mainMap.Scan(cursor, "", "", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen = append(seen, page...)
+		if cursor = next; cursor == ScanStart {
+			break
+		}
+	}
+	if cursor != ScanStart || len(seen) != 3 || seen[0] != "" || seen[1] != "a" || seen[2] != "b" {
+		t.Fatalf("expected a scan of \"\", a and b, found %q ending at cursor %q", seen, cursor)
+	}
+}
+
+func BenchmarkRadixInsert(b *testing.B) {
+	keys := tenantKeys(100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	tree := NewRadixTree()
+	for i := 0; i < b.N; i++ {
+		if i%len(keys) == 0 {
+			tree = NewRadixTree()
+		}
+		tree.Insert(keys[i%len(keys)])
+	}
+}
+
+func BenchmarkKeysWithPrefix(b *testing.B) {
+	mainMap := CreateMainMap()
+	mainMap.EnableKeyIndex()
+	for i, key := range tenantKeys(100000) {
+		mainMap.SetInteger(key, int64(i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mainMap.KeysWithPrefix("tenant:"+strconv.Itoa(i%100)+":", "", true, -1)
+	}
+}
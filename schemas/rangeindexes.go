@@ -0,0 +1,249 @@
+package schemas
+
+import (
+	"cmp"
+	"fmt"
+	"go.uber.org/zap"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IndexedValue is one key returned by a range index query.
+type IndexedValue struct {
+	Key   string
+	Value interface{}
+}
+
+// rangeBound is one end of a value range, Infinity being -1 for -inf and
+// 1 for +inf.
+type rangeBound[S cmp.Ordered] struct {
+	value     S
+	exclusive bool
+	infinity  int
+}
+
+// parseRangeBound reads a bound such as 5, (5 for an exclusive bound,
+// -inf or +inf, parsing the value with parse.
+func parseRangeBound[S cmp.Ordered](text string, parse func(string) (S, error)) (rangeBound[S], error) {
+	switch strings.ToLower(text) {
+	case "-inf":
+		return rangeBound[S]{infinity: -1}, nil
+	case "+inf", "inf":
+		return rangeBound[S]{infinity: 1}, nil
+	}
+	bound := rangeBound[S]{}
+	value := text
+	if strings.HasPrefix(value, "(") {
+		bound.exclusive, value = true, value[1:]
+	}
+	parsed, err := parse(value)
+	if err != nil {
+		return rangeBound[S]{}, fmt.Errorf("invalid range bound %q", text)
+	}
+	bound.value = parsed
+	return bound, nil
+}
+
+// below reports whether value lies before the range starting at bound.
+func (bound rangeBound[S]) below(value S) bool {
+	if bound.infinity != 0 {
+		return bound.infinity > 0
+	}
+	return value < bound.value || (bound.exclusive && value == bound.value)
+}
+
+// within reports whether value does not pass the range ending at bound.
+func (bound rangeBound[S]) within(value S) bool {
+	if bound.infinity != 0 {
+		return bound.infinity > 0
+	}
+	return value < bound.value || (!bound.exclusive && value == bound.value)
+}
+
+// rangeStore is the ordered part of a range index over one value map.
+type rangeStore interface {
+	// refresh brings the entry of key in line with the value map
+	refresh(key string)
+	size() int
+	query(min string, max string, reverse bool, offset int, count int) ([]IndexedValue, error)
+}
+
+type rangeEntries[S cmp.Ordered] struct {
+	source map[string]S
+	parse  func(string) (S, error)
+	values map[string]S
+	list   *skiplist[S]
+}
+
+func newRangeEntries[S cmp.Ordered](source map[string]S, parse func(string) (S, error)) *rangeEntries[S] {
+	return &rangeEntries[S]{source: source, parse: parse, values: map[string]S{}, list: newSkiplist[S]()}
+}
+
+func (entries *rangeEntries[S]) refresh(key string) {
+	current, indexed := entries.values[key]
+	value, ok := entries.source[key]
+	// NaN compares unequal to itself and cannot be ordered, it is left out
+	ok = ok && value == value
+	if indexed {
+		if ok && value == current {
+			return
+		}
+		entries.list.delete(current, key)
+		delete(entries.values, key)
+	}
+	if ok {
+		entries.values[key] = value
+		entries.list.insert(value, key)
+	}
+}
+
+func (entries *rangeEntries[S]) size() int {
+	return entries.list.length
+}
+
+// query skips offset entries by rank, so deep pages cost no more than the
+// first one.
+func (entries *rangeEntries[S]) query(minText string, maxText string, reverse bool, offset int, count int) ([]IndexedValue, error) {
+	min, err := parseRangeBound(minText, entries.parse)
+	if err != nil {
+		return nil, err
+	}
+	max, err := parseRangeBound(maxText, entries.parse)
+	if err != nil {
+		return nil, err
+	}
+	list := entries.list
+	var node *skiplistNode[S]
+	if reverse {
+		node = list.last(func(node *skiplistNode[S]) bool { return max.within(node.score) })
+		if node != nil && offset > 0 {
+			node = list.byRank(list.rank(node.score, node.member) - offset)
+		}
+	} else {
+		node = list.first(func(node *skiplistNode[S]) bool { return min.below(node.score) })
+		if node != nil && offset > 0 {
+			node = list.byRank(list.rank(node.score, node.member) + offset)
+		}
+	}
+	found := []IndexedValue{}
+	for ; node != nil && count != 0; count-- {
+		if reverse {
+			if min.below(node.score) {
+				break
+			}
+			found = append(found, IndexedValue{node.member, node.score})
+			node = node.backward
+		} else {
+			if !max.within(node.score) {
+				break
+			}
+			found = append(found, IndexedValue{node.member, node.score})
+			node = node.levels[0].forward
+		}
+	}
+	return found, nil
+}
+
+func parseInteger(text string) (int64, error) {
+	return strconv.ParseInt(text, 10, 64)
+}
+
+func parseFloat(text string) (float64, error) {
+	value, err := strconv.ParseFloat(text, 64)
+	if err == nil && math.IsNaN(value) {
+		err = fmt.Errorf("NaN is not a valid bound")
+	}
+	return value, err
+}
+
+// RangeIndex orders the integer or float values of the keys starting with
+// Prefix, as named by Type, so keys can be queried by value range.
+type RangeIndex struct {
+	Name    string
+	Prefix  string
+	Type    string
+	entries rangeStore
+}
+
+func (index *RangeIndex) Size() int {
+	return index.entries.size()
+}
+
+// CreateRangeIndex registers an index over the values of typeName,
+// "integer" or "float", stored under prefix and adds the existing ones.
+func (m *MainMap) CreateRangeIndex(name string, prefix string, typeName string) error {
+	if name == "" {
+		return fmt.Errorf("range index name must not be empty")
+	}
+	if _, ok := m.RANGE_INDEXES[name]; ok {
+		return fmt.Errorf("%w: %s", ErrIndexExists, name)
+	}
+	index := &RangeIndex{Name: name, Prefix: prefix, Type: strings.ToLower(typeName)}
+	keys := []string{}
+	switch index.Type {
+	case "integer":
+		index.entries = newRangeEntries(m.INTEGER_MAP, parseInteger)
+		keys = appendKeys(keys, m.INTEGER_MAP)
+	case "float":
+		index.entries = newRangeEntries(m.FLOAT_MAP, parseFloat)
+		keys = appendKeys(keys, m.FLOAT_MAP)
+	default:
+		return fmt.Errorf("range indexes cover integer or float values, not %q", typeName)
+	}
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			index.entries.refresh(key)
+		}
+	}
+	zap.L().Info("Creating range index", zap.String("name", name), zap.String("prefix", prefix), zap.String("type", index.Type))
+	m.RANGE_INDEXES[name] = index
+	return nil
+}
+
+// DropRangeIndex removes the index and reports whether it existed.
+func (m *MainMap) DropRangeIndex(name string) bool {
+	if _, ok := m.RANGE_INDEXES[name]; !ok {
+		return false
+	}
+	zap.L().Info("Dropping range index", zap.String("name", name))
+	delete(m.RANGE_INDEXES, name)
+	return true
+}
+
+// RangeIndexes returns every index sorted by name.
+func (m *MainMap) RangeIndexes() []*RangeIndex {
+	indexes := make([]*RangeIndex, 0, len(m.RANGE_INDEXES))
+	for _, index := range m.RANGE_INDEXES {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name < indexes[j].Name })
+	return indexes
+}
+
+// reindexRange updates the range indexes covering key after its integer
+// or float value was set or deleted.
+func (m *MainMap) reindexRange(key string) {
+	for _, index := range m.RANGE_INDEXES {
+		if strings.HasPrefix(key, index.Prefix) {
+			index.entries.refresh(key)
+		}
+	}
+}
+
+// RangeQuery returns the keys of the index whose values lie between min
+// and max, ordered by value then key, descending with reverse. Bounds are
+// values, values prefixed with ( to exclude them, -inf or +inf. offset
+// entries are skipped and at most count returned, all when count is
+// negative.
+func (m *MainMap) RangeQuery(name string, min string, max string, reverse bool, offset int, count int) ([]IndexedValue, error) {
+	index, ok := m.RANGE_INDEXES[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoSuchIndex, name)
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("offset must not be negative, found %d", offset)
+	}
+	return index.entries.query(min, max, reverse, offset, count)
+}
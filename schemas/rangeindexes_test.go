@@ -0,0 +1,94 @@
+package schemas
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func indexedKeys(values []IndexedValue) []string {
+	keys := []string{}
+	for _, value := range values {
+		keys = append(keys, value.Key)
+	}
+	return keys
+}
+
+func TestRangeQuery(t *testing.T) {
+	mainMap := CreateMainMap()
+	for i, age := range []int64{30, 20, 40, 20, 50} {
+		mainMap.SetInteger("age:"+strconv.Itoa(i), age)
+	}
+	mainMap.SetInteger("other", 35)
+	if err := mainMap.CreateRangeIndex("ages", "age:", "integer"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		min, max      string
+		reverse       bool
+		offset, count int
+		keys          []string
+	}{
+		{"-inf", "+inf", false, 0, -1, []string{"age:1", "age:3", "age:0", "age:2", "age:4"}},
+		{"20", "30", false, 0, -1, []string{"age:1", "age:3", "age:0"}},
+		{"(20", "(50", false, 0, -1, []string{"age:0", "age:2"}},
+		{"-inf", "+inf", true, 0, 2, []string{"age:4", "age:2"}},
+		{"-inf", "+inf", false, 3, -1, []string{"age:2", "age:4"}},
+		{"20", "40", true, 1, 2, []string{"age:0", "age:3"}},
+		{"-inf", "+inf", true, 10, -1, []string{}},
+		{"60", "+inf", false, 0, -1, []string{}},
+	}
+	for _, test := range tests {
+		found, err := mainMap.RangeQuery("ages", test.min, test.max, test.reverse, test.offset, test.count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keys := indexedKeys(found); !reflect.DeepEqual(keys, test.keys) {
+			t.Errorf("%s %s reverse %v offset %d count %d: expected %v, found %v",
+				test.min, test.max, test.reverse, test.offset, test.count, test.keys, keys)
+		}
+	}
+}
+
+func TestRangeIndexFollowsWrites(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.CreateRangeIndex("prices", "price:", "float")
+	mainMap.SetFloat("price:a", 1.5)
+	mainMap.SetFloat("price:b", 2.5)
+	mainMap.IncrementFloat("price:a", 2)
+	mainMap.SetFloat("price:c", math.NaN())
+	mainMap.Delete("price:b")
+	found, _ := mainMap.RangeQuery("prices", "-inf", "+inf", false, 0, -1)
+	if !reflect.DeepEqual(found, []IndexedValue{{"price:a", 3.5}}) {
+		t.Fatalf("expected only price:a at 3.5, NaN left out, found %v", found)
+	}
+	// a key moving to another type leaves the index
+	mainMap.SetValue("price:a", "text")
+	if size := mainMap.RANGE_INDEXES["prices"].Size(); size != 0 {
+		t.Fatalf("expected an empty index, found %d entries", size)
+	}
+}
+
+func TestRangeIndexErrors(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.CreateRangeIndex("index", "", "integer")
+	if err := mainMap.CreateRangeIndex("index", "", "integer"); !errors.Is(err, ErrIndexExists) {
+		t.Errorf("expected a duplicate index to be rejected, found %v", err)
+	}
+	if err := mainMap.CreateRangeIndex("strings", "", "string"); err == nil {
+		t.Error("expected an index over strings to be rejected")
+	}
+	if _, err := mainMap.RangeQuery("missing", "-inf", "+inf", false, 0, -1); !errors.Is(err, ErrNoSuchIndex) {
+		t.Errorf("expected no such index, found %v", err)
+	}
+	for _, bound := range []string{"x", "(", "1.5"} {
+		if _, err := mainMap.RangeQuery("index", bound, "+inf", false, 0, -1); err == nil {
+			t.Errorf("expected bound %q to be rejected by an integer index", bound)
+		}
+	}
+	if _, err := mainMap.RangeQuery("index", "-inf", "+inf", false, -1, -1); err == nil {
+		t.Error("expected a negative offset to be rejected")
+	}
+}
@@ -0,0 +1,91 @@
+package schemas
+
+import (
+	"fmt"
+)
+
+// KeyTypes lists the names TypeOf gives to existing keys.
+var KeyTypes = []string{"string", "string_array", "integer", "integer_array", "float", "float_array", "set", "hash", "sorted_set"}
+
+func appendKeys[V any](keys []string, values map[string]V) []string {
+	for key := range values {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// keysOf returns the keys of the map holding values of typeName, or of
+// every map when typeName is empty.
+func (m *MainMap) keysOf(typeName string) ([]string, error) {
+	keys := []string{}
+	switch typeName {
+	case "string":
+		return appendKeys(keys, m.STRING_MAP), nil
+	case "string_array":
+		return appendKeys(keys, m.STRING_ARRAY_MAP), nil
+	case "integer":
+		return appendKeys(keys, m.INTEGER_MAP), nil
+	case "integer_array":
+		return appendKeys(keys, m.INTEGER_ARRAY_MAP), nil
+	case "float":
+		return appendKeys(keys, m.FLOAT_MAP), nil
+	case "float_array":
+		return appendKeys(keys, m.FLOAT_ARRAY_MAP), nil
+	case "set":
+		return appendKeys(keys, m.SET_MAP), nil
+	case "hash":
+		return appendKeys(keys, m.HASH_MAP), nil
+	case "sorted_set":
+		return appendKeys(keys, m.SORTED_SET_MAP), nil
+	case "":
+		for _, name := range KeyTypes {
+			typed, _ := m.keysOf(name)
+			keys = append(keys, typed...)
+		}
+		return keys, nil
+	}
+	return nil, fmt.Errorf("unknown type %q, expected one of %v", typeName, KeyTypes)
+}
+
+// Keys returns every key in no particular order.
+func (m *MainMap) Keys() []string {
+	keys, _ := m.keysOf("")
+	return keys
+}
+
+// Scan returns the keys matching pattern among up to count keys after
+// cursor, and the cursor to continue from. When typeName is not empty
+// only keys of that type, as named by TypeOf, are examined. Start with
+// ScanStart; ScanStart is returned again once every key was examined.
+// Keys present for the whole scan are returned exactly once, keys added
+// or removed meanwhile may or may not be.
+func (m *MainMap) Scan(cursor string, pattern string, typeName string, count int) ([]string, string, error) {
+	if m.KEY_INDEX != nil && typeName == "" {
+		return m.scanKeyIndex(cursor, pattern, count)
+	}
+	keys, err := m.keysOf(typeName)
+	if err != nil {
+		return nil, "", err
+	}
+	return scanPage(keys, cursor, pattern, count)
+}
+
+// scanKeyIndex is Scan over the key index, which walks only the keys of
+// the page instead of every key.
+func (m *MainMap) scanKeyIndex(cursor string, pattern string, count int) ([]string, string, error) {
+	last, fromStart, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	if count <= 0 {
+		return nil, "", fmt.Errorf("count must be positive, found %d", count)
+	}
+	// one key past the page tells whether the scan is complete
+	page := m.KeysWithPrefix("", last, fromStart, count+1)
+	next := ScanStart
+	if len(page) > count {
+		page = page[:count]
+		next = encodeCursor(page[count-1])
+	}
+	return matchingNames(page, pattern), next, nil
+}
@@ -0,0 +1,104 @@
+package schemas
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// scanAll runs a scan to completion and returns the keys it found, and
+// calls between, when not nil, after every page.
+func scanAll(t *testing.T, mainMap *MainMap, pattern string, typeName string, count int, between func()) []string {
+	t.Helper()
+	keys := []string{}
+	cursor := ScanStart
+	for pages := 0; ; pages++ {
+		if pages > 10000 {
+			t.Fatal("the scan did not end")
+		}
+		page, next, err := mainMap.Scan(cursor, pattern, typeName, count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, page...)
+		if cursor = next; cursor == ScanStart {
+			return keys
+		}
+		if between != nil {
+			between()
+		}
+	}
+}
+
+func TestScanReturnsEveryKeyOnce(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		mainMap := CreateMainMap()
+		if indexed {
+			mainMap.EnableKeyIndex()
+		}
+		for i := 0; i < 100; i++ {
+			mainMap.SetInteger("n:"+strconv.Itoa(i), int64(i))
+		}
+		mainMap.SetString("s:1", "x")
+		// keys added and removed during the scan must not disturb the rest
+		changes := 0
+		keys := scanAll(t, mainMap, "", "", 7, func() {
+			changes++
+			mainMap.SetInteger("added:"+strconv.Itoa(changes), 1)
+			mainMap.Delete("n:" + strconv.Itoa(changes))
+		})
+		seen := map[string]int{}
+		for _, key := range keys {
+			seen[key]++
+		}
+		for i := 0; i < 100; i++ {
+			if i >= 1 && i <= changes {
+				continue
+			}
+			if key := "n:" + strconv.Itoa(i); seen[key] != 1 {
+				t.Fatalf("indexed %v: expected %s once, found it %d times", indexed, key, seen[key])
+			}
+		}
+		if seen["s:1"] != 1 {
+			t.Fatalf("indexed %v: expected s:1 once, found it %d times", indexed, seen["s:1"])
+		}
+		for key, times := range seen {
+			if times > 1 {
+				t.Fatalf("indexed %v: %s was returned %d times", indexed, key, times)
+			}
+		}
+	}
+}
+
+func TestScanFilters(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.SetInteger("user:1", 1)
+	mainMap.SetString("user:2", "x")
+	mainMap.SetString("item:1", "y")
+	keys := scanAll(t, mainMap, "user:*", "", 1, nil)
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "user:1" || keys[1] != "user:2" {
+		t.Fatalf("expected the user keys, found %v", keys)
+	}
+	if keys := scanAll(t, mainMap, "", "string", 10, nil); len(keys) != 2 {
+		t.Fatalf("expected the 2 string keys, found %v", keys)
+	}
+	if keys := scanAll(t, mainMap, "user:*", "string", 10, nil); len(keys) != 1 || keys[0] != "user:2" {
+		t.Fatalf("expected user:2, found %v", keys)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	mainMap := CreateMainMap()
+	for _, cursor := range []string{"", "x", "c!"} {
+		if _, _, err := mainMap.Scan(cursor, "", "", 10); err == nil {
+			t.Errorf("expected cursor %q to be rejected", cursor)
+		}
+	}
+	if _, _, err := mainMap.Scan(ScanStart, "", "", 0); err == nil {
+		t.Error("expected a count of 0 to be rejected")
+	}
+	if _, _, err := mainMap.Scan(ScanStart, "", "unknown", 10); err == nil {
+		t.Error("expected an unknown type to be rejected")
+	}
+}
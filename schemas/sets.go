@@ -0,0 +1,212 @@
+package schemas
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"math/rand"
+	"sort"
+)
+
+// SetOperation selects how SetCombine joins sets.
+type SetOperation int
+
+const (
+	SetUnion SetOperation = iota
+	SetIntersection
+	// SetDifference keeps the members of the first set found in none of
+	// the others.
+	SetDifference
+)
+
+func sortedMembers(set map[string]struct{}) []string {
+	members := make([]string, 0, len(set))
+	for member := range set {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// setAt returns the set stored at key, nil when the key is absent.
+func (m *MainMap) setAt(key string) (map[string]struct{}, error) {
+	if err := m.checkType(key, "set"); err != nil {
+		return nil, err
+	}
+	return m.SET_MAP[key], nil
+}
+
+// SetAdd adds members to the set at key, creating it when absent, and
+// returns how many were not already members.
+func (m *MainMap) SetAdd(key string, members ...string) (int, error) {
+	set, err := m.setAt(key)
+	if err != nil {
+		return 0, err
+	}
+	if set == nil {
+		set = make(map[string]struct{}, len(members))
+		m.SET_MAP[key] = set
+	}
+	added := 0
+	for _, member := range members {
+		if _, ok := set[member]; !ok {
+			set[member] = struct{}{}
+			added++
+		}
+	}
+	zap.L().Info("Adding to Set", zap.String("key", key), zap.Int("added", added))
+	if added > 0 {
+		m.touched(key)
+	}
+	return added, nil
+}
+
+// SetRemove removes members from the set at key and returns how many were
+// members. A set left empty is deleted.
+func (m *MainMap) SetRemove(key string, members ...string) (int, error) {
+	set, err := m.setAt(key)
+	if err != nil || set == nil {
+		return 0, err
+	}
+	removed := 0
+	for _, member := range members {
+		if _, ok := set[member]; ok {
+			delete(set, member)
+			removed++
+		}
+	}
+	if len(set) == 0 {
+		delete(m.SET_MAP, key)
+	}
+	zap.L().Info("Removing from Set", zap.String("key", key), zap.Int("removed", removed))
+	if removed > 0 {
+		m.touched(key)
+	}
+	return removed, nil
+}
+
+func (m *MainMap) SetIsMember(key string, member string) (bool, error) {
+	set, err := m.setAt(key)
+	if err != nil {
+		return false, err
+	}
+	_, ok := set[member]
+	return ok, nil
+}
+
+func (m *MainMap) SetCardinality(key string) (int, error) {
+	set, err := m.setAt(key)
+	return len(set), err
+}
+
+// SetMembers returns the members of the set at key, sorted.
+func (m *MainMap) SetMembers(key string) ([]string, error) {
+	set, err := m.setAt(key)
+	if err != nil {
+		return nil, err
+	}
+	return sortedMembers(set), nil
+}
+
+// SetRandomMembers returns count distinct random members of the set at
+// key, all of them when count exceeds its size. A negative count returns
+// -count members that may repeat.
+func (m *MainMap) SetRandomMembers(key string, count int) ([]string, error) {
+	set, err := m.setAt(key)
+	if err != nil || len(set) == 0 {
+		return []string{}, err
+	}
+	members := sortedMembers(set)
+	if count < 0 {
+		picked := make([]string, 0, -count)
+		for i := 0; i < -count; i++ {
+			picked = append(picked, members[rand.Intn(len(members))])
+		}
+		return picked, nil
+	}
+	rand.Shuffle(len(members), func(i, j int) {
+		members[i], members[j] = members[j], members[i]
+	})
+	return members[:min(count, len(members))], nil
+}
+
+func (m *MainMap) combine(operation SetOperation, keys []string) (map[string]struct{}, error) {
+	sets := make([]map[string]struct{}, 0, len(keys))
+	for _, key := range keys {
+		set, err := m.setAt(key)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+	result := map[string]struct{}{}
+	if len(sets) == 0 {
+		return result, nil
+	}
+	switch operation {
+	case SetUnion:
+		for _, set := range sets {
+			for member := range set {
+				result[member] = struct{}{}
+			}
+		}
+	case SetIntersection:
+		// walk the smallest set and probe the others
+		smallest := sets[0]
+		for _, set := range sets[1:] {
+			if len(set) < len(smallest) {
+				smallest = set
+			}
+		}
+	members:
+		for member := range smallest {
+			for _, set := range sets {
+				if _, ok := set[member]; !ok {
+					continue members
+				}
+			}
+			result[member] = struct{}{}
+		}
+	case SetDifference:
+	difference:
+		for member := range sets[0] {
+			for _, set := range sets[1:] {
+				if _, ok := set[member]; ok {
+					continue difference
+				}
+			}
+			result[member] = struct{}{}
+		}
+	default:
+		return nil, fmt.Errorf("unknown set operation %d", operation)
+	}
+	return result, nil
+}
+
+// SetCombine returns the sorted union, intersection or difference of the
+// sets at keys. Absent keys count as empty sets.
+func (m *MainMap) SetCombine(operation SetOperation, keys ...string) ([]string, error) {
+	result, err := m.combine(operation, keys)
+	if err != nil {
+		return nil, err
+	}
+	return sortedMembers(result), nil
+}
+
+// SetCombineStore stores the result of SetCombine in destination,
+// replacing whatever it held, and returns its size. destination is deleted
+// when the result is empty.
+func (m *MainMap) SetCombineStore(operation SetOperation, destination string, keys ...string) (int, error) {
+	result, err := m.combine(operation, keys)
+	if err != nil {
+		return 0, err
+	}
+	removed := m.removeValue(destination)
+	if len(result) > 0 {
+		zap.L().Info("Storing Set", zap.String("key", destination), zap.Int("members", len(result)))
+		m.SET_MAP[destination] = result
+	}
+	if removed || len(result) > 0 {
+		m.touched(destination)
+	}
+	return len(result), nil
+}
@@ -0,0 +1,100 @@
+package schemas
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSetAddAndRemove(t *testing.T) {
+	mainMap := CreateMainMap()
+	if added, _ := mainMap.SetAdd("s", "a", "b", "a"); added != 2 {
+		t.Fatalf("expected 2 new members, found %d", added)
+	}
+	version := mainMap.Version("s")
+	if added, _ := mainMap.SetAdd("s", "b"); added != 0 || mainMap.Version("s") != version {
+		t.Fatal("adding a member again counted as a change")
+	}
+	if ok, _ := mainMap.SetIsMember("s", "a"); !ok {
+		t.Fatal("expected a to be a member")
+	}
+	if removed, _ := mainMap.SetRemove("s", "a", "missing"); removed != 1 {
+		t.Fatalf("expected 1 removed member, found %d", removed)
+	}
+	mainMap.SetRemove("s", "b")
+	if kind := mainMap.TypeOf("s"); kind != "none" {
+		t.Fatalf("expected an emptied set to be deleted, found a %s", kind)
+	}
+	mainMap.SetInteger("n", 1)
+	if _, err := mainMap.SetAdd("n", "a"); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("expected a wrong type error, found %v", err)
+	}
+}
+
+func TestSetRandomMembers(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.SetAdd("s", "a", "b", "c")
+	if members, _ := mainMap.SetRandomMembers("s", 10); len(members) != 3 {
+		t.Fatalf("expected every member once, found %v", members)
+	}
+	picked, _ := mainMap.SetRandomMembers("s", 2)
+	if len(picked) != 2 || picked[0] == picked[1] {
+		t.Fatalf("expected 2 distinct members, found %v", picked)
+	}
+	if members, _ := mainMap.SetRandomMembers("s", -5); len(members) != 5 {
+		t.Fatalf("expected 5 members that may repeat, found %v", members)
+	}
+	if members, _ := mainMap.SetRandomMembers("missing", 2); members == nil || len(members) != 0 {
+		t.Fatalf("expected an empty reply for an absent key, found %v", members)
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.SetAdd("x", "a", "b", "c")
+	mainMap.SetAdd("y", "b", "c", "d")
+	mainMap.SetAdd("z", "c")
+	tests := []struct {
+		operation SetOperation
+		keys      []string
+		members   []string
+	}{
+		{SetUnion, []string{"x", "y"}, []string{"a", "b", "c", "d"}},
+		{SetIntersection, []string{"x", "y", "z"}, []string{"c"}},
+		{SetIntersection, []string{"x", "missing"}, []string{}},
+		{SetDifference, []string{"x", "y"}, []string{"a"}},
+		{SetDifference, []string{"x", "missing"}, []string{"a", "b", "c"}},
+	}
+	for _, test := range tests {
+		members, err := mainMap.SetCombine(test.operation, test.keys...)
+		if err != nil || !reflect.DeepEqual(members, test.members) {
+			t.Errorf("operation %d on %v: expected %v, found %v, %v", test.operation, test.keys, test.members, members, err)
+		}
+	}
+	mainMap.SetString("s", "text")
+	if _, err := mainMap.SetCombine(SetUnion, "x", "s"); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("expected a wrong type error, found %v", err)
+	}
+}
+
+func TestSetCombineStore(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.SetAdd("x", "a", "b")
+	mainMap.SetAdd("y", "b")
+	// the destination is replaced whatever its type
+	mainMap.SetString("d", "text")
+	if size, err := mainMap.SetCombineStore(SetDifference, "d", "x", "y"); err != nil || size != 1 {
+		t.Fatalf("expected a set of 1 member, found %d, %v", size, err)
+	}
+	if members, _ := mainMap.SetMembers("d"); !reflect.DeepEqual(members, []string{"a"}) {
+		t.Fatalf("expected [a], found %v", members)
+	}
+	// the result may be stored in one of its sources
+	mainMap.SetCombineStore(SetUnion, "x", "x", "y", "d")
+	if members, _ := mainMap.SetMembers("x"); !reflect.DeepEqual(members, []string{"a", "b"}) {
+		t.Fatalf("expected [a b], found %v", members)
+	}
+	if size, _ := mainMap.SetCombineStore(SetIntersection, "d", "x", "missing"); size != 0 || mainMap.TypeOf("d") != "none" {
+		t.Fatal("expected an empty result to delete the destination")
+	}
+}
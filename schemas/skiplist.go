@@ -0,0 +1,198 @@
+package schemas
+
+import (
+	"cmp"
+	"math/rand"
+)
+
+const (
+	skiplistMaxLevel = 32
+	// skiplistP is the chance a node also appears on the next level up
+	skiplistP = 0.25
+)
+
+type skiplistLevel[S cmp.Ordered] struct {
+	forward *skiplistNode[S]
+	// span counts the nodes forward skips over, used to compute ranks
+	span int
+}
+
+type skiplistNode[S cmp.Ordered] struct {
+	member   string
+	score    S
+	backward *skiplistNode[S]
+	levels   []skiplistLevel[S]
+}
+
+// skiplist keeps members ordered by score, then by member, with ranks
+// computed from the spans of the links walked. Sorted sets score with
+// float64, range indexes over integers with int64.
+type skiplist[S cmp.Ordered] struct {
+	header *skiplistNode[S]
+	tail   *skiplistNode[S]
+	length int
+	level  int
+}
+
+func newSkiplist[S cmp.Ordered]() *skiplist[S] {
+	return &skiplist[S]{
+		header: &skiplistNode[S]{levels: make([]skiplistLevel[S], skiplistMaxLevel)},
+		level:  1,
+	}
+}
+
+func randomLevel() int {
+	level := 1
+	for level < skiplistMaxLevel && rand.Float64() < skiplistP {
+		level++
+	}
+	return level
+}
+
+// before reports whether node sorts before score and member.
+func (node *skiplistNode[S]) before(score S, member string) bool {
+	return node.score < score || (node.score == score && node.member < member)
+}
+
+// insert adds a member that must not be in the list yet.
+func (list *skiplist[S]) insert(score S, member string) *skiplistNode[S] {
+	var update [skiplistMaxLevel]*skiplistNode[S]
+	var rank [skiplistMaxLevel]int
+	node := list.header
+	for i := list.level - 1; i >= 0; i-- {
+		if i < list.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for node.levels[i].forward != nil && node.levels[i].forward.before(score, member) {
+			rank[i] += node.levels[i].span
+			node = node.levels[i].forward
+		}
+		update[i] = node
+	}
+	level := randomLevel()
+	if level > list.level {
+		for i := list.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = list.header
+			update[i].levels[i].span = list.length
+		}
+		list.level = level
+	}
+	node = &skiplistNode[S]{member: member, score: score, levels: make([]skiplistLevel[S], level)}
+	for i := 0; i < level; i++ {
+		node.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = node
+		node.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < list.level; i++ {
+		update[i].levels[i].span++
+	}
+	if update[0] != list.header {
+		node.backward = update[0]
+	}
+	if node.levels[0].forward != nil {
+		node.levels[0].forward.backward = node
+	} else {
+		list.tail = node
+	}
+	list.length++
+	return node
+}
+
+// delete removes the member with the given score and reports whether it
+// was found.
+func (list *skiplist[S]) delete(score S, member string) bool {
+	var update [skiplistMaxLevel]*skiplistNode[S]
+	node := list.header
+	for i := list.level - 1; i >= 0; i-- {
+		for node.levels[i].forward != nil && node.levels[i].forward.before(score, member) {
+			node = node.levels[i].forward
+		}
+		update[i] = node
+	}
+	node = node.levels[0].forward
+	if node == nil || node.score != score || node.member != member {
+		return false
+	}
+	for i := 0; i < list.level; i++ {
+		if update[i].levels[i].forward == node {
+			update[i].levels[i].span += node.levels[i].span - 1
+			update[i].levels[i].forward = node.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+	if node.levels[0].forward != nil {
+		node.levels[0].forward.backward = node.backward
+	} else {
+		list.tail = node.backward
+	}
+	for list.level > 1 && list.header.levels[list.level-1].forward == nil {
+		list.level--
+	}
+	list.length--
+	return true
+}
+
+// rank returns the 0 based position of the member, -1 when absent.
+func (list *skiplist[S]) rank(score S, member string) int {
+	rank := 0
+	node := list.header
+	for i := list.level - 1; i >= 0; i-- {
+		for next := node.levels[i].forward; next != nil && (next.before(score, member) || (next.score == score && next.member == member)); next = node.levels[i].forward {
+			rank += node.levels[i].span
+			node = next
+		}
+		if node != list.header && node.member == member {
+			return rank - 1
+		}
+	}
+	return -1
+}
+
+// byRank returns the node at the 0 based rank, nil when out of range.
+func (list *skiplist[S]) byRank(rank int) *skiplistNode[S] {
+	if rank < 0 || rank >= list.length {
+		return nil
+	}
+	traversed := 0
+	node := list.header
+	for i := list.level - 1; i >= 0; i-- {
+		for node.levels[i].forward != nil && traversed+node.levels[i].span <= rank+1 {
+			traversed += node.levels[i].span
+			node = node.levels[i].forward
+		}
+		if traversed == rank+1 {
+			return node
+		}
+	}
+	return nil
+}
+
+// first returns the first node for which below is false, given that below
+// holds for a prefix of the list.
+func (list *skiplist[S]) first(below func(node *skiplistNode[S]) bool) *skiplistNode[S] {
+	node := list.header
+	for i := list.level - 1; i >= 0; i-- {
+		for node.levels[i].forward != nil && below(node.levels[i].forward) {
+			node = node.levels[i].forward
+		}
+	}
+	return node.levels[0].forward
+}
+
+// last returns the last node for which within is true, given that within
+// holds for a prefix of the list.
+func (list *skiplist[S]) last(within func(node *skiplistNode[S]) bool) *skiplistNode[S] {
+	node := list.header
+	for i := list.level - 1; i >= 0; i-- {
+		for node.levels[i].forward != nil && within(node.levels[i].forward) {
+			node = node.levels[i].forward
+		}
+	}
+	if node == list.header {
+		return nil
+	}
+	return node
+}
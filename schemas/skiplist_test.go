@@ -0,0 +1,68 @@
+package schemas
+
+import (
+	"cmp"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// checkSkiplist compares every rank and link of list with members, which
+// holds the expected order.
+func checkSkiplist(t *testing.T, list *skiplist[int64], members []ScoredMember) {
+	t.Helper()
+	if list.length != len(members) {
+		t.Fatalf("expected length %d, found %d", len(members), list.length)
+	}
+	var previous *skiplistNode[int64]
+	for i, member := range members {
+		node := list.byRank(i)
+		if node == nil || node.member != member.Member || float64(node.score) != member.Score {
+			t.Fatalf("expected %v at rank %d, found %+v", member, i, node)
+		}
+		if rank := list.rank(node.score, node.member); rank != i {
+			t.Fatalf("expected %s at rank %d, found %d", member.Member, i, rank)
+		}
+		if node.backward != previous {
+			t.Fatalf("the backward link of %s is wrong", member.Member)
+		}
+		previous = node
+	}
+	if list.tail != previous || list.byRank(len(members)) != nil || list.byRank(-1) != nil {
+		t.Fatal("expected the tail to be the last member and ranks past either end to be nil")
+	}
+}
+
+func TestSkiplistAgainstASortedSlice(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	list := newSkiplist[int64]()
+	scores := map[string]int64{}
+	for step := 0; step < 2000; step++ {
+		member := strconv.Itoa(random.Intn(200))
+		if score, ok := scores[member]; ok {
+			if !list.delete(score, member) {
+				t.Fatalf("failed to delete %s", member)
+			}
+			delete(scores, member)
+		} else {
+			// few distinct scores so ties are ordered by member
+			scores[member] = int64(random.Intn(10))
+			list.insert(scores[member], member)
+		}
+		if step%100 == 0 {
+			members := []ScoredMember{}
+			for member, score := range scores {
+				members = append(members, ScoredMember{member, float64(score)})
+			}
+			slices.SortFunc(members, func(a, b ScoredMember) int {
+				return cmp.Or(cmp.Compare(a.Score, b.Score), strings.Compare(a.Member, b.Member))
+			})
+			checkSkiplist(t, list, members)
+		}
+	}
+	if list.delete(100, "absent") || list.rank(100, "absent") != -1 {
+		t.Fatal("expected an absent member to be neither deleted nor ranked")
+	}
+}
@@ -0,0 +1,354 @@
+package schemas
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SortedSet maps members to scores and keeps them ordered by score, ties
+// broken by member. The map answers score lookups, the skiplist ranks and
+// ranges.
+type SortedSet struct {
+	scores map[string]float64
+	list   *skiplist[float64]
+}
+
+// ScoredMember is one element of a sorted set.
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+func NewSortedSet() *SortedSet {
+	return &SortedSet{scores: map[string]float64{}, list: newSkiplist[float64]()}
+}
+
+func (set *SortedSet) Len() int {
+	return set.list.length
+}
+
+// Add sets the score of member and reports whether it is new.
+func (set *SortedSet) Add(member string, score float64) bool {
+	current, ok := set.scores[member]
+	if ok {
+		if current == score {
+			return false
+		}
+		set.list.delete(current, member)
+	}
+	set.scores[member] = score
+	set.list.insert(score, member)
+	return !ok
+}
+
+func (set *SortedSet) Remove(member string) bool {
+	score, ok := set.scores[member]
+	if !ok {
+		return false
+	}
+	delete(set.scores, member)
+	set.list.delete(score, member)
+	return true
+}
+
+// Members returns every member in ascending order.
+func (set *SortedSet) Members() []ScoredMember {
+	members := make([]ScoredMember, 0, set.list.length)
+	for node := set.list.header.levels[0].forward; node != nil; node = node.levels[0].forward {
+		members = append(members, ScoredMember{Member: node.member, Score: node.score})
+	}
+	return members
+}
+
+// ScoreBound is one end of a score range. Use infinite values for open
+// ends.
+type ScoreBound struct {
+	Value     float64
+	Exclusive bool
+}
+
+// ParseScoreBound reads a bound such as 1.5, (1.5 for an exclusive bound,
+// -inf or +inf.
+func ParseScoreBound(text string) (ScoreBound, error) {
+	bound := ScoreBound{}
+	if strings.HasPrefix(text, "(") {
+		bound.Exclusive, text = true, text[1:]
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsNaN(value) {
+		return ScoreBound{}, fmt.Errorf("invalid score bound %q", text)
+	}
+	bound.Value = value
+	return bound, nil
+}
+
+// below reports whether score lies before the range starting at bound.
+func (bound ScoreBound) below(score float64) bool {
+	return score < bound.Value || (bound.Exclusive && score == bound.Value)
+}
+
+// within reports whether score does not pass the range ending at bound.
+func (bound ScoreBound) within(score float64) bool {
+	return score < bound.Value || (!bound.Exclusive && score == bound.Value)
+}
+
+// LexBound is one end of a member range. Infinity is -1 for "-", below
+// every member, and 1 for "+", above every member.
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Infinity  int
+}
+
+// ParseLexBound reads [member or (member for inclusive and exclusive
+// bounds, or - and + for the open ends.
+func ParseLexBound(text string) (LexBound, error) {
+	switch {
+	case text == "-":
+		return LexBound{Infinity: -1}, nil
+	case text == "+":
+		return LexBound{Infinity: 1}, nil
+	case strings.HasPrefix(text, "["):
+		return LexBound{Value: text[1:]}, nil
+	case strings.HasPrefix(text, "("):
+		return LexBound{Value: text[1:], Exclusive: true}, nil
+	}
+	return LexBound{}, fmt.Errorf("invalid lex bound %q, expected [member, (member, - or +", text)
+}
+
+func (bound LexBound) below(member string) bool {
+	if bound.Infinity != 0 {
+		return bound.Infinity > 0
+	}
+	return member < bound.Value || (bound.Exclusive && member == bound.Value)
+}
+
+func (bound LexBound) within(member string) bool {
+	if bound.Infinity != 0 {
+		return bound.Infinity > 0
+	}
+	return member < bound.Value || (!bound.Exclusive && member == bound.Value)
+}
+
+// collect walks from node in either direction while keep holds, skipping
+// offset nodes and returning at most count of them, all when count is
+// negative.
+func collect(node *skiplistNode[float64], reverse bool, offset int, count int, keep func(node *skiplistNode[float64]) bool) []ScoredMember {
+	members := []ScoredMember{}
+	for ; node != nil && keep(node) && count != 0; offset-- {
+		if offset <= 0 {
+			members = append(members, ScoredMember{Member: node.member, Score: node.score})
+			count--
+		}
+		if reverse {
+			node = node.backward
+		} else {
+			node = node.levels[0].forward
+		}
+	}
+	return members
+}
+
+// sortedSetAt returns the sorted set stored at key, nil when absent.
+func (m *MainMap) sortedSetAt(key string) (*SortedSet, error) {
+	if err := m.checkType(key, "sorted_set"); err != nil {
+		return nil, err
+	}
+	return m.SORTED_SET_MAP[key], nil
+}
+
+// deleteIfEmpty drops a sorted set that lost its last member.
+func (m *MainMap) deleteIfEmpty(key string, set *SortedSet) {
+	if set != nil && set.Len() == 0 {
+		delete(m.SORTED_SET_MAP, key)
+	}
+}
+
+// SortedSetAdd sets the scores of members, creating the sorted set when
+// absent, and returns how many members are new. NaN scores are rejected.
+func (m *MainMap) SortedSetAdd(key string, scores map[string]float64) (int, error) {
+	set, err := m.sortedSetAt(key)
+	if err != nil {
+		return 0, err
+	}
+	for member, score := range scores {
+		if math.IsNaN(score) {
+			return 0, fmt.Errorf("%w: score of %s is NaN", ErrNotFinite, member)
+		}
+	}
+	if set == nil {
+		set = NewSortedSet()
+		m.SORTED_SET_MAP[key] = set
+	}
+	added := 0
+	for member, score := range scores {
+		if set.Add(member, score) {
+			added++
+		}
+	}
+	zap.L().Info("Adding to Sorted Set", zap.String("key", key), zap.Int("added", added))
+	m.touched(key)
+	return added, nil
+}
+
+// SortedSetIncrement adds delta to the score of member, starting from zero
+// when absent, and returns the new score.
+func (m *MainMap) SortedSetIncrement(key string, member string, delta float64) (float64, error) {
+	set, err := m.sortedSetAt(key)
+	if err != nil {
+		return 0, err
+	}
+	var current float64
+	if set != nil {
+		current = set.scores[member]
+	}
+	score := current + delta
+	if math.IsNaN(score) {
+		return 0, fmt.Errorf("%w: %v + %v", ErrNotFinite, current, delta)
+	}
+	if set == nil {
+		set = NewSortedSet()
+		m.SORTED_SET_MAP[key] = set
+	}
+	set.Add(member, score)
+	m.touched(key)
+	zap.L().Info("Incrementing Sorted Set score", zap.String("key", key), zap.String("member", member), zap.Float64("score", score))
+	return score, nil
+}
+
+// SortedSetScore returns the score of member and whether it exists.
+func (m *MainMap) SortedSetScore(key string, member string) (float64, bool, error) {
+	set, err := m.sortedSetAt(key)
+	if err != nil || set == nil {
+		return 0, false, err
+	}
+	score, ok := set.scores[member]
+	return score, ok, nil
+}
+
+// SortedSetRemove removes members and returns how many existed. A sorted
+// set left empty is deleted.
+func (m *MainMap) SortedSetRemove(key string, members ...string) (int, error) {
+	set, err := m.sortedSetAt(key)
+	if err != nil || set == nil {
+		return 0, err
+	}
+	removed := 0
+	for _, member := range members {
+		if set.Remove(member) {
+			removed++
+		}
+	}
+	m.deleteIfEmpty(key, set)
+	zap.L().Info("Removing from Sorted Set", zap.String("key", key), zap.Int("removed", removed))
+	if removed > 0 {
+		m.touched(key)
+	}
+	return removed, nil
+}
+
+func (m *MainMap) SortedSetCardinality(key string) (int, error) {
+	set, err := m.sortedSetAt(key)
+	if err != nil || set == nil {
+		return 0, err
+	}
+	return set.Len(), nil
+}
+
+// SortedSetRank returns the 0 based position of member in ascending order,
+// or descending with reverse, and whether the member exists.
+func (m *MainMap) SortedSetRank(key string, member string, reverse bool) (int, bool, error) {
+	set, err := m.sortedSetAt(key)
+	if err != nil || set == nil {
+		return 0, false, err
+	}
+	score, ok := set.scores[member]
+	if !ok {
+		return 0, false, nil
+	}
+	rank := set.list.rank(score, member)
+	if reverse {
+		rank = set.Len() - 1 - rank
+	}
+	return rank, true, nil
+}
+
+// SortedSetRangeByRank returns the members from rank start to stop
+// inclusive, negative ranks counting from the end. With reverse ranks
+// count from the highest score.
+func (m *MainMap) SortedSetRangeByRank(key string, start int, stop int, reverse bool) ([]ScoredMember, error) {
+	set, err := m.sortedSetAt(key)
+	if err != nil || set == nil {
+		return []ScoredMember{}, err
+	}
+	from, to := normalizeRange(start, stop, set.Len())
+	if from == to {
+		return []ScoredMember{}, nil
+	}
+	if reverse {
+		return collect(set.list.byRank(set.Len()-1-from), true, 0, to-from, func(*skiplistNode[float64]) bool { return true }), nil
+	}
+	return collect(set.list.byRank(from), false, 0, to-from, func(*skiplistNode[float64]) bool { return true }), nil
+}
+
+// SortedSetRangeByScore returns members with scores between min and max,
+// in descending order with reverse, skipping offset of them and returning
+// at most count, all when count is negative.
+func (m *MainMap) SortedSetRangeByScore(key string, min ScoreBound, max ScoreBound, reverse bool, offset int, count int) ([]ScoredMember, error) {
+	set, err := m.sortedSetAt(key)
+	if err != nil || set == nil {
+		return []ScoredMember{}, err
+	}
+	if reverse {
+		start := set.list.last(func(node *skiplistNode[float64]) bool { return max.within(node.score) })
+		return collect(start, true, offset, count, func(node *skiplistNode[float64]) bool { return !min.below(node.score) }), nil
+	}
+	start := set.list.first(func(node *skiplistNode[float64]) bool { return min.below(node.score) })
+	return collect(start, false, offset, count, func(node *skiplistNode[float64]) bool { return max.within(node.score) }), nil
+}
+
+// SortedSetRangeByLex returns members between min and max ordered by
+// member. Like in redis it is meant for sorted sets whose members all have
+// the same score.
+func (m *MainMap) SortedSetRangeByLex(key string, min LexBound, max LexBound, reverse bool, offset int, count int) ([]ScoredMember, error) {
+	set, err := m.sortedSetAt(key)
+	if err != nil || set == nil {
+		return []ScoredMember{}, err
+	}
+	if reverse {
+		start := set.list.last(func(node *skiplistNode[float64]) bool { return max.within(node.member) })
+		return collect(start, true, offset, count, func(node *skiplistNode[float64]) bool { return !min.below(node.member) }), nil
+	}
+	start := set.list.first(func(node *skiplistNode[float64]) bool { return min.below(node.member) })
+	return collect(start, false, offset, count, func(node *skiplistNode[float64]) bool { return max.within(node.member) }), nil
+}
+
+// SortedSetPop removes and returns up to count members with the lowest
+// scores, or the highest with max.
+func (m *MainMap) SortedSetPop(key string, count int, max bool) ([]ScoredMember, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("count must not be negative, found %d", count)
+	}
+	set, err := m.sortedSetAt(key)
+	if err != nil || set == nil {
+		return []ScoredMember{}, err
+	}
+	popped := []ScoredMember{}
+	for len(popped) < count && set.Len() > 0 {
+		node := set.list.header.levels[0].forward
+		if max {
+			node = set.list.tail
+		}
+		popped = append(popped, ScoredMember{Member: node.member, Score: node.score})
+		set.Remove(node.member)
+	}
+	m.deleteIfEmpty(key, set)
+	zap.L().Info("Popping from Sorted Set", zap.String("key", key), zap.Int("popped", len(popped)))
+	if len(popped) > 0 {
+		m.touched(key)
+	}
+	return popped, nil
+}
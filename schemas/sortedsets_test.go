@@ -0,0 +1,143 @@
+package schemas
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// leaderboard holds a at 1, b and c at 2, d at 3 and e at 5.
+func leaderboard() *MainMap {
+	mainMap := CreateMainMap()
+	mainMap.SortedSetAdd("z", map[string]float64{"a": 1, "b": 2, "c": 2, "d": 3, "e": 5})
+	return mainMap
+}
+
+func memberNames(members []ScoredMember) []string {
+	names := []string{}
+	for _, member := range members {
+		names = append(names, member.Member)
+	}
+	return names
+}
+
+func TestSortedSetRanks(t *testing.T) {
+	mainMap := leaderboard()
+	for member, rank := range map[string]int{"a": 0, "b": 1, "c": 2, "e": 4} {
+		if found, ok, _ := mainMap.SortedSetRank("z", member, false); !ok || found != rank {
+			t.Errorf("expected %s at rank %d, found %d", member, rank, found)
+		}
+	}
+	if rank, _, _ := mainMap.SortedSetRank("z", "a", true); rank != 4 {
+		t.Errorf("expected a at reverse rank 4, found %d", rank)
+	}
+	if _, ok, _ := mainMap.SortedSetRank("z", "missing", false); ok {
+		t.Error("expected no rank for an absent member")
+	}
+	// moving a member updates its rank
+	mainMap.SortedSetIncrement("z", "a", 10)
+	if rank, _, _ := mainMap.SortedSetRank("z", "a", false); rank != 4 {
+		t.Errorf("expected a at rank 4 after its increment, found %d", rank)
+	}
+}
+
+func TestSortedSetRangeByRank(t *testing.T) {
+	mainMap := leaderboard()
+	tests := []struct {
+		start, stop int
+		reverse     bool
+		members     []string
+	}{
+		{0, -1, false, []string{"a", "b", "c", "d", "e"}},
+		{1, 2, false, []string{"b", "c"}},
+		{-2, -1, false, []string{"d", "e"}},
+		{0, 1, true, []string{"e", "d"}},
+		{3, 100, false, []string{"d", "e"}},
+		{3, 1, false, []string{}},
+	}
+	for _, test := range tests {
+		members, _ := mainMap.SortedSetRangeByRank("z", test.start, test.stop, test.reverse)
+		if names := memberNames(members); !reflect.DeepEqual(names, test.members) {
+			t.Errorf("range %d %d reverse %v: expected %v, found %v", test.start, test.stop, test.reverse, test.members, names)
+		}
+	}
+}
+
+func TestSortedSetRangeByScore(t *testing.T) {
+	mainMap := leaderboard()
+	bound := func(text string) ScoreBound {
+		parsed, err := ParseScoreBound(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	tests := []struct {
+		min, max      string
+		reverse       bool
+		offset, count int
+		members       []string
+	}{
+		{"2", "3", false, 0, -1, []string{"b", "c", "d"}},
+		{"(2", "+inf", false, 0, -1, []string{"d", "e"}},
+		{"-inf", "(2", false, 0, -1, []string{"a"}},
+		{"1", "5", false, 1, 2, []string{"b", "c"}},
+		{"2", "5", true, 0, -1, []string{"e", "d", "c", "b"}},
+		{"(1", "(5", true, 1, 1, []string{"c"}},
+		{"4", "4", false, 0, -1, []string{}},
+	}
+	for _, test := range tests {
+		members, _ := mainMap.SortedSetRangeByScore("z", bound(test.min), bound(test.max), test.reverse, test.offset, test.count)
+		if names := memberNames(members); !reflect.DeepEqual(names, test.members) {
+			t.Errorf("scores %s %s reverse %v: expected %v, found %v", test.min, test.max, test.reverse, test.members, names)
+		}
+	}
+	if _, err := ParseScoreBound("nan"); err == nil {
+		t.Error("expected a NaN bound to be rejected")
+	}
+}
+
+func TestSortedSetRangeByLex(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.SortedSetAdd("z", map[string]float64{"apple": 0, "banana": 0, "cherry": 0})
+	bound := func(text string) LexBound {
+		parsed, err := ParseLexBound(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	members, _ := mainMap.SortedSetRangeByLex("z", bound("[b"), bound("+"), false, 0, -1)
+	if names := memberNames(members); !reflect.DeepEqual(names, []string{"banana", "cherry"}) {
+		t.Errorf("expected [banana cherry], found %v", names)
+	}
+	members, _ = mainMap.SortedSetRangeByLex("z", bound("-"), bound("(banana"), true, 0, -1)
+	if names := memberNames(members); !reflect.DeepEqual(names, []string{"apple"}) {
+		t.Errorf("expected [apple], found %v", names)
+	}
+	if _, err := ParseLexBound("banana"); err == nil {
+		t.Error("expected a bound without [ or ( to be rejected")
+	}
+}
+
+func TestSortedSetPopAndRemove(t *testing.T) {
+	mainMap := leaderboard()
+	popped, _ := mainMap.SortedSetPop("z", 2, true)
+	if !reflect.DeepEqual(popped, []ScoredMember{{"e", 5}, {"d", 3}}) {
+		t.Fatalf("expected to pop e and d, found %v", popped)
+	}
+	if removed, _ := mainMap.SortedSetRemove("z", "a", "b", "missing"); removed != 2 {
+		t.Fatalf("expected 2 removed members, found %d", removed)
+	}
+	mainMap.SortedSetPop("z", 10, false)
+	if kind := mainMap.TypeOf("z"); kind != "none" {
+		t.Fatalf("expected an emptied sorted set to be deleted, found a %s", kind)
+	}
+	if _, err := mainMap.SortedSetAdd("z", map[string]float64{"a": math.NaN()}); !errors.Is(err, ErrNotFinite) {
+		t.Fatalf("expected a NaN score to be rejected, found %v", err)
+	}
+	if mainMap.TypeOf("z") != "none" {
+		t.Fatal("a rejected add created the key")
+	}
+}
@@ -0,0 +1,220 @@
+package schemas
+
+// porter holds a word being stemmed with the Porter algorithm. b[:k+1] is
+// the current word and j marks the end of the stem a suffix test left.
+type porter struct {
+	b []byte
+	k int
+	j int
+}
+
+// stem reduces an English word to its Porter stem, so "connection",
+// "connected" and "connecting" all become "connect". Words of two letters
+// or less and words with anything but a to z are returned unchanged.
+func stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+	p := &porter{b: []byte(word), k: len(word) - 1}
+	p.step1ab()
+	if p.k > 0 {
+		p.step1c()
+		p.step2()
+		p.step3()
+		p.step4()
+		p.step5()
+	}
+	return string(p.b[:p.k+1])
+}
+
+// consonant reports whether b[i] is a consonant. y is one at the start of
+// the word and after a vowel.
+func (p *porter) consonant(i int) bool {
+	switch p.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !p.consonant(i-1)
+	}
+	return true
+}
+
+// measure counts the vowel consonant sequences in b[:j+1].
+func (p *porter) measure() int {
+	n, i := 0, 0
+	for ; i <= p.j && p.consonant(i); i++ {
+	}
+	for i <= p.j {
+		for ; i <= p.j && !p.consonant(i); i++ {
+		}
+		if i > p.j {
+			return n
+		}
+		n++
+		for ; i <= p.j && p.consonant(i); i++ {
+		}
+	}
+	return n
+}
+
+func (p *porter) vowelInStem() bool {
+	for i := 0; i <= p.j; i++ {
+		if !p.consonant(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doubleConsonant reports whether b[i-1:i+1] is a doubled consonant.
+func (p *porter) doubleConsonant(i int) bool {
+	return i >= 1 && p.b[i] == p.b[i-1] && p.consonant(i)
+}
+
+// cvc reports whether b[i-2:i+1] is consonant, vowel, consonant with the
+// last not w, x or y, as in hop but not in snow.
+func (p *porter) cvc(i int) bool {
+	if i < 2 || !p.consonant(i) || p.consonant(i-1) || !p.consonant(i-2) {
+		return false
+	}
+	switch p.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// ends reports whether the word ends with suffix and sets j before it.
+func (p *porter) ends(suffix string) bool {
+	if len(suffix) > p.k+1 || string(p.b[p.k+1-len(suffix):p.k+1]) != suffix {
+		return false
+	}
+	p.j = p.k - len(suffix)
+	return true
+}
+
+// setTo replaces what follows j with replacement.
+func (p *porter) setTo(replacement string) {
+	p.b = append(p.b[:p.j+1], replacement...)
+	p.k = p.j + len(replacement)
+}
+
+// replaceSuffix swaps the first of the suffix, replacement pairs the word
+// ends with when the stem before it measures above minimum.
+func (p *porter) replaceSuffix(pairs []string, minimum int) {
+	for i := 0; i < len(pairs); i += 2 {
+		if p.ends(pairs[i]) {
+			if p.measure() > minimum {
+				p.setTo(pairs[i+1])
+			}
+			return
+		}
+	}
+}
+
+// step1ab removes plurals and -ed or -ing.
+func (p *porter) step1ab() {
+	if p.b[p.k] == 's' {
+		switch {
+		case p.ends("sses"):
+			p.k -= 2
+		case p.ends("ies"):
+			p.setTo("i")
+		case p.b[p.k-1] != 's':
+			p.k--
+		}
+	}
+	if p.ends("eed") {
+		if p.measure() > 0 {
+			p.k--
+		}
+		return
+	}
+	if (p.ends("ed") || p.ends("ing")) && p.vowelInStem() {
+		p.k = p.j
+		switch {
+		case p.ends("at"):
+			p.setTo("ate")
+		case p.ends("bl"):
+			p.setTo("ble")
+		case p.ends("iz"):
+			p.setTo("ize")
+		case p.doubleConsonant(p.k):
+			switch p.b[p.k] {
+			case 'l', 's', 'z':
+			default:
+				p.k--
+			}
+		case p.measure() == 1 && p.cvc(p.k):
+			p.setTo("e")
+		}
+	}
+}
+
+// step1c turns a final y into i when the stem has a vowel.
+func (p *porter) step1c() {
+	if p.ends("y") && p.vowelInStem() {
+		p.b[p.k] = 'i'
+	}
+}
+
+var porterStep2 = []string{
+	"ational", "ate", "tional", "tion", "enci", "ence", "anci", "ance", "izer", "ize",
+	"bli", "ble", "alli", "al", "entli", "ent", "eli", "e", "ousli", "ous",
+	"ization", "ize", "ation", "ate", "ator", "ate", "alism", "al", "iveness", "ive",
+	"fulness", "ful", "ousness", "ous", "aliti", "al", "iviti", "ive", "biliti", "ble", "logi", "log",
+}
+
+var porterStep3 = []string{
+	"icate", "ic", "ative", "", "alize", "al", "iciti", "ic", "ical", "ic", "ful", "", "ness", "",
+}
+
+// step2 maps double suffixes to single ones, as -ization to -ize.
+func (p *porter) step2() {
+	p.replaceSuffix(porterStep2, 0)
+}
+
+// step3 deals with -ic-, -full, -ness and the like.
+func (p *porter) step3() {
+	p.replaceSuffix(porterStep3, 0)
+}
+
+var porterStep4 = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent",
+	"ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+// step4 removes -ant, -ence and the like from stems measuring above one.
+func (p *porter) step4() {
+	for _, suffix := range porterStep4 {
+		if !p.ends(suffix) {
+			continue
+		}
+		if suffix == "ion" && (p.j < 0 || (p.b[p.j] != 's' && p.b[p.j] != 't')) {
+			continue
+		}
+		if p.measure() > 1 {
+			p.k = p.j
+		}
+		return
+	}
+}
+
+// step5 removes a final -e and turns -ll into -l on long stems.
+func (p *porter) step5() {
+	p.j = p.k
+	if p.b[p.k] == 'e' {
+		measure := p.measure()
+		if measure > 1 || (measure == 1 && !p.cvc(p.k-1)) {
+			p.k--
+		}
+	}
+	if p.b[p.k] == 'l' && p.doubleConsonant(p.k) && p.measure() > 1 {
+		p.k--
+	}
+}
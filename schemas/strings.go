@@ -0,0 +1,128 @@
+package schemas
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"in-memory-store/constants"
+	"strings"
+	"unicode/utf8"
+)
+
+// stringAt returns the string stored at key and whether it exists, or
+// ErrWrongType when the key holds another type.
+func (m *MainMap) stringAt(key string) (string, bool, error) {
+	if err := m.checkType(key, "string"); err != nil {
+		return "", false, err
+	}
+	value, ok := m.STRING_MAP[key]
+	return value, ok, nil
+}
+
+// AppendString appends value to the string at key, creating it when
+// absent, and returns the new length in bytes.
+func (m *MainMap) AppendString(key string, value string) (int, error) {
+	current, _, err := m.stringAt(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(current)+len(value) > constants.MAX_STRING_LENGTH {
+		return 0, fmt.Errorf("string would exceed %d bytes", constants.MAX_STRING_LENGTH)
+	}
+	zap.L().Info("Appending to String", zap.String("key", key), zap.Int("length", len(value)))
+	m.STRING_MAP[key] = current + value
+	m.touched(key)
+	return len(current) + len(value), nil
+}
+
+// StringLength returns the length of the string at key in bytes and in
+// runes, both 0 when the key is absent.
+func (m *MainMap) StringLength(key string) (int, int, error) {
+	value, _, err := m.stringAt(key)
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(value), utf8.RuneCountInString(value), nil
+}
+
+// GetRange returns the bytes from start to end inclusive of the string at
+// key. Negative offsets count from the end and both are clamped.
+func (m *MainMap) GetRange(key string, start int, end int) (string, error) {
+	value, _, err := m.stringAt(key)
+	if err != nil {
+		return "", err
+	}
+	from, to := normalizeRange(start, end, len(value))
+	return value[from:to], nil
+}
+
+// SetRange overwrites the string at key from byte offset on with value,
+// padding with zero bytes when the string is shorter than offset, and
+// returns the new length.
+func (m *MainMap) SetRange(key string, offset int, value string) (int, error) {
+	if offset < 0 {
+		return 0, fmt.Errorf("%w: offset %d", ErrIndexOutOfRange, offset)
+	}
+	// offset is checked before adding, as offsets near the largest int
+	// would overflow
+	if offset > constants.MAX_STRING_LENGTH-len(value) {
+		return 0, fmt.Errorf("string would exceed %d bytes", constants.MAX_STRING_LENGTH)
+	}
+	current, _, err := m.stringAt(key)
+	if err != nil {
+		return 0, err
+	}
+	if value == "" {
+		return len(current), nil
+	}
+	var builder strings.Builder
+	builder.Grow(max(len(current), offset+len(value)))
+	if offset <= len(current) {
+		builder.WriteString(current[:offset])
+	} else {
+		builder.WriteString(current)
+		builder.WriteString(strings.Repeat("\x00", offset-len(current)))
+	}
+	builder.WriteString(value)
+	if offset+len(value) < len(current) {
+		builder.WriteString(current[offset+len(value):])
+	}
+	zap.L().Info("Setting String range", zap.String("key", key), zap.Int("offset", offset), zap.Int("length", len(value)))
+	m.STRING_MAP[key] = builder.String()
+	m.touched(key)
+	return builder.Len(), nil
+}
+
+// GetSet stores value under key and returns what the key held before, nil
+// when it was absent.
+func (m *MainMap) GetSet(key string, value interface{}) (interface{}, error) {
+	previous := m.GetValue(key)
+	if err := m.SetValue(key, value); err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// GetDelete removes key and returns the value it held, nil when absent.
+func (m *MainMap) GetDelete(key string) interface{} {
+	previous := m.GetValue(key)
+	m.Delete(key)
+	return previous
+}
+
+// SetIfAbsent stores value only when key does not exist and reports
+// whether it did.
+func (m *MainMap) SetIfAbsent(key string, value interface{}) (bool, error) {
+	if m.TypeOf(key) != "none" {
+		return false, nil
+	}
+	return true, m.SetValue(key, value)
+}
+
+// SetIfPresent stores value only when key already exists and reports
+// whether it did.
+func (m *MainMap) SetIfPresent(key string, value interface{}) (bool, error) {
+	if m.TypeOf(key) == "none" {
+		return false, nil
+	}
+	return true, m.SetValue(key, value)
+}
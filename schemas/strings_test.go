@@ -0,0 +1,37 @@
+package schemas
+
+import (
+	"in-memory-store/constants"
+	"math"
+	"testing"
+)
+
+func TestSetRangeRejectsHugeOffsets(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.SetString("k", "abc")
+	for _, offset := range []int{math.MaxInt, math.MaxInt - 1, constants.MAX_STRING_LENGTH} {
+		if _, err := mainMap.SetRange("k", offset, "a"); err == nil {
+			t.Fatalf("expected offset %d to be rejected", offset)
+		}
+	}
+	if value := mainMap.GetValue("k"); value != "abc" {
+		t.Fatalf("expected the string to be left as \"abc\", found %v", value)
+	}
+}
+
+func TestSetRangePadsAndOverwrites(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.SetString("k", "hello world")
+	if length, err := mainMap.SetRange("k", 6, "there"); err != nil || length != 11 {
+		t.Fatalf("expected length 11, found %d, %v", length, err)
+	}
+	if length, err := mainMap.SetRange("padded", 2, "x"); err != nil || length != 3 {
+		t.Fatalf("expected length 3, found %d, %v", length, err)
+	}
+	if value := mainMap.GetValue("k"); value != "hello there" {
+		t.Fatalf("expected \"hello there\", found %q", value)
+	}
+	if value := mainMap.GetValue("padded"); value != "\x00\x00x" {
+		t.Fatalf("expected the string to be padded with zero bytes, found %q", value)
+	}
+}
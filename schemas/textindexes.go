@@ -0,0 +1,224 @@
+package schemas
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters: k1 caps how much repeating a term counts, b how much
+// long documents are penalised.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// TextIndex is an inverted index over the strings stored under any of its
+// prefixes. Words are lowercased and stemmed, and their positions kept for
+// phrase queries.
+type TextIndex struct {
+	Name     string
+	Prefixes []string
+	// postings maps a term to the keys holding it and its positions there
+	postings    map[string]map[string][]int
+	documents   map[string]textDocument
+	totalLength int
+}
+
+// textDocument remembers what an indexed string added so it can be taken
+// out again once the string is gone.
+type textDocument struct {
+	length int
+	terms  []string
+}
+
+// TextMatch is one key found by a text search.
+type TextMatch struct {
+	Key   string
+	Score float64
+}
+
+// Size returns how many strings the index holds.
+func (index *TextIndex) Size() int {
+	return len(index.documents)
+}
+
+// Terms returns how many distinct terms the index holds.
+func (index *TextIndex) Terms() int {
+	return len(index.postings)
+}
+
+func (index *TextIndex) covers(key string) bool {
+	for _, prefix := range index.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenize splits text into runs of letters and digits, lowercased and
+// stemmed.
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for i, word := range words {
+		words[i] = stem(word)
+	}
+	return words
+}
+
+func (index *TextIndex) add(key string, text string) {
+	terms := tokenize(text)
+	document := textDocument{length: len(terms)}
+	for position, term := range terms {
+		keys := index.postings[term]
+		if keys == nil {
+			keys = map[string][]int{}
+			index.postings[term] = keys
+		}
+		if keys[key] == nil {
+			document.terms = append(document.terms, term)
+		}
+		keys[key] = append(keys[key], position)
+	}
+	index.documents[key] = document
+	index.totalLength += document.length
+}
+
+func (index *TextIndex) remove(key string) {
+	document, ok := index.documents[key]
+	if !ok {
+		return
+	}
+	for _, term := range document.terms {
+		keys := index.postings[term]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(index.postings, term)
+		}
+	}
+	index.totalLength -= document.length
+	delete(index.documents, key)
+}
+
+// score ranks key against the query terms with BM25.
+func (index *TextIndex) score(key string, terms []string) float64 {
+	documents := float64(len(index.documents))
+	averageLength := float64(index.totalLength) / documents
+	length := float64(index.documents[key].length)
+	score := 0.0
+	for _, term := range terms {
+		keys := index.postings[term]
+		frequency := float64(len(keys[key]))
+		if frequency == 0 {
+			continue
+		}
+		found := float64(len(keys))
+		idf := math.Log(1 + (documents-found+0.5)/(found+0.5))
+		score += idf * frequency * (bm25K1 + 1) / (frequency + bm25K1*(1-bm25B+bm25B*length/averageLength))
+	}
+	return score
+}
+
+// CreateTextIndex registers an index over the strings stored under any of
+// prefixes and adds the existing ones.
+func (m *MainMap) CreateTextIndex(name string, prefixes []string) error {
+	if name == "" {
+		return fmt.Errorf("text index name must not be empty")
+	}
+	if len(prefixes) == 0 {
+		return fmt.Errorf("text index %s needs at least one prefix", name)
+	}
+	if _, ok := m.TEXT_INDEXES[name]; ok {
+		return fmt.Errorf("%w: %s", ErrIndexExists, name)
+	}
+	index := &TextIndex{
+		Name:      name,
+		Prefixes:  append([]string{}, prefixes...),
+		postings:  map[string]map[string][]int{},
+		documents: map[string]textDocument{},
+	}
+	for key, text := range m.STRING_MAP {
+		if index.covers(key) {
+			index.add(key, text)
+		}
+	}
+	zap.L().Info("Creating text index", zap.String("name", name), zap.Strings("prefixes", prefixes), zap.Int("documents", index.Size()))
+	m.TEXT_INDEXES[name] = index
+	return nil
+}
+
+// DropTextIndex removes the index and reports whether it existed.
+func (m *MainMap) DropTextIndex(name string) bool {
+	if _, ok := m.TEXT_INDEXES[name]; !ok {
+		return false
+	}
+	zap.L().Info("Dropping text index", zap.String("name", name))
+	delete(m.TEXT_INDEXES, name)
+	return true
+}
+
+// TextIndexes returns every index sorted by name.
+func (m *MainMap) TextIndexes() []*TextIndex {
+	indexes := make([]*TextIndex, 0, len(m.TEXT_INDEXES))
+	for _, index := range m.TEXT_INDEXES {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name < indexes[j].Name })
+	return indexes
+}
+
+// reindexText updates the text indexes covering key after its string was
+// set, changed or deleted.
+func (m *MainMap) reindexText(key string) {
+	text, ok := m.STRING_MAP[key]
+	for _, index := range m.TEXT_INDEXES {
+		if !index.covers(key) {
+			continue
+		}
+		index.remove(key)
+		if ok {
+			index.add(key, text)
+		}
+	}
+}
+
+// TextSearch returns the keys of the index matching query, best BM25
+// score first, and how many matched in total. offset matches are skipped
+// and at most count returned, all when count is negative. See
+// parseTextQuery for the query syntax.
+func (m *MainMap) TextSearch(name string, query string, offset int, count int) ([]TextMatch, int, error) {
+	index, ok := m.TEXT_INDEXES[name]
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %s", ErrNoSuchIndex, name)
+	}
+	if offset < 0 {
+		return nil, 0, fmt.Errorf("offset must not be negative, found %d", offset)
+	}
+	parsed, err := parseTextQuery(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	terms := parsed.rankedTerms(nil)
+	matches := []TextMatch{}
+	for key := range parsed.evaluate(index) {
+		matches = append(matches, TextMatch{Key: key, Score: index.score(key, terms)})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Key < matches[j].Key
+	})
+	total := len(matches)
+	matches = matches[min(offset, total):]
+	if count >= 0 && count < len(matches) {
+		matches = matches[:count]
+	}
+	return matches, total, nil
+}
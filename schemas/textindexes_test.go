@@ -0,0 +1,150 @@
+package schemas
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"cats":           "cat",
+		"feed":           "feed",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"sing":           "sing",
+		"hopping":        "hop",
+		"falling":        "fall",
+		"filing":         "file",
+		"happy":          "happi",
+		"relational":     "relat",
+		"generalization": "gener",
+		"connection":     "connect",
+		"connected":      "connect",
+		"connecting":     "connect",
+		"hopefulness":    "hope",
+		"adjustable":     "adjust",
+		"controlling":    "control",
+		"is":             "is",
+		"café":           "café",
+		"mp3s":           "mp3s",
+	}
+	for word, expected := range tests {
+		if stemmed := stem(word); stemmed != expected {
+			t.Errorf("expected %s to stem to %s, found %s", word, expected, stemmed)
+		}
+	}
+}
+
+// library indexes three books under book: and an unindexed note.
+func library(t *testing.T) *MainMap {
+	mainMap := CreateMainMap()
+	mainMap.SetString("book:1", "The quick brown fox jumps over the lazy dog")
+	mainMap.SetString("book:2", "A lazy afternoon: foxes sleeping, dogs sleeping")
+	mainMap.SetString("book:3", "Quick thinking and quick running win the race")
+	mainMap.SetString("note:1", "quick fox")
+	if err := mainMap.CreateTextIndex("books", []string{"book:"}); err != nil {
+		t.Fatal(err)
+	}
+	return mainMap
+}
+
+func searchKeys(t *testing.T, mainMap *MainMap, query string) []string {
+	t.Helper()
+	matches, total, err := mainMap.TextSearch("books", query, 0, -1)
+	if err != nil {
+		t.Fatalf("%q failed: %v", query, err)
+	}
+	if total != len(matches) {
+		t.Fatalf("%q: expected the total %d to count every match, found %d", query, len(matches), total)
+	}
+	keys := []string{}
+	for _, match := range matches {
+		keys = append(keys, match.Key)
+	}
+	return keys
+}
+
+func TestTextQueries(t *testing.T) {
+	mainMap := library(t)
+	tests := []struct {
+		query string
+		keys  []string
+	}{
+		{"fox", []string{"book:1", "book:2"}},
+		{"FOXES sleep", []string{"book:2"}},
+		{"fox AND quick", []string{"book:1"}},
+		{"fox OR race", []string{"book:1", "book:2", "book:3"}},
+		{"fox | race", []string{"book:1", "book:2", "book:3"}},
+		{"lazy -fox", []string{}},
+		{"quick NOT fox", []string{"book:3"}},
+		{`"lazy dog"`, []string{"book:1"}},
+		{`"dog lazy"`, []string{}},
+		{"(fox OR race) -sleeping", []string{"book:1", "book:3"}},
+		{"unknown", []string{}},
+	}
+	for _, test := range tests {
+		// the order is left to TestBM25Scores
+		keys := searchKeys(t, mainMap, test.query)
+		sort.Strings(keys)
+		if !reflect.DeepEqual(keys, test.keys) {
+			t.Errorf("%q: expected %v, found %v", test.query, test.keys, keys)
+		}
+	}
+}
+
+func TestTextQueryErrors(t *testing.T) {
+	for _, query := range []string{"", "   ", "-fox", "NOT fox", "a OR -b", "(a", "a)", `"open phrase`, "()"} {
+		if _, err := parseTextQuery(query); err == nil {
+			t.Errorf("expected %q to be rejected", query)
+		}
+	}
+}
+
+func TestBM25Scores(t *testing.T) {
+	mainMap := library(t)
+	matches, _, _ := mainMap.TextSearch("books", "quick", 0, -1)
+	// book:3 holds quick twice and ranks first
+	if len(matches) != 2 || matches[0].Key != "book:3" || matches[0].Score <= matches[1].Score {
+		t.Fatalf("expected book:3 to outrank book:1, found %v", matches)
+	}
+	// 2 of 3 documents hold quick, book:1 once in 9 words against an
+	// average of 8
+	idf := math.Log(1 + (3-2+0.5)/(2+0.5))
+	expected := idf * (bm25K1 + 1) / (1 + bm25K1*(1-bm25B+bm25B*9.0/8))
+	if math.Abs(matches[1].Score-expected) > 1e-9 {
+		t.Fatalf("expected book:1 to score %v, found %v", expected, matches[1].Score)
+	}
+	// excluded terms do not count towards the score
+	excluded, _, _ := mainMap.TextSearch("books", "quick -race", 0, -1)
+	if len(excluded) != 1 || excluded[0].Score != matches[1].Score {
+		t.Fatalf("expected book:1 alone with the same score, found %v", excluded)
+	}
+	page, total, _ := mainMap.TextSearch("books", "quick", 1, 1)
+	if total != 2 || len(page) != 1 || page[0].Key != "book:1" {
+		t.Fatalf("expected the second match of 2, found %v of %d", page, total)
+	}
+}
+
+func TestTextIndexFollowsWrites(t *testing.T) {
+	mainMap := library(t)
+	index := mainMap.TEXT_INDEXES["books"]
+	mainMap.SetString("book:1", "nothing to see")
+	mainMap.Delete("book:2")
+	mainMap.SetString("book:4", "a fox at last")
+	if keys := searchKeys(t, mainMap, "fox"); !reflect.DeepEqual(keys, []string{"book:4"}) {
+		t.Fatalf("expected only book:4, found %v", keys)
+	}
+	// 3, 8 and 4 words are left
+	if index.Size() != 3 || index.totalLength != 15 {
+		t.Fatalf("expected the index to count 3 documents and their words, found %d and %d", index.Size(), index.totalLength)
+	}
+	if _, _, err := mainMap.TextSearch("missing", "fox", 0, -1); !errors.Is(err, ErrNoSuchIndex) {
+		t.Fatalf("expected no such index, found %v", err)
+	}
+}
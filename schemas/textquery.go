@@ -0,0 +1,303 @@
+package schemas
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type textQueryKind int
+
+const (
+	// textTerms matches strings holding terms next to each other, a
+	// single word being a phrase of one term
+	textTerms textQueryKind = iota
+	textAnd
+	textOr
+	textNot
+)
+
+// textQuery is a node of a parsed text query.
+type textQuery struct {
+	kind     textQueryKind
+	terms    []string
+	children []*textQuery
+}
+
+// textQueryParser reads queries made of words and "quoted phrases".
+// Adjacent parts must all match; OR or | between them needs only one,
+// NOT or a leading - excludes a part, and parentheses group. OR binds
+// looser than the implicit AND, which may also be written out.
+type textQueryParser struct {
+	tokens []string
+	next   int
+}
+
+// parseTextQuery parses query into a tree of textQuery nodes.
+func parseTextQuery(query string) (*textQuery, error) {
+	tokens, err := lexTextQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	parser := &textQueryParser{tokens: tokens}
+	parsed, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.next < len(tokens) {
+		return nil, fmt.Errorf("unexpected %q in text query", tokens[parser.next])
+	}
+	if parsed == nil {
+		return nil, fmt.Errorf("text query %q has no words to search for", query)
+	}
+	if !parsed.bounded() {
+		return nil, fmt.Errorf("text query %q excludes words without anything to exclude them from", query)
+	}
+	return parsed, nil
+}
+
+// lexTextQuery splits query into parentheses, |, -, quoted phrases kept
+// with their quotes, and words.
+func lexTextQuery(query string) ([]string, error) {
+	tokens := []string{}
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == '|' || r == '-':
+			tokens = append(tokens, string(r))
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated phrase in text query")
+			}
+			tokens = append(tokens, string(runes[i:end+1]))
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(`()|"`, runes[end]) {
+				end++
+			}
+			tokens = append(tokens, string(runes[i:end]))
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+func (parser *textQueryParser) peek() string {
+	if parser.next < len(parser.tokens) {
+		return parser.tokens[parser.next]
+	}
+	return ""
+}
+
+func (parser *textQueryParser) parseOr() (*textQuery, error) {
+	node := &textQuery{kind: textOr}
+	for {
+		child, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			node.children = append(node.children, child)
+		}
+		if token := parser.peek(); token != "OR" && token != "|" {
+			break
+		}
+		parser.next++
+	}
+	return node.simplify(), nil
+}
+
+func (parser *textQueryParser) parseAnd() (*textQuery, error) {
+	node := &textQuery{kind: textAnd}
+	for {
+		switch parser.peek() {
+		case "", ")", "OR", "|":
+			return node.simplify(), nil
+		case "AND":
+			parser.next++
+			continue
+		}
+		child, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			node.children = append(node.children, child)
+		}
+	}
+}
+
+func (parser *textQueryParser) parseUnary() (*textQuery, error) {
+	token := parser.peek()
+	parser.next++
+	switch {
+	case token == "NOT" || token == "-":
+		child, err := parser.parseUnary()
+		if err != nil || child == nil {
+			return nil, err
+		}
+		return &textQuery{kind: textNot, children: []*textQuery{child}}, nil
+	case token == "(":
+		child, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if parser.peek() != ")" {
+			return nil, fmt.Errorf("missing ) in text query")
+		}
+		parser.next++
+		return child, nil
+	case token == "" || token == ")":
+		return nil, fmt.Errorf("text query ends early")
+	}
+	// words the tokenizer splits, like e-mail, are searched as phrases
+	terms := tokenize(strings.Trim(token, `"`))
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	return &textQuery{kind: textTerms, terms: terms}, nil
+}
+
+// simplify drops and or or nodes with a single child, nil when empty.
+func (node *textQuery) simplify() *textQuery {
+	switch len(node.children) {
+	case 0:
+		return nil
+	case 1:
+		return node.children[0]
+	}
+	return node
+}
+
+// bounded reports whether node can be evaluated without listing every
+// indexed string: NOT may only narrow an and that also has other parts.
+func (node *textQuery) bounded() bool {
+	switch node.kind {
+	case textTerms:
+		return true
+	case textOr:
+		for _, child := range node.children {
+			if !child.bounded() {
+				return false
+			}
+		}
+		return true
+	case textAnd:
+		positive := false
+		for _, child := range node.children {
+			if child.kind == textNot {
+				if !child.children[0].bounded() {
+					return false
+				}
+				continue
+			}
+			if !child.bounded() {
+				return false
+			}
+			positive = true
+		}
+		return positive
+	}
+	return false
+}
+
+// rankedTerms appends the terms that count towards the score, those not
+// excluded by NOT.
+func (node *textQuery) rankedTerms(terms []string) []string {
+	switch node.kind {
+	case textTerms:
+		return append(terms, node.terms...)
+	case textAnd, textOr:
+		for _, child := range node.children {
+			terms = child.rankedTerms(terms)
+		}
+	}
+	return terms
+}
+
+// evaluate returns the keys of index matching a bounded node.
+func (node *textQuery) evaluate(index *TextIndex) map[string]struct{} {
+	matched := map[string]struct{}{}
+	switch node.kind {
+	case textTerms:
+		for key := range index.postings[node.terms[0]] {
+			if index.phraseAt(key, node.terms) {
+				matched[key] = struct{}{}
+			}
+		}
+	case textOr:
+		for _, child := range node.children {
+			for key := range child.evaluate(index) {
+				matched[key] = struct{}{}
+			}
+		}
+	case textAnd:
+		excluded := []map[string]struct{}{}
+		first := true
+		for _, child := range node.children {
+			if child.kind == textNot {
+				excluded = append(excluded, child.children[0].evaluate(index))
+				continue
+			}
+			keys := child.evaluate(index)
+			if first {
+				matched, first = keys, false
+				continue
+			}
+			for key := range matched {
+				if _, ok := keys[key]; !ok {
+					delete(matched, key)
+				}
+			}
+		}
+		for _, keys := range excluded {
+			for key := range keys {
+				delete(matched, key)
+			}
+		}
+	}
+	return matched
+}
+
+// phraseAt reports whether terms appear one after another in key.
+func (index *TextIndex) phraseAt(key string, terms []string) bool {
+	positions := make([][]int, len(terms))
+	for i, term := range terms {
+		positions[i] = index.postings[term][key]
+		if positions[i] == nil {
+			return false
+		}
+	}
+starts:
+	for _, start := range positions[0] {
+		for i := 1; i < len(terms); i++ {
+			if !containsPosition(positions[i], start+i) {
+				continue starts
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// containsPosition searches the ascending positions for position.
+func containsPosition(positions []int, position int) bool {
+	low, high := 0, len(positions)
+	for low < high {
+		middle := (low + high) / 2
+		if positions[middle] < position {
+			low = middle + 1
+		} else {
+			high = middle
+		}
+	}
+	return low < len(positions) && positions[low] == position
+}
@@ -0,0 +1,271 @@
+package schemas
+
+import (
+	"container/heap"
+	"fmt"
+	"go.uber.org/zap"
+	"math"
+	"sort"
+	"strings"
+)
+
+// VectorMetric selects how vector distances are measured. Smaller
+// distances are always closer.
+type VectorMetric int
+
+const (
+	// VectorCosine is one minus the cosine similarity.
+	VectorCosine VectorMetric = iota
+	// VectorDot is the negated dot product.
+	VectorDot
+	// VectorL2 is the euclidean distance.
+	VectorL2
+)
+
+// Defaults for the HNSW parameters left at zero when creating an index.
+const (
+	defaultHNSWM              = 16
+	defaultHNSWEfConstruction = 200
+	defaultHNSWEfSearch       = 50
+)
+
+func ParseVectorMetric(name string) (VectorMetric, error) {
+	switch strings.ToUpper(name) {
+	case "COSINE":
+		return VectorCosine, nil
+	case "DOT":
+		return VectorDot, nil
+	case "L2":
+		return VectorL2, nil
+	}
+	return 0, fmt.Errorf("unknown vector metric %q, expected COSINE, DOT or L2", name)
+}
+
+func (metric VectorMetric) String() string {
+	switch metric {
+	case VectorDot:
+		return "DOT"
+	case VectorL2:
+		return "L2"
+	}
+	return "COSINE"
+}
+
+func (metric VectorMetric) distance(a []float64, b []float64) float64 {
+	switch metric {
+	case VectorDot:
+		dot := 0.0
+		for i := range a {
+			dot += a[i] * b[i]
+		}
+		return -dot
+	case VectorL2:
+		sum := 0.0
+		for i := range a {
+			difference := a[i] - b[i]
+			sum += difference * difference
+		}
+		return math.Sqrt(sum)
+	}
+	dot, normA, normB := 0.0, 0.0, 0.0
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 1
+	}
+	return 1 - dot/math.Sqrt(normA*normB)
+}
+
+// VectorIndex covers the float arrays whose key starts with Prefix and
+// whose length is Dimension. Without HNSW every search is an exact scan,
+// with it searches walk a graph kept up to date on every write.
+type VectorIndex struct {
+	Name           string
+	Prefix         string
+	Dimension      int
+	Metric         VectorMetric
+	HNSW           bool
+	M              int
+	EfConstruction int
+	EfSearch       int
+	graph          *hnswGraph
+}
+
+// Size returns the number of vectors in the graph, or -1 for exact
+// indexes which keep none.
+func (index *VectorIndex) Size() int {
+	if index.graph == nil {
+		return -1
+	}
+	return len(index.graph.ids)
+}
+
+func (index *VectorIndex) covers(key string, vector []float64) bool {
+	return strings.HasPrefix(key, index.Prefix) && len(vector) == index.Dimension
+}
+
+// VectorQuery tunes VectorSearch.
+type VectorQuery struct {
+	// Filter keeps only keys starting with it.
+	Filter string
+	// Exact scans every vector even when the index has a graph.
+	Exact bool
+	// EfSearch overrides the index's candidate list size when positive.
+	EfSearch int
+}
+
+type VectorMatch struct {
+	Key      string
+	Distance float64
+}
+
+// CreateVectorIndex registers index and adds the vectors already stored
+// under its prefix.
+func (m *MainMap) CreateVectorIndex(index VectorIndex) error {
+	if index.Name == "" {
+		return fmt.Errorf("vector index name must not be empty")
+	}
+	if _, ok := m.VECTOR_INDEXES[index.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrIndexExists, index.Name)
+	}
+	if index.Dimension <= 0 {
+		return fmt.Errorf("vector dimension must be positive, found %d", index.Dimension)
+	}
+	if index.M == 0 {
+		index.M = defaultHNSWM
+	}
+	if index.EfConstruction == 0 {
+		index.EfConstruction = defaultHNSWEfConstruction
+	}
+	if index.EfSearch == 0 {
+		index.EfSearch = defaultHNSWEfSearch
+	}
+	if index.M < 2 || index.EfConstruction < 1 || index.EfSearch < 1 {
+		return fmt.Errorf("M must be at least 2 and EF values positive")
+	}
+	if index.HNSW {
+		index.graph = newHNSWGraph(index.M, index.EfConstruction, index.Metric.distance)
+		keys := []string{}
+		for key, vector := range m.FLOAT_ARRAY_MAP {
+			if index.covers(key, vector) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			index.graph.insert(key, append([]float64{}, m.FLOAT_ARRAY_MAP[key]...))
+		}
+	}
+	zap.L().Info("Creating vector index", zap.String("name", index.Name), zap.String("prefix", index.Prefix),
+		zap.Int("dimension", index.Dimension), zap.Stringer("metric", index.Metric), zap.Bool("hnsw", index.HNSW))
+	m.VECTOR_INDEXES[index.Name] = &index
+	return nil
+}
+
+// DropVectorIndex removes the index and reports whether it existed. The
+// vectors themselves are kept.
+func (m *MainMap) DropVectorIndex(name string) bool {
+	if _, ok := m.VECTOR_INDEXES[name]; !ok {
+		return false
+	}
+	zap.L().Info("Dropping vector index", zap.String("name", name))
+	delete(m.VECTOR_INDEXES, name)
+	return true
+}
+
+// VectorIndexes returns every index sorted by name.
+func (m *MainMap) VectorIndexes() []*VectorIndex {
+	indexes := make([]*VectorIndex, 0, len(m.VECTOR_INDEXES))
+	for _, index := range m.VECTOR_INDEXES {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name < indexes[j].Name })
+	return indexes
+}
+
+// reindexVector brings the graphs covering key in line with the float
+// array now stored there, after it was set, changed or deleted.
+func (m *MainMap) reindexVector(key string) {
+	vector, ok := m.FLOAT_ARRAY_MAP[key]
+	for _, index := range m.VECTOR_INDEXES {
+		if index.graph == nil || !strings.HasPrefix(key, index.Prefix) {
+			continue
+		}
+		if ok && index.covers(key, vector) {
+			index.graph.insert(key, append([]float64{}, vector...))
+		} else {
+			index.graph.remove(key)
+		}
+	}
+}
+
+// VectorSearch returns the k vectors of the index closest to query,
+// nearest first. Graph searches are approximate and fall back to an exact
+// scan when they find fewer than k vectors while more may match, as when
+// a filter leaves few of the candidates or tombstones cut off live nodes.
+func (m *MainMap) VectorSearch(name string, query []float64, k int, options VectorQuery) ([]VectorMatch, error) {
+	index, ok := m.VECTOR_INDEXES[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoSuchIndex, name)
+	}
+	if len(query) != index.Dimension {
+		return nil, fmt.Errorf("%w: query has %d dimensions, index %s has %d", ErrDimensionMismatch, len(query), name, index.Dimension)
+	}
+	if k < 0 {
+		return nil, fmt.Errorf("k must not be negative, found %d", k)
+	}
+	for _, component := range query {
+		if math.IsNaN(component) || math.IsInf(component, 0) {
+			return nil, fmt.Errorf("%w: query vector", ErrNotFinite)
+		}
+	}
+	if k == 0 {
+		return []VectorMatch{}, nil
+	}
+	keep := func(key string) bool { return strings.HasPrefix(key, options.Filter) }
+	if index.graph != nil && !options.Exact {
+		ef := index.EfSearch
+		if options.EfSearch > 0 {
+			ef = options.EfSearch
+		}
+		found := index.graph.search(query, k, ef, keep)
+		// without a filter every live node matches, so finding all of
+		// them is complete too
+		if len(found) == k || (options.Filter == "" && len(found) == len(index.graph.ids)) {
+			matches := make([]VectorMatch, 0, len(found))
+			for _, item := range found {
+				matches = append(matches, VectorMatch{index.graph.nodes[item.id].key, item.distance})
+			}
+			return matches, nil
+		}
+	}
+	return m.exactVectorSearch(index, query, k, keep), nil
+}
+
+func (m *MainMap) exactVectorSearch(index *VectorIndex, query []float64, k int, keep func(key string) bool) []VectorMatch {
+	keys := []string{}
+	results := &candidateHeap{farthest: true}
+	for key, vector := range m.FLOAT_ARRAY_MAP {
+		if !index.covers(key, vector) || !keep(key) {
+			continue
+		}
+		distance := index.Metric.distance(query, vector)
+		if results.Len() == k && distance >= results.items[0].distance {
+			continue
+		}
+		keys = append(keys, key)
+		heap.Push(results, candidate{len(keys) - 1, distance})
+		if results.Len() > k {
+			heap.Pop(results)
+		}
+	}
+	matches := make([]VectorMatch, results.Len())
+	for i := len(matches) - 1; i >= 0; i-- {
+		item := heap.Pop(results).(candidate)
+		matches[i] = VectorMatch{keys[item.id], item.distance}
+	}
+	return matches
+}
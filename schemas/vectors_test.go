@@ -0,0 +1,159 @@
+package schemas
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// randomVectors stores n random vectors of dimension under prefix.
+func randomVectors(mainMap *MainMap, random *rand.Rand, prefix string, n int, dimension int) {
+	for i := 0; i < n; i++ {
+		vector := make([]float64, dimension)
+		for j := range vector {
+			vector[j] = random.Float64()*2 - 1
+		}
+		mainMap.SetFloatArray(prefix+strconv.Itoa(i), vector)
+	}
+}
+
+func randomQuery(random *rand.Rand, dimension int) []float64 {
+	query := make([]float64, dimension)
+	for i := range query {
+		query[i] = random.Float64()*2 - 1
+	}
+	return query
+}
+
+func TestVectorDistances(t *testing.T) {
+	a, b := []float64{1, 0}, []float64{0, 2}
+	tests := []struct {
+		metric   VectorMetric
+		distance float64
+	}{
+		{VectorCosine, 1},
+		{VectorDot, 0},
+		{VectorL2, math.Sqrt(5)},
+	}
+	for _, test := range tests {
+		if distance := test.metric.distance(a, b); math.Abs(distance-test.distance) > 1e-12 {
+			t.Errorf("%s: expected %v, found %v", test.metric, test.distance, distance)
+		}
+	}
+	if distance := VectorCosine.distance([]float64{0, 0}, a); distance != 1 {
+		t.Errorf("expected a zero vector to be at cosine distance 1, found %v", distance)
+	}
+}
+
+func TestHNSWRecall(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	mainMap := CreateMainMap()
+	randomVectors(mainMap, random, "v:", 2000, 16)
+	for _, name := range []string{"graph", "exact"} {
+		err := mainMap.CreateVectorIndex(VectorIndex{Name: name, Prefix: "v:", Dimension: 16, Metric: VectorL2, HNSW: name == "graph"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	found, total := 0, 0
+	for i := 0; i < 50; i++ {
+		query := randomQuery(random, 16)
+		exact, _ := mainMap.VectorSearch("exact", query, 10, VectorQuery{})
+		approximate, _ := mainMap.VectorSearch("graph", query, 10, VectorQuery{})
+		nearest := map[string]bool{}
+		for _, match := range exact {
+			nearest[match.Key] = true
+		}
+		for _, match := range approximate {
+			if nearest[match.Key] {
+				found++
+			}
+		}
+		total += len(exact)
+	}
+	if recall := float64(found) / float64(total); recall < 0.9 {
+		t.Fatalf("expected a recall of at least 0.9, found %.2f", recall)
+	}
+}
+
+func TestHNSWSkipsTombstones(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	mainMap := CreateMainMap()
+	randomVectors(mainMap, random, "v:", 300, 8)
+	mainMap.CreateVectorIndex(VectorIndex{Name: "graph", Prefix: "v:", Dimension: 8, Metric: VectorCosine, HNSW: true})
+	// deleted vectors and vectors of another dimension leave the graph
+	for i := 0; i < 250; i++ {
+		if i%2 == 0 {
+			mainMap.Delete("v:" + strconv.Itoa(i))
+		} else {
+			mainMap.SetFloatArray("v:"+strconv.Itoa(i), []float64{1})
+		}
+	}
+	index := mainMap.VECTOR_INDEXES["graph"]
+	if index.Size() != 50 {
+		t.Fatalf("expected 50 vectors in the graph, found %d", index.Size())
+	}
+	if index.graph.deleted > len(index.graph.ids) {
+		t.Fatalf("expected the graph to be rebuilt once tombstones outnumber live nodes, found %d of them", index.graph.deleted)
+	}
+	for i := 0; i < 20; i++ {
+		matches, _ := mainMap.VectorSearch("graph", randomQuery(random, 8), 10, VectorQuery{})
+		if len(matches) != 10 {
+			t.Fatalf("expected 10 matches, found %d", len(matches))
+		}
+		for _, match := range matches {
+			if number, _ := strconv.Atoi(strings.TrimPrefix(match.Key, "v:")); number < 250 {
+				t.Fatalf("a removed vector %s was returned", match.Key)
+			}
+		}
+	}
+	// with every vector gone the graph is empty and searches find nothing
+	for i := 250; i < 300; i++ {
+		mainMap.Delete("v:" + strconv.Itoa(i))
+	}
+	if matches, _ := mainMap.VectorSearch("graph", randomQuery(random, 8), 10, VectorQuery{}); len(matches) != 0 || index.Size() != 0 {
+		t.Fatalf("expected an empty graph, found %d matches", len(matches))
+	}
+}
+
+func TestFilteredSearchesFallBackToAnExactScan(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	mainMap := CreateMainMap()
+	randomVectors(mainMap, random, "v:common:", 1000, 8)
+	randomVectors(mainMap, random, "v:rare:", 5, 8)
+	mainMap.CreateVectorIndex(VectorIndex{Name: "graph", Prefix: "v:", Dimension: 8, Metric: VectorL2, HNSW: true, EfSearch: 10})
+	// the graph search sees few rare vectors among its candidates, the
+	// exact scan then finds all of them
+	matches, err := mainMap.VectorSearch("graph", randomQuery(random, 8), 5, VectorQuery{Filter: "v:rare:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 5 {
+		t.Fatalf("expected all 5 rare vectors, found %v", matches)
+	}
+	for i := 1; i < len(matches); i++ {
+		if matches[i].Distance < matches[i-1].Distance {
+			t.Fatalf("expected the matches nearest first, found %v", matches)
+		}
+	}
+}
+
+func TestVectorSearchErrors(t *testing.T) {
+	mainMap := CreateMainMap()
+	mainMap.CreateVectorIndex(VectorIndex{Name: "index", Dimension: 2})
+	if err := mainMap.CreateVectorIndex(VectorIndex{Name: "index", Dimension: 2}); !errors.Is(err, ErrIndexExists) {
+		t.Errorf("expected a duplicate index to be rejected, found %v", err)
+	}
+	if _, err := mainMap.VectorSearch("missing", []float64{1, 0}, 1, VectorQuery{}); !errors.Is(err, ErrNoSuchIndex) {
+		t.Errorf("expected no such index, found %v", err)
+	}
+	if _, err := mainMap.VectorSearch("index", []float64{1}, 1, VectorQuery{}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("expected a dimension mismatch, found %v", err)
+	}
+	if _, err := mainMap.VectorSearch("index", []float64{math.NaN(), 0}, 1, VectorQuery{}); !errors.Is(err, ErrNotFinite) {
+		t.Errorf("expected a NaN query to be rejected, found %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package schemas
+
+import (
+	"go.uber.org/zap"
+)
+
+// Every change to a key gives it the next value of the database's version
+// clock, so versions only grow, and a key that is removed and created
+// again never gets a version it had before. Absent keys have version 0.
+
+// bumpVersion records a change to key, forgetting its version when it was
+// removed.
+func (m *MainMap) bumpVersion(key string, exists bool) {
+	if !exists {
+		delete(m.versions, key)
+		return
+	}
+	m.versionClock++
+	m.versions[key] = m.versionClock
+}
+
+// Version returns the version of key, 0 when it is absent.
+func (m *MainMap) Version(key string) int64 {
+	return m.versions[key]
+}
+
+// SetIfVersion stores value like SetValue when key is at version, 0
+// meaning absent, and returns the new version and whether it was stored.
+func (m *MainMap) SetIfVersion(key string, value interface{}, version int64) (int64, bool, error) {
+	if current := m.Version(key); current != version {
+		zap.L().Info("Version mismatch, not setting", zap.String("key", key), zap.Int64("expected", version), zap.Int64("current", current))
+		return current, false, nil
+	}
+	if err := m.SetValue(key, value); err != nil {
+		return 0, false, err
+	}
+	return m.Version(key), true, nil
+}
+
+// DeleteIfVersion removes key when it exists at version and reports
+// whether it did.
+func (m *MainMap) DeleteIfVersion(key string, version int64) bool {
+	if current := m.Version(key); current == 0 || current != version {
+		zap.L().Info("Version mismatch, not deleting", zap.String("key", key), zap.Int64("expected", version), zap.Int64("current", current))
+		return false
+	}
+	return m.Delete(key)
+}
+
+// Versions returns the version clock and the version of every key, for
+// snapshots. The map must not be modified.
+func (m *MainMap) Versions() (int64, map[string]int64) {
+	return m.versionClock, m.versions
+}
+
+// RestoreVersions puts back versions read from a snapshot after the keys
+// were loaded. Keys that are absent are skipped and the clock never goes
+// back.
+func (m *MainMap) RestoreVersions(clock int64, versions map[string]int64) {
+	for key, version := range versions {
+		if m.TypeOf(key) != "none" {
+			m.versions[key] = version
+			clock = max(clock, version)
+		}
+	}
+	m.versionClock = max(m.versionClock, clock)
+}
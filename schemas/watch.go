@@ -0,0 +1,41 @@
+package schemas
+
+import (
+	"sync/atomic"
+)
+
+// Watch notices when any of the keys it watches is created, changed or
+// removed. Keys of several databases may share one Watch, so the flag is
+// set under whichever database lock the change held.
+type Watch struct {
+	changed atomic.Bool
+}
+
+func (watch *Watch) Changed() bool {
+	return watch.changed.Load()
+}
+
+// Watch registers watch on key until Unwatch is called.
+func (m *MainMap) Watch(key string, watch *Watch) {
+	watches := m.watches[key]
+	if watches == nil {
+		watches = map[*Watch]struct{}{}
+		m.watches[key] = watches
+	}
+	watches[watch] = struct{}{}
+}
+
+func (m *MainMap) Unwatch(key string, watch *Watch) {
+	watches := m.watches[key]
+	delete(watches, watch)
+	if len(watches) == 0 {
+		delete(m.watches, key)
+	}
+}
+
+// notifyWatches flags the watches on key as changed.
+func (m *MainMap) notifyWatches(key string) {
+	for watch := range m.watches[key] {
+		watch.changed.Store(true)
+	}
+}
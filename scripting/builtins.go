@@ -0,0 +1,284 @@
+package scripting
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// builtins are the functions every script can call. None of them reads
+// the clock or a random source, so a script always does the same with the
+// same data, keys and arguments.
+var builtins = map[string]builtin{
+	"call":     callBuiltin,
+	"error":    errorBuiltin,
+	"type":     typeBuiltin,
+	"tostring": tostringBuiltin,
+	"tonumber": tonumberBuiltin,
+	"ipairs":   ipairsBuiltin,
+	"insert":   insertBuiltin,
+	"remove":   removeBuiltin,
+	"sub":      subBuiltin,
+	"upper":    upperBuiltin,
+	"lower":    lowerBuiltin,
+	"floor":    floorBuiltin,
+	"ceil":     ceilBuiltin,
+	"abs":      absBuiltin,
+	"min":      minBuiltin,
+	"max":      maxBuiltin,
+}
+
+// arity fails unless between least and most arguments were given; most is
+// -1 when there is no upper bound.
+func arity(name string, line int, args []interface{}, least int, most int) error {
+	if len(args) < least || (most >= 0 && len(args) > most) {
+		return runtimeError(line, "wrong number of arguments for %s", name)
+	}
+	return nil
+}
+
+// callBuiltin implements call(name, ...), running a store command and
+// returning its reply. A failing command stops the script.
+func callBuiltin(in *interpreter, line int, args []interface{}) (interface{}, error) {
+	if err := arity("call", line, args, 1, -1); err != nil {
+		return nil, err
+	}
+	name, ok := args[0].(string)
+	if !ok {
+		return nil, runtimeError(line, "call expects a command name, found %s", describe(args[0]))
+	}
+	arguments := make([]interface{}, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		argument, err := toArgument(line, arg)
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, argument)
+	}
+	reply, err := in.call(name, arguments)
+	if err != nil {
+		return nil, &Error{Line: line, Err: err}
+	}
+	return fromReply(reply), nil
+}
+
+// errorBuiltin implements error(message), stopping the script.
+func errorBuiltin(in *interpreter, line int, args []interface{}) (interface{}, error) {
+	if err := arity("error", line, args, 1, 1); err != nil {
+		return nil, err
+	}
+	message, ok := concatenable(args[0])
+	if !ok {
+		message = typeName(args[0])
+	}
+	return nil, &Error{Line: line, Err: errors.New(message)}
+}
+
+func typeBuiltin(in *interpreter, line int, args []interface{}) (interface{}, error) {
+	if err := arity("type", line, args, 1, 1); err != nil {
+		return nil, err
+	}
+	return typeName(args[0]), nil
+}
+
+func tostringBuiltin(in *interpreter, line int, args []interface{}) (interface{}, error) {
+	if err := arity("tostring", line, args, 1, 1); err != nil {
+		return nil, err
+	}
+	if text, ok := concatenable(args[0]); ok {
+		return text, nil
+	}
+	if b, ok := args[0].(bool); ok {
+		return strconv.FormatBool(b), nil
+	}
+	return typeName(args[0]), nil
+}
+
+// tonumberBuiltin returns numbers as they are and parses strings, giving
+// nil for anything that is not a number.
+func tonumberBuiltin(in *interpreter, line int, args []interface{}) (interface{}, error) {
+	if err := arity("tonumber", line, args, 1, 1); err != nil {
+		return nil, err
+	}
+	switch v := args[0].(type) {
+	case int64, float64:
+		return v, nil
+	case string:
+		text := strings.TrimSpace(v)
+		if integer, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return integer, nil
+		}
+		if float, err := strconv.ParseFloat(text, 64); err == nil {
+			return float, nil
+		}
+	}
+	return nil, nil
+}
+
+// ipairsBuiltin returns its list, so for i, v in ipairs(list) reads as it
+// would in Lua.
+func ipairsBuiltin(in *interpreter, line int, args []interface{}) (interface{}, error) {
+	if err := arity("ipairs", line, args, 1, 1); err != nil {
+		return nil, err
+	}
+	if _, ok := args[0].(*list); !ok {
+		return nil, runtimeError(line, "ipairs expects a list, found %s", describe(args[0]))
+	}
+	return args[0], nil
+}
+
+// insertBuiltin implements insert(list, value), appending value.
+func insertBuiltin(in *interpreter, line int, args []interface{}) (interface{}, error) {
+	if err := arity("insert", line, args, 2, 2); err != nil {
+		return nil, err
+	}
+	items, ok := args[0].(*list)
+	if !ok {
+		return nil, runtimeError(line, "insert expects a list, found %s", describe(args[0]))
+	}
+	items.items = append(items.items, args[1])
+	return nil, nil
+}
+
+// removeBuiltin implements remove(list), removing and returning the last
+// item, nil when the list is empty.
+func removeBuiltin(in *interpreter, line int, args []interface{}) (interface{}, error) {
+	if err := arity("remove", line, args, 1, 1); err != nil {
+		return nil, err
+	}
+	items, ok := args[0].(*list)
+	if !ok {
+		return nil, runtimeError(line, "remove expects a list, found %s", describe(args[0]))
+	}
+	if len(items.items) == 0 {
+		return nil, nil
+	}
+	last := items.items[len(items.items)-1]
+	items.items = items.items[:len(items.items)-1]
+	return last, nil
+}
+
+// subBuiltin implements sub(s, i [, j]), the bytes from i to j counted
+// from 1, with negative positions counted from the end.
+func subBuiltin(in *interpreter, line int, args []interface{}) (interface{}, error) {
+	if err := arity("sub", line, args, 2, 3); err != nil {
+		return nil, err
+	}
+	text, ok := args[0].(string)
+	if !ok {
+		return nil, runtimeError(line, "sub expects a string, found %s", describe(args[0]))
+	}
+	bounds := []int64{0, -1}
+	for i, arg := range args[1:] {
+		bound, ok := toInteger(arg)
+		if !ok {
+			return nil, runtimeError(line, "sub expects integer positions, found %s", describe(arg))
+		}
+		bounds[i] = bound
+	}
+	length := int64(len(text))
+	start, stop := bounds[0], bounds[1]
+	if start < 0 {
+		start = max(length+start+1, 1)
+	} else if start == 0 {
+		start = 1
+	}
+	if stop < 0 {
+		stop = length + stop + 1
+	}
+	stop = min(stop, length)
+	if start > stop {
+		return "", nil
+	}
+	return text[start-1 : stop], nil
+}
+
+func upperBuiltin(in *interpreter, line int, args []interface{}) (interface{}, error) {
+	if err := arity("upper", line, args, 1, 1); err != nil {
+		return nil, err
+	}
+	text, ok := args[0].(string)
+	if !ok {
+		return nil, runtimeError(line, "upper expects a string, found %s", describe(args[0]))
+	}
+	return strings.ToUpper(text), nil
+}
+
+func lowerBuiltin(in *interpreter, line int, args []interface{}) (interface{}, error) {
+	if err := arity("lower", line, args, 1, 1); err != nil {
+		return nil, err
+	}
+	text, ok := args[0].(string)
+	if !ok {
+		return nil, runtimeError(line, "lower expects a string, found %s", describe(args[0]))
+	}
+	return strings.ToLower(text), nil
+}
+
+// rounding applies round to a number and returns an integer when the
+// result fits in one.
+func rounding(name string, round func(float64) float64) builtin {
+	return func(in *interpreter, line int, args []interface{}) (interface{}, error) {
+		if err := arity(name, line, args, 1, 1); err != nil {
+			return nil, err
+		}
+		switch v := args[0].(type) {
+		case int64:
+			return v, nil
+		case float64:
+			rounded := round(v)
+			if integer, ok := toInteger(rounded); ok {
+				return integer, nil
+			}
+			return rounded, nil
+		}
+		return nil, runtimeError(line, "%s expects a number, found %s", name, describe(args[0]))
+	}
+}
+
+var floorBuiltin = rounding("floor", math.Floor)
+
+var ceilBuiltin = rounding("ceil", math.Ceil)
+
+func absBuiltin(in *interpreter, line int, args []interface{}) (interface{}, error) {
+	if err := arity("abs", line, args, 1, 1); err != nil {
+		return nil, err
+	}
+	switch v := args[0].(type) {
+	case int64:
+		if v < 0 {
+			return -v, nil
+		}
+		return v, nil
+	case float64:
+		return math.Abs(v), nil
+	}
+	return nil, runtimeError(line, "abs expects a number, found %s", describe(args[0]))
+}
+
+// extreme returns the argument that wins every comparison against the
+// others by the given order.
+func extreme(name string, wins func(order int) bool) builtin {
+	return func(in *interpreter, line int, args []interface{}) (interface{}, error) {
+		if err := arity(name, line, args, 1, -1); err != nil {
+			return nil, err
+		}
+		best := args[0]
+		for _, arg := range args {
+			bestNumber, bestOk := toFloat(best)
+			number, ok := toFloat(arg)
+			if !bestOk || !ok {
+				return nil, runtimeError(line, "%s expects numbers, found %s", name, describe(arg))
+			}
+			if wins(compareFloats(number, bestNumber)) {
+				best = arg
+			}
+		}
+		return best, nil
+	}
+}
+
+var minBuiltin = extreme("min", func(order int) bool { return order < 0 })
+
+var maxBuiltin = extreme("max", func(order int) bool { return order > 0 })
@@ -0,0 +1,785 @@
+package scripting
+
+import (
+	"fmt"
+	"in-memory-store/constants"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Values inside a script are nil, bool, int64, float64, string, *list,
+// *closure and builtin.
+
+// list is the only compound value. Lists are shared by reference and
+// indexed from 1.
+type list struct {
+	items []interface{}
+}
+
+// closure is a script function with the scope it was declared in.
+type closure struct {
+	function *functionExpression
+	scope    *scope
+}
+
+// builtin is a function provided by the interpreter. line is where it was
+// called, for errors.
+type builtin func(in *interpreter, line int, args []interface{}) (interface{}, error)
+
+// scope holds the locals of one block and links to the enclosing one.
+type scope struct {
+	names  map[string]interface{}
+	parent *scope
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{names: map[string]interface{}{}, parent: parent}
+}
+
+// lookup finds the scope declaring name, nil when none does.
+func (s *scope) lookup(name string) *scope {
+	for current := s; current != nil; current = current.parent {
+		if _, ok := current.names[name]; ok {
+			return current
+		}
+	}
+	return nil
+}
+
+// interpreter runs one script and enforces its limits.
+type interpreter struct {
+	call     Caller
+	maxSteps int64
+	deadline time.Time
+	steps    int64
+	depth    int
+}
+
+// checkDeadlineEvery is how many steps run between two looks at the clock.
+const checkDeadlineEvery = 1024
+
+// step counts one unit of work at line and stops the script past its step
+// or time limit.
+func (in *interpreter) step(line int) error {
+	in.steps++
+	if in.maxSteps > 0 && in.steps > in.maxSteps {
+		return &Error{Line: line, Err: fmt.Errorf("script exceeded the limit of %d steps", in.maxSteps)}
+	}
+	if in.steps%checkDeadlineEvery == 0 && !in.deadline.IsZero() && time.Now().After(in.deadline) {
+		return &Error{Line: line, Err: fmt.Errorf("script exceeded its time limit")}
+	}
+	return nil
+}
+
+func runtimeError(line int, format string, args ...interface{}) error {
+	return &Error{Line: line, Err: fmt.Errorf(format, args...)}
+}
+
+// flow tells the enclosing statements how a statement ended.
+type flow int
+
+const (
+	flowNormal flow = iota
+	flowBreak
+	flowReturn
+)
+
+type statement interface {
+	execute(in *interpreter, env *scope) (flow, interface{}, error)
+}
+
+type expression interface {
+	evaluate(in *interpreter, env *scope) (interface{}, error)
+}
+
+type block struct {
+	statements []statement
+}
+
+// run executes the block in a new scope inside env.
+func (b *block) run(in *interpreter, env *scope) (flow, interface{}, error) {
+	inner := newScope(env)
+	for _, next := range b.statements {
+		result, value, err := next.execute(in, inner)
+		if err != nil || result != flowNormal {
+			return result, value, err
+		}
+	}
+	return flowNormal, nil, nil
+}
+
+type localStatement struct {
+	name  string
+	value expression
+	line  int
+}
+
+func (s *localStatement) execute(in *interpreter, env *scope) (flow, interface{}, error) {
+	if err := in.step(s.line); err != nil {
+		return flowNormal, nil, err
+	}
+	var value interface{}
+	if s.value != nil {
+		var err error
+		if value, err = s.value.evaluate(in, env); err != nil {
+			return flowNormal, nil, err
+		}
+	}
+	env.names[s.name] = value
+	return flowNormal, nil, nil
+}
+
+// localFunctionStatement declares the name before creating the closure so
+// the function can call itself.
+type localFunctionStatement struct {
+	name     string
+	function *functionExpression
+}
+
+func (s *localFunctionStatement) execute(in *interpreter, env *scope) (flow, interface{}, error) {
+	env.names[s.name] = &closure{function: s.function, scope: env}
+	return flowNormal, nil, nil
+}
+
+type assignStatement struct {
+	target expression
+	value  expression
+	line   int
+}
+
+func (s *assignStatement) execute(in *interpreter, env *scope) (flow, interface{}, error) {
+	if err := in.step(s.line); err != nil {
+		return flowNormal, nil, err
+	}
+	value, err := s.value.evaluate(in, env)
+	if err != nil {
+		return flowNormal, nil, err
+	}
+	switch target := s.target.(type) {
+	case *variable:
+		declared := env.lookup(target.name)
+		if declared == nil {
+			if _, ok := builtins[target.name]; ok {
+				return flowNormal, nil, runtimeError(s.line, "can not assign to builtin %s", target.name)
+			}
+			return flowNormal, nil, runtimeError(s.line, "assignment to undeclared variable %s, declare it with local", target.name)
+		}
+		declared.names[target.name] = value
+	case *indexExpression:
+		container, err := target.target.evaluate(in, env)
+		if err != nil {
+			return flowNormal, nil, err
+		}
+		items, ok := container.(*list)
+		if !ok {
+			return flowNormal, nil, runtimeError(s.line, "can not index a %s value", typeName(container))
+		}
+		index, err := target.index.evaluate(in, env)
+		if err != nil {
+			return flowNormal, nil, err
+		}
+		position, ok := toInteger(index)
+		if !ok || position < 1 || position > int64(len(items.items))+1 {
+			return flowNormal, nil, runtimeError(s.line, "list index %s out of range, lists may only grow by one at the end", describe(index))
+		}
+		if position == int64(len(items.items))+1 {
+			items.items = append(items.items, value)
+		} else {
+			items.items[position-1] = value
+		}
+	}
+	return flowNormal, nil, nil
+}
+
+type callStatement struct {
+	call *callExpression
+}
+
+func (s *callStatement) execute(in *interpreter, env *scope) (flow, interface{}, error) {
+	_, err := s.call.evaluate(in, env)
+	return flowNormal, nil, err
+}
+
+type ifStatement struct {
+	conditions []expression
+	bodies     []*block
+	otherwise  *block
+}
+
+func (s *ifStatement) execute(in *interpreter, env *scope) (flow, interface{}, error) {
+	for i, condition := range s.conditions {
+		value, err := condition.evaluate(in, env)
+		if err != nil {
+			return flowNormal, nil, err
+		}
+		if truthy(value) {
+			return s.bodies[i].run(in, env)
+		}
+	}
+	if s.otherwise != nil {
+		return s.otherwise.run(in, env)
+	}
+	return flowNormal, nil, nil
+}
+
+type whileStatement struct {
+	condition expression
+	body      *block
+	line      int
+}
+
+func (s *whileStatement) execute(in *interpreter, env *scope) (flow, interface{}, error) {
+	for {
+		if err := in.step(s.line); err != nil {
+			return flowNormal, nil, err
+		}
+		value, err := s.condition.evaluate(in, env)
+		if err != nil || !truthy(value) {
+			return flowNormal, nil, err
+		}
+		result, returned, err := s.body.run(in, env)
+		if err != nil || result == flowReturn {
+			return result, returned, err
+		}
+		if result == flowBreak {
+			return flowNormal, nil, nil
+		}
+	}
+}
+
+type numericForStatement struct {
+	name              string
+	start, stop, step expression
+	body              *block
+	line              int
+}
+
+func (s *numericForStatement) execute(in *interpreter, env *scope) (flow, interface{}, error) {
+	bounds := []interface{}{nil, nil, int64(1)}
+	for i, bound := range []expression{s.start, s.stop, s.step} {
+		if bound == nil {
+			continue
+		}
+		value, err := bound.evaluate(in, env)
+		if err != nil {
+			return flowNormal, nil, err
+		}
+		if _, ok := value.(int64); !ok {
+			return flowNormal, nil, runtimeError(s.line, "for bounds must be integers, found %s", describe(value))
+		}
+		bounds[i] = value
+	}
+	start, stop, step := bounds[0].(int64), bounds[1].(int64), bounds[2].(int64)
+	if step == 0 {
+		return flowNormal, nil, runtimeError(s.line, "for step must not be 0")
+	}
+	for i := start; (step > 0 && i <= stop) || (step < 0 && i >= stop); i += step {
+		if err := in.step(s.line); err != nil {
+			return flowNormal, nil, err
+		}
+		inner := newScope(env)
+		inner.names[s.name] = i
+		result, returned, err := s.body.run(in, inner)
+		if err != nil || result == flowReturn {
+			return result, returned, err
+		}
+		if result == flowBreak {
+			break
+		}
+		// stop before i overflows past stop
+		if (step > 0 && i > math.MaxInt64-step) || (step < 0 && i < math.MinInt64-step) {
+			break
+		}
+	}
+	return flowNormal, nil, nil
+}
+
+// listForStatement walks a list by index and value. Items appended while
+// it runs are visited too.
+type listForStatement struct {
+	index, value string
+	list         expression
+	body         *block
+	line         int
+}
+
+func (s *listForStatement) execute(in *interpreter, env *scope) (flow, interface{}, error) {
+	value, err := s.list.evaluate(in, env)
+	if err != nil {
+		return flowNormal, nil, err
+	}
+	items, ok := value.(*list)
+	if !ok {
+		return flowNormal, nil, runtimeError(s.line, "for in needs a list, found %s", describe(value))
+	}
+	for i := 0; i < len(items.items); i++ {
+		if err := in.step(s.line); err != nil {
+			return flowNormal, nil, err
+		}
+		inner := newScope(env)
+		inner.names[s.index] = int64(i + 1)
+		inner.names[s.value] = items.items[i]
+		result, returned, err := s.body.run(in, inner)
+		if err != nil || result == flowReturn {
+			return result, returned, err
+		}
+		if result == flowBreak {
+			break
+		}
+	}
+	return flowNormal, nil, nil
+}
+
+type doStatement struct {
+	body *block
+}
+
+func (s *doStatement) execute(in *interpreter, env *scope) (flow, interface{}, error) {
+	return s.body.run(in, env)
+}
+
+type breakStatement struct{}
+
+func (s *breakStatement) execute(in *interpreter, env *scope) (flow, interface{}, error) {
+	return flowBreak, nil, nil
+}
+
+type returnStatement struct {
+	value expression
+	line  int
+}
+
+func (s *returnStatement) execute(in *interpreter, env *scope) (flow, interface{}, error) {
+	if s.value == nil {
+		return flowReturn, nil, nil
+	}
+	value, err := s.value.evaluate(in, env)
+	return flowReturn, value, err
+}
+
+type constant struct {
+	value interface{}
+}
+
+func (e *constant) evaluate(in *interpreter, env *scope) (interface{}, error) {
+	return e.value, nil
+}
+
+type variable struct {
+	name string
+	line int
+}
+
+func (e *variable) evaluate(in *interpreter, env *scope) (interface{}, error) {
+	if declared := env.lookup(e.name); declared != nil {
+		return declared.names[e.name], nil
+	}
+	if function, ok := builtins[e.name]; ok {
+		return function, nil
+	}
+	return nil, runtimeError(e.line, "undeclared variable %s", e.name)
+}
+
+type listConstructor struct {
+	items []expression
+	line  int
+}
+
+func (e *listConstructor) evaluate(in *interpreter, env *scope) (interface{}, error) {
+	if err := in.step(e.line); err != nil {
+		return nil, err
+	}
+	items := make([]interface{}, 0, len(e.items))
+	for _, item := range e.items {
+		value, err := item.evaluate(in, env)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+	}
+	return &list{items: items}, nil
+}
+
+type indexExpression struct {
+	target, index expression
+	line          int
+}
+
+// evaluate returns nil for indexes past the end of the list.
+func (e *indexExpression) evaluate(in *interpreter, env *scope) (interface{}, error) {
+	container, err := e.target.evaluate(in, env)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := container.(*list)
+	if !ok {
+		return nil, runtimeError(e.line, "can not index a %s value", typeName(container))
+	}
+	index, err := e.index.evaluate(in, env)
+	if err != nil {
+		return nil, err
+	}
+	position, ok := toInteger(index)
+	if !ok {
+		return nil, runtimeError(e.line, "list index must be an integer, found %s", describe(index))
+	}
+	if position < 1 || position > int64(len(items.items)) {
+		return nil, nil
+	}
+	return items.items[position-1], nil
+}
+
+type callExpression struct {
+	function  expression
+	arguments []expression
+	line      int
+}
+
+func (e *callExpression) evaluate(in *interpreter, env *scope) (interface{}, error) {
+	if err := in.step(e.line); err != nil {
+		return nil, err
+	}
+	function, err := e.function.evaluate(in, env)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, 0, len(e.arguments))
+	for _, argument := range e.arguments {
+		value, err := argument.evaluate(in, env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+	switch f := function.(type) {
+	case builtin:
+		return f(in, e.line, args)
+	case *closure:
+		return in.callClosure(f, e.line, args)
+	}
+	return nil, runtimeError(e.line, "can not call a %s value", typeName(function))
+}
+
+// callClosure runs f with args bound to its parameters; missing arguments
+// are nil and extra ones are dropped.
+func (in *interpreter) callClosure(f *closure, line int, args []interface{}) (interface{}, error) {
+	in.depth++
+	defer func() { in.depth-- }()
+	if in.depth > maxDepth {
+		return nil, runtimeError(line, "functions call each other deeper than %d levels", maxDepth)
+	}
+	frame := newScope(f.scope)
+	for i, parameter := range f.function.parameters {
+		var value interface{}
+		if i < len(args) {
+			value = args[i]
+		}
+		frame.names[parameter] = value
+	}
+	_, value, err := f.function.body.run(in, frame)
+	return value, err
+}
+
+type functionExpression struct {
+	name       string
+	parameters []string
+	body       *block
+	line       int
+}
+
+func (e *functionExpression) evaluate(in *interpreter, env *scope) (interface{}, error) {
+	return &closure{function: e, scope: env}, nil
+}
+
+type unaryExpression struct {
+	operator string
+	operand  expression
+	line     int
+}
+
+func (e *unaryExpression) evaluate(in *interpreter, env *scope) (interface{}, error) {
+	value, err := e.operand.evaluate(in, env)
+	if err != nil {
+		return nil, err
+	}
+	switch e.operator {
+	case "not":
+		return !truthy(value), nil
+	case "#":
+		switch v := value.(type) {
+		case string:
+			return int64(len(v)), nil
+		case *list:
+			return int64(len(v.items)), nil
+		}
+		return nil, runtimeError(e.line, "can not take the length of a %s value", typeName(value))
+	}
+	switch v := value.(type) {
+	case int64:
+		return -v, nil
+	case float64:
+		return -v, nil
+	}
+	return nil, runtimeError(e.line, "can not negate a %s value", typeName(value))
+}
+
+type binaryExpression struct {
+	operator    string
+	left, right expression
+	line        int
+}
+
+func (e *binaryExpression) evaluate(in *interpreter, env *scope) (interface{}, error) {
+	left, err := e.left.evaluate(in, env)
+	if err != nil {
+		return nil, err
+	}
+	// and and or only evaluate their right side when it decides the result
+	switch e.operator {
+	case "and":
+		if !truthy(left) {
+			return left, nil
+		}
+		return e.right.evaluate(in, env)
+	case "or":
+		if truthy(left) {
+			return left, nil
+		}
+		return e.right.evaluate(in, env)
+	}
+	right, err := e.right.evaluate(in, env)
+	if err != nil {
+		return nil, err
+	}
+	switch e.operator {
+	case "==":
+		return equal(left, right), nil
+	case "~=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		return e.compare(left, right)
+	case "..":
+		return e.concatenate(left, right)
+	}
+	return e.arithmetic(left, right)
+}
+
+func (e *binaryExpression) compare(left, right interface{}) (interface{}, error) {
+	var order int
+	leftString, leftIsString := left.(string)
+	rightString, rightIsString := right.(string)
+	leftNumber, leftIsNumber := toFloat(left)
+	rightNumber, rightIsNumber := toFloat(right)
+	switch {
+	case leftIsString && rightIsString:
+		order = compareStrings(leftString, rightString)
+	case leftIsNumber && rightIsNumber:
+		leftInteger, leftIsInteger := left.(int64)
+		rightInteger, rightIsInteger := right.(int64)
+		if leftIsInteger && rightIsInteger {
+			order = compareIntegers(leftInteger, rightInteger)
+		} else {
+			order = compareFloats(leftNumber, rightNumber)
+		}
+	default:
+		return nil, runtimeError(e.line, "can not compare %s with %s", typeName(left), typeName(right))
+	}
+	switch e.operator {
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	}
+	return order >= 0, nil
+}
+
+func (e *binaryExpression) concatenate(left, right interface{}) (interface{}, error) {
+	leftText, leftOk := concatenable(left)
+	rightText, rightOk := concatenable(right)
+	if !leftOk || !rightOk {
+		return nil, runtimeError(e.line, "can not concatenate %s with %s", typeName(left), typeName(right))
+	}
+	if len(leftText)+len(rightText) > constants.MAX_STRING_LENGTH {
+		return nil, runtimeError(e.line, "string longer than %d bytes", constants.MAX_STRING_LENGTH)
+	}
+	return leftText + rightText, nil
+}
+
+// arithmetic keeps integers exact while both sides are integers; / always
+// divides as floats and // and % round towards negative infinity.
+func (e *binaryExpression) arithmetic(left, right interface{}) (interface{}, error) {
+	leftInteger, leftIsInteger := left.(int64)
+	rightInteger, rightIsInteger := right.(int64)
+	if leftIsInteger && rightIsInteger && e.operator != "/" {
+		switch e.operator {
+		case "+":
+			return leftInteger + rightInteger, nil
+		case "-":
+			return leftInteger - rightInteger, nil
+		case "*":
+			return leftInteger * rightInteger, nil
+		}
+		if rightInteger == 0 {
+			return nil, runtimeError(e.line, "integer division by zero")
+		}
+		quotient, remainder := leftInteger/rightInteger, leftInteger%rightInteger
+		if remainder != 0 && (remainder < 0) != (rightInteger < 0) {
+			quotient, remainder = quotient-1, remainder+rightInteger
+		}
+		if e.operator == "//" {
+			return quotient, nil
+		}
+		return remainder, nil
+	}
+	leftNumber, leftOk := toFloat(left)
+	rightNumber, rightOk := toFloat(right)
+	if !leftOk || !rightOk {
+		return nil, runtimeError(e.line, "can not use %s on %s and %s", e.operator, typeName(left), typeName(right))
+	}
+	switch e.operator {
+	case "+":
+		return leftNumber + rightNumber, nil
+	case "-":
+		return leftNumber - rightNumber, nil
+	case "*":
+		return leftNumber * rightNumber, nil
+	case "/":
+		return leftNumber / rightNumber, nil
+	case "//":
+		return math.Floor(leftNumber / rightNumber), nil
+	}
+	return leftNumber - math.Floor(leftNumber/rightNumber)*rightNumber, nil
+}
+
+// truthy is false only for nil and false.
+func truthy(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	return true
+}
+
+// equal compares numbers by value whatever their type, lists and functions
+// by identity and everything else by type and value.
+func equal(left, right interface{}) bool {
+	leftNumber, leftIsNumber := toFloat(left)
+	rightNumber, rightIsNumber := toFloat(right)
+	if leftIsNumber && rightIsNumber {
+		leftInteger, leftIsInteger := left.(int64)
+		rightInteger, rightIsInteger := right.(int64)
+		if leftIsInteger && rightIsInteger {
+			return leftInteger == rightInteger
+		}
+		return leftNumber == rightNumber
+	}
+	switch l := left.(type) {
+	case builtin:
+		return false
+	case *list, *closure:
+		return l == right
+	}
+	if _, ok := right.(builtin); ok {
+		return false
+	}
+	return left == right
+}
+
+func compareIntegers(left, right int64) int {
+	switch {
+	case left < right:
+		return -1
+	case left > right:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(left, right float64) int {
+	switch {
+	case left < right:
+		return -1
+	case left > right:
+		return 1
+	}
+	return 0
+}
+
+func compareStrings(left, right string) int {
+	switch {
+	case left < right:
+		return -1
+	case left > right:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// toInteger accepts integers and floats without a fraction.
+func toInteger(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+// concatenable returns strings and numbers as text.
+func concatenable(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	}
+	return "", false
+}
+
+// typeName names the type of a value as the type builtin does.
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case int64, float64:
+		return "number"
+	case string:
+		return "string"
+	case *list:
+		return "list"
+	}
+	return "function"
+}
+
+// describe shows a value in error messages.
+func describe(value interface{}) string {
+	if text, ok := concatenable(value); ok {
+		if _, isString := value.(string); isString {
+			return strconv.Quote(text)
+		}
+		return text
+	}
+	return typeName(value)
+}
@@ -0,0 +1,191 @@
+package scripting
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenName
+	tokenKeyword
+	tokenNumber
+	tokenString
+	tokenSymbol
+)
+
+// token is one lexeme. value holds the int64, float64 or unquoted string of
+// number and string tokens.
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+	line  int
+}
+
+var keywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true,
+	"end": true, "false": true, "for": true, "function": true, "if": true,
+	"in": true, "local": true, "nil": true, "not": true, "or": true,
+	"return": true, "then": true, "true": true, "while": true,
+}
+
+// symbols are tried in order, so longer ones come before their prefixes.
+var symbols = []string{
+	"==", "~=", "<=", ">=", "//", "..",
+	"+", "-", "*", "/", "%", "#", "<", ">", "=",
+	"(", ")", "{", "}", "[", "]", ",", ";",
+}
+
+// lex splits source into tokens, ending with a tokenEOF.
+func lex(source string) ([]token, error) {
+	tokens := []token{}
+	line := 1
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(source[i:], "--"):
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+		case isLetter(c):
+			start := i
+			for i < len(source) && (isLetter(source[i]) || isDigit(source[i])) {
+				i++
+			}
+			word := source[start:i]
+			kind := tokenName
+			if keywords[word] {
+				kind = tokenKeyword
+			}
+			tokens = append(tokens, token{kind: kind, text: word, line: line})
+		case isDigit(c) || (c == '.' && i+1 < len(source) && isDigit(source[i+1])):
+			number, length, err := lexNumber(source[i:])
+			if err != nil {
+				return nil, &Error{Line: line, Err: err}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: source[i : i+length], value: number, line: line})
+			i += length
+		case c == '"' || c == '\'':
+			text, length, err := lexString(source[i:])
+			if err != nil {
+				return nil, &Error{Line: line, Err: err}
+			}
+			tokens = append(tokens, token{kind: tokenString, text: source[i : i+length], value: text, line: line})
+			i += length
+		default:
+			symbol := ""
+			for _, candidate := range symbols {
+				if strings.HasPrefix(source[i:], candidate) {
+					symbol = candidate
+					break
+				}
+			}
+			if symbol == "" {
+				return nil, &Error{Line: line, Err: fmt.Errorf("unexpected character %q", c)}
+			}
+			tokens = append(tokens, token{kind: tokenSymbol, text: symbol, line: line})
+			i += len(symbol)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, text: "end of script", line: line}), nil
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// lexNumber reads a decimal or 0x hexadecimal integer, or a float with a
+// fraction or an exponent, from the start of source.
+func lexNumber(source string) (interface{}, int, error) {
+	if strings.HasPrefix(source, "0x") || strings.HasPrefix(source, "0X") {
+		length := 2
+		for length < len(source) && strings.IndexByte("0123456789abcdefABCDEF", source[length]) >= 0 {
+			length++
+		}
+		value, err := strconv.ParseUint(source[2:length], 16, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("malformed number %q", source[:length])
+		}
+		return int64(value), length, nil
+	}
+	length := 0
+	float := false
+	for length < len(source) {
+		c := source[length]
+		switch {
+		case isDigit(c):
+		case c == '.' && !strings.HasPrefix(source[length:], ".."):
+			float = true
+		case c == 'e' || c == 'E':
+			float = true
+			if length+1 < len(source) && (source[length+1] == '+' || source[length+1] == '-') {
+				length++
+			}
+		default:
+			return parseNumber(source[:length], float)
+		}
+		length++
+	}
+	return parseNumber(source, float)
+}
+
+func parseNumber(text string, float bool) (interface{}, int, error) {
+	if !float {
+		if value, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return value, len(text), nil
+		}
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("malformed number %q", text)
+	}
+	return value, len(text), nil
+}
+
+// lexString reads a quoted string from the start of source and returns it
+// with its escapes resolved.
+func lexString(source string) (string, int, error) {
+	quote := source[0]
+	var text strings.Builder
+	for i := 1; i < len(source); i++ {
+		c := source[i]
+		switch {
+		case c == quote:
+			return text.String(), i + 1, nil
+		case c == '\n':
+			return "", 0, fmt.Errorf("unfinished string")
+		case c == '\\' && i+1 < len(source):
+			i++
+			switch source[i] {
+			case 'n':
+				text.WriteByte('\n')
+			case 't':
+				text.WriteByte('\t')
+			case 'r':
+				text.WriteByte('\r')
+			case '0':
+				text.WriteByte(0)
+			case '\\', '"', '\'':
+				text.WriteByte(source[i])
+			default:
+				return "", 0, fmt.Errorf("invalid escape \\%c in string", source[i])
+			}
+		default:
+			text.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unfinished string")
+}
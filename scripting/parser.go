@@ -0,0 +1,456 @@
+package scripting
+
+import (
+	"fmt"
+)
+
+// maxDepth bounds how deeply blocks and expressions nest and how deeply
+// functions call each other, so a script cannot exhaust the Go stack.
+const maxDepth = 200
+
+// binaryPrecedence gives the binding strength of binary operators; .. is
+// the only right associative one.
+var binaryPrecedence = map[string]int{
+	"or":  1,
+	"and": 2,
+	"==":  3, "~=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"..": 4,
+	"+":  5, "-": 5,
+	"*": 6, "/": 6, "//": 6, "%": 6,
+}
+
+// unaryPrecedence binds tighter than every binary operator.
+const unaryPrecedence = 7
+
+type parser struct {
+	tokens   []token
+	position int
+	depth    int
+}
+
+// parse turns source into the block of its top level statements.
+func parse(source string) (*block, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, p.unexpected(next, "end of script")
+	}
+	return body, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.position]
+}
+
+func (p *parser) next() token {
+	next := p.tokens[p.position]
+	if next.kind != tokenEOF {
+		p.position++
+	}
+	return next
+}
+
+// is reports whether the next token is the keyword or symbol text.
+func (p *parser) is(text string) bool {
+	next := p.peek()
+	return (next.kind == tokenKeyword || next.kind == tokenSymbol) && next.text == text
+}
+
+// accept skips the next token when it is the keyword or symbol text.
+func (p *parser) accept(text string) bool {
+	if p.is(text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.unexpected(p.peek(), fmt.Sprintf("%q", text))
+	}
+	return nil
+}
+
+func (p *parser) expectName() (string, error) {
+	next := p.peek()
+	if next.kind != tokenName {
+		return "", p.unexpected(next, "a name")
+	}
+	p.next()
+	return next.text, nil
+}
+
+func (p *parser) unexpected(found token, expected string) error {
+	return &Error{Line: found.line, Err: fmt.Errorf("expected %s, found %s", expected, found.text)}
+}
+
+// enter counts one more level of nesting and fails past maxDepth.
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > maxDepth {
+		return &Error{Line: p.peek().line, Err: fmt.Errorf("script nests deeper than %d levels", maxDepth)}
+	}
+	return nil
+}
+
+// block parses statements up to a keyword that ends the block. A return
+// must be the last statement.
+func (p *parser) block() (*block, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	body := &block{}
+	for {
+		if p.peek().kind == tokenEOF || p.is("end") || p.is("else") || p.is("elseif") {
+			return body, nil
+		}
+		if p.accept(";") {
+			continue
+		}
+		if p.is("return") {
+			line := p.next().line
+			result := &returnStatement{line: line}
+			if !p.is("end") && !p.is("else") && !p.is("elseif") && !p.is(";") && p.peek().kind != tokenEOF {
+				value, err := p.expression(0)
+				if err != nil {
+					return nil, err
+				}
+				result.value = value
+			}
+			p.accept(";")
+			body.statements = append(body.statements, result)
+			return body, nil
+		}
+		next, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		body.statements = append(body.statements, next)
+	}
+}
+
+func (p *parser) statement() (statement, error) {
+	start := p.peek()
+	switch {
+	case p.accept("local"):
+		if p.accept("function") {
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			function, err := p.functionBody(name, start.line)
+			if err != nil {
+				return nil, err
+			}
+			return &localFunctionStatement{name: name, function: function}, nil
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		declaration := &localStatement{name: name, line: start.line}
+		if p.accept("=") {
+			if declaration.value, err = p.expression(0); err != nil {
+				return nil, err
+			}
+		}
+		return declaration, nil
+	case p.accept("if"):
+		return p.ifStatement()
+	case p.accept("while"):
+		condition, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		body, err := p.doBlock()
+		if err != nil {
+			return nil, err
+		}
+		return &whileStatement{condition: condition, body: body, line: start.line}, nil
+	case p.accept("for"):
+		return p.forStatement(start.line)
+	case p.accept("do"):
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		return &doStatement{body: body}, p.expect("end")
+	case p.accept("break"):
+		return &breakStatement{}, nil
+	case p.is("function"):
+		return nil, &Error{Line: start.line, Err: fmt.Errorf("functions must be declared with local function")}
+	}
+	target, err := p.suffixedExpression()
+	if err != nil {
+		return nil, err
+	}
+	if p.accept("=") {
+		switch target.(type) {
+		case *variable, *indexExpression:
+		default:
+			return nil, &Error{Line: start.line, Err: fmt.Errorf("can not assign to this expression")}
+		}
+		value, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		return &assignStatement{target: target, value: value, line: start.line}, nil
+	}
+	call, ok := target.(*callExpression)
+	if !ok {
+		return nil, &Error{Line: start.line, Err: fmt.Errorf("expected a statement, found an expression")}
+	}
+	return &callStatement{call: call}, nil
+}
+
+// ifStatement parses what follows if, up to its end.
+func (p *parser) ifStatement() (statement, error) {
+	result := &ifStatement{}
+	for {
+		condition, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("then"); err != nil {
+			return nil, err
+		}
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		result.conditions = append(result.conditions, condition)
+		result.bodies = append(result.bodies, body)
+		if !p.accept("elseif") {
+			break
+		}
+	}
+	if p.accept("else") {
+		otherwise, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		result.otherwise = otherwise
+	}
+	return result, p.expect("end")
+}
+
+// forStatement parses for name = start, stop [, step] do ... end and
+// for index, value in list do ... end.
+func (p *parser) forStatement(line int) (statement, error) {
+	first, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	if p.accept("=") {
+		loop := &numericForStatement{name: first, line: line}
+		if loop.start, err = p.expression(0); err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if loop.stop, err = p.expression(0); err != nil {
+			return nil, err
+		}
+		if p.accept(",") {
+			if loop.step, err = p.expression(0); err != nil {
+				return nil, err
+			}
+		}
+		loop.body, err = p.doBlock()
+		return loop, err
+	}
+	loop := &listForStatement{index: first, line: line}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	if loop.value, err = p.expectName(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("in"); err != nil {
+		return nil, err
+	}
+	if loop.list, err = p.expression(0); err != nil {
+		return nil, err
+	}
+	loop.body, err = p.doBlock()
+	return loop, err
+}
+
+// doBlock parses do block end.
+func (p *parser) doBlock() (*block, error) {
+	if err := p.expect("do"); err != nil {
+		return nil, err
+	}
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	return body, p.expect("end")
+}
+
+// functionBody parses (parameters) block end.
+func (p *parser) functionBody(name string, line int) (*functionExpression, error) {
+	function := &functionExpression{name: name, line: line}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for !p.accept(")") {
+		if len(function.parameters) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		parameter, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		function.parameters = append(function.parameters, parameter)
+	}
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	function.body = body
+	return function, p.expect("end")
+}
+
+// expression parses operators binding tighter than limit by precedence
+// climbing.
+func (p *parser) expression(limit int) (expression, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	var left expression
+	start := p.peek()
+	if (start.kind == tokenKeyword || start.kind == tokenSymbol) && (start.text == "not" || start.text == "-" || start.text == "#") {
+		p.next()
+		operand, err := p.expression(unaryPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		left = &unaryExpression{operator: start.text, operand: operand, line: start.line}
+	} else {
+		simple, err := p.simpleExpression()
+		if err != nil {
+			return nil, err
+		}
+		left = simple
+	}
+	for {
+		operator := p.peek()
+		precedence, ok := binaryPrecedence[operator.text]
+		if !ok || (operator.kind != tokenKeyword && operator.kind != tokenSymbol) || precedence <= limit {
+			return left, nil
+		}
+		p.next()
+		// .. is right associative, so its right side may hold another ..
+		next := precedence
+		if operator.text == ".." {
+			next--
+		}
+		right, err := p.expression(next)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpression{operator: operator.text, left: left, right: right, line: operator.line}
+	}
+}
+
+func (p *parser) simpleExpression() (expression, error) {
+	start := p.peek()
+	switch {
+	case start.kind == tokenNumber || start.kind == tokenString:
+		p.next()
+		return &constant{value: start.value}, nil
+	case p.accept("nil"):
+		return &constant{}, nil
+	case p.accept("true"):
+		return &constant{value: true}, nil
+	case p.accept("false"):
+		return &constant{value: false}, nil
+	case p.accept("function"):
+		return p.functionBody("anonymous function", start.line)
+	case p.accept("{"):
+		constructor := &listConstructor{line: start.line}
+		for !p.accept("}") {
+			item, err := p.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			constructor.items = append(constructor.items, item)
+			if !p.accept(",") && !p.accept(";") {
+				if err := p.expect("}"); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		return constructor, nil
+	}
+	return p.suffixedExpression()
+}
+
+// suffixedExpression parses a name or parenthesized expression followed by
+// any number of [index] and (arguments).
+func (p *parser) suffixedExpression() (expression, error) {
+	start := p.peek()
+	var result expression
+	switch {
+	case start.kind == tokenName:
+		p.next()
+		result = &variable{name: start.text, line: start.line}
+	case p.accept("("):
+		inner, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		result = inner
+	default:
+		return nil, p.unexpected(start, "an expression")
+	}
+	for {
+		suffix := p.peek()
+		switch {
+		case p.accept("["):
+			index, err := p.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			result = &indexExpression{target: result, index: index, line: suffix.line}
+		case p.accept("("):
+			call := &callExpression{function: result, line: suffix.line}
+			for !p.accept(")") {
+				if len(call.arguments) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				argument, err := p.expression(0)
+				if err != nil {
+					return nil, err
+				}
+				call.arguments = append(call.arguments, argument)
+			}
+			result = call
+		default:
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package scripting
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Error is a compile or runtime error of a script at a source line. Err
+// is the error of the failing command when call failed.
+type Error struct {
+	Line int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err.Error())
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Caller runs the store command name with args for call and returns its
+// reply.
+type Caller func(name string, args []interface{}) (interface{}, error)
+
+// Limits stop a script that runs too long. A step is one statement, loop
+// iteration or function call; zero disables either limit.
+type Limits struct {
+	MaxSteps int64
+	Timeout  time.Duration
+}
+
+// Script is a compiled script, which can run any number of times.
+type Script struct {
+	Source string
+	Hash   string
+	body   *block
+}
+
+// Hash returns the hex SHA-1 of source, the name a script is cached by.
+func Hash(source string) string {
+	sum := sha1.Sum([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+// Compile parses source.
+func Compile(source string) (*Script, error) {
+	body, err := parse(source)
+	if err != nil {
+		return nil, err
+	}
+	return &Script{Source: source, Hash: Hash(source), body: body}, nil
+}
+
+// Run executes the script with the lists KEYS and ARGV and returns what it
+// returned, as a reply value: true becomes 1, false nil and lists
+// []interface{}. Commands the script ran before an error keep their
+// effect.
+func (script *Script) Run(keys []string, args []interface{}, call Caller, limits Limits) (interface{}, error) {
+	in := &interpreter{call: call, maxSteps: limits.MaxSteps}
+	if limits.Timeout > 0 {
+		in.deadline = time.Now().Add(limits.Timeout)
+	}
+	globals := newScope(nil)
+	keyList := &list{items: make([]interface{}, 0, len(keys))}
+	for _, key := range keys {
+		keyList.items = append(keyList.items, key)
+	}
+	globals.names["KEYS"] = keyList
+	globals.names["ARGV"] = fromReply(args)
+	_, value, err := script.body.run(in, globals)
+	if err != nil {
+		return nil, err
+	}
+	return toReply(value, 0)
+}
+
+// toArgument turns a script value into a command argument. Lists become
+// arrays: of integers when every item is one, of floats when every item
+// is a number and of strings otherwise.
+func toArgument(line int, value interface{}) (interface{}, error) {
+	items, ok := value.(*list)
+	if !ok {
+		switch value.(type) {
+		case nil, int64, float64, string:
+			return value, nil
+		}
+		return nil, runtimeError(line, "can not pass a %s value to a command", typeName(value))
+	}
+	integers, floats, text := true, true, false
+	for _, item := range items.items {
+		switch item.(type) {
+		case int64:
+		case float64:
+			integers = false
+		case string:
+			integers, floats, text = false, false, true
+		default:
+			return nil, runtimeError(line, "can not pass a list holding a %s value to a command", typeName(item))
+		}
+	}
+	switch {
+	case len(items.items) == 0:
+		return []string{}, nil
+	case integers:
+		array := make([]int64, 0, len(items.items))
+		for _, item := range items.items {
+			array = append(array, item.(int64))
+		}
+		return array, nil
+	case floats && !text:
+		array := make([]float64, 0, len(items.items))
+		for _, item := range items.items {
+			number, _ := toFloat(item)
+			array = append(array, number)
+		}
+		return array, nil
+	}
+	array := make([]string, 0, len(items.items))
+	for _, item := range items.items {
+		text, _ := concatenable(item)
+		array = append(array, text)
+	}
+	return array, nil
+}
+
+// fromReply turns a command reply into a script value, arrays of any kind
+// into lists.
+func fromReply(reply interface{}) interface{} {
+	switch v := reply.(type) {
+	case []int64:
+		items := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			items = append(items, item)
+		}
+		return &list{items: items}
+	case []float64:
+		items := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			items = append(items, item)
+		}
+		return &list{items: items}
+	case []string:
+		items := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			items = append(items, item)
+		}
+		return &list{items: items}
+	case []interface{}:
+		items := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			items = append(items, fromReply(item))
+		}
+		return &list{items: items}
+	}
+	return reply
+}
+
+// maxReplyDepth matches how deeply the protocol lets lists nest.
+const maxReplyDepth = 32
+
+// toReply turns the value a script returned into a reply value.
+func toReply(value interface{}, depth int) (interface{}, error) {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return nil, nil
+	case *list:
+		if depth >= maxReplyDepth {
+			return nil, fmt.Errorf("scripts can not return lists nested deeper than %d levels", maxReplyDepth)
+		}
+		items := make([]interface{}, 0, len(v.items))
+		for _, item := range v.items {
+			reply, err := toReply(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, reply)
+		}
+		return items, nil
+	case nil, int64, float64, string:
+		return v, nil
+	}
+	return nil, fmt.Errorf("scripts can not return a %s value", typeName(value))
+}
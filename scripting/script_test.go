@@ -0,0 +1,202 @@
+package scripting
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// noCalls is the caller of scripts that must not run commands.
+func noCalls(name string, args []interface{}) (interface{}, error) {
+	return nil, errors.New("unexpected call of " + name)
+}
+
+func run(t *testing.T, source string, keys []string, args []interface{}, call Caller, limits Limits) (interface{}, error) {
+	t.Helper()
+	script, err := Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	return script.Run(keys, args, call, limits)
+}
+
+func TestEvaluation(t *testing.T) {
+	tests := []struct {
+		source string
+		reply  interface{}
+	}{
+		{"return 1 + 2 * 3", int64(7)},
+		{"return (1 + 2) * 3", int64(9)},
+		{"return 7 // 2", int64(3)},
+		{"return 7 % 3", int64(1)},
+		{"return 7 / 2", 3.5},
+		{"return 2 - 3 - 4", int64(-5)},
+		{`return "a" .. "b" .. 1`, "ab1"},
+		{"return 1 < 2 and 2 <= 2", int64(1)},
+		{"return 1 == 2", nil},
+		{"return nil or 5", int64(5)},
+		{"return not nil", int64(1)},
+		{"return 1 ~= 1", nil},
+		{"return #{1, 2, 3} + #\"ab\"", int64(5)},
+		{"local t = {1, 2} t[3] = 3 return t", []interface{}{int64(1), int64(2), int64(3)}},
+		{"local s = 0 for i = 1, 10 do s = s + i end return s", int64(55)},
+		{"local s = 0 for i = 10, 1, -3 do s = s + i end return s", int64(22)},
+		{"local s = 0 for i, v in ipairs({4, 5}) do s = s + i * v end return s", int64(14)},
+		{"local n = 0 while true do n = n + 1 if n == 4 then break end end return n", int64(4)},
+		{"local x = 3 if x > 5 then return 1 elseif x > 2 then return 2 else return 3 end", int64(2)},
+		{"local function f(n) if n < 2 then return n end return f(n - 1) + f(n - 2) end return f(10)", int64(55)},
+		{"local add = function(a, b) return a + b end return add(2, 3)", int64(5)},
+		{"local x = 1 do local x = 2 end return x", int64(1)},
+		{"local t = {} insert(t, 1) insert(t, 2) remove(t) return t", []interface{}{int64(1)}},
+		{`return upper(sub("hello", 2, 3)) .. lower("X")`, "ELx"},
+		{`return tonumber("12") + tonumber(" 1.5 ")`, 13.5},
+		{`return tonumber("nope")`, nil},
+		{"return type({}) .. type(1) .. type(nil)", "listnumbernil"},
+		{"return min(3, 1, 2) + max(1, 5) + abs(-2) + floor(1.5) + ceil(1.5)", int64(11)},
+		{"return {1, {2, true, false}}", []interface{}{int64(1), []interface{}{int64(2), int64(1), nil}}},
+		{"-- comment\nreturn 1", int64(1)},
+		{"", nil},
+	}
+	for _, test := range tests {
+		reply, err := run(t, test.source, nil, nil, noCalls, Limits{})
+		if err != nil {
+			t.Errorf("%q failed: %v", test.source, err)
+			continue
+		}
+		if !reflect.DeepEqual(reply, test.reply) {
+			t.Errorf("%q returned %#v, expected %#v", test.source, reply, test.reply)
+		}
+	}
+}
+
+func TestKeysAndArguments(t *testing.T) {
+	reply, err := run(t, "return {KEYS[1], #KEYS, ARGV[1], ARGV[2][2]}", []string{"k"}, []interface{}{"a", []int64{1, 2}}, noCalls, Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{"k", int64(1), "a", int64(2)}
+	if !reflect.DeepEqual(reply, expected) {
+		t.Fatalf("expected %#v, found %#v", expected, reply)
+	}
+}
+
+func TestCallPassesArgumentsAndReplies(t *testing.T) {
+	var calls [][]interface{}
+	call := func(name string, args []interface{}) (interface{}, error) {
+		calls = append(calls, append([]interface{}{name}, args...))
+		return []string{"x", "y"}, nil
+	}
+	reply, err := run(t, `local r = call("GET", "k", 1, 1.5, {1, 2}, {1, 2.5}, {"a", 1}) return r[2]`, nil, nil, call, Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "y" {
+		t.Fatalf("expected the reply to become a list, found %#v", reply)
+	}
+	expected := []interface{}{"GET", "k", int64(1), 1.5, []int64{1, 2}, []float64{1, 2.5}, []string{"a", "1"}}
+	if len(calls) != 1 || !reflect.DeepEqual(calls[0], expected) {
+		t.Fatalf("expected the call %#v, found %#v", expected, calls)
+	}
+}
+
+func TestCommandErrorsStopTheScript(t *testing.T) {
+	failure := errors.New("wrong type")
+	calls := 0
+	call := func(name string, args []interface{}) (interface{}, error) {
+		calls++
+		return nil, failure
+	}
+	_, err := run(t, "local x = 1\ncall(\"INCR\", \"k\")\ncall(\"INCR\", \"k\")", nil, nil, call, Limits{})
+	var scriptError *Error
+	if !errors.As(err, &scriptError) || scriptError.Line != 2 || !errors.Is(err, failure) {
+		t.Fatalf("expected the command's error at line 2, found %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected the script to stop after the failing call, it made %d", calls)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		source  string
+		line    int
+		message string
+	}{
+		{"return 1 +", 1, "expected"},
+		{"local x = \"unfinished", 1, "unfinished string"},
+		{"return 1 @ 2", 1, "unexpected character"},
+		{"x = 1", 1, "undeclared variable x"},
+		{"\nreturn y", 2, "undeclared variable y"},
+		{"function f() end", 1, "local function"},
+		{"return 1 // 0", 1, "division by zero"},
+		{"return {} .. 1", 1, "concatenate"},
+		{"return 1 < \"a\"", 1, "compare"},
+		{"local t = {} t[3] = 1", 1, "out of range"},
+		{"local x = 1 return x()", 1, "call a number"},
+		{"error(\"stop here\")", 1, "stop here"},
+		{"return function() end", 1, "can not return a function"},
+		{"call(\"SET\", \"k\", function() end)", 1, "can not pass"},
+		{"return sub(1)", 1, "wrong number of arguments for sub"},
+		{"call = 1", 1, "builtin call"},
+	}
+	for _, test := range tests {
+		_, err := run(t, test.source, nil, nil, noCalls, Limits{})
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%q: expected an error containing %q, found %v", test.source, test.message, err)
+			continue
+		}
+		var scriptError *Error
+		if errors.As(err, &scriptError) && scriptError.Line != test.line {
+			t.Errorf("%q: expected the error at line %d, found %d", test.source, test.line, scriptError.Line)
+		}
+	}
+}
+
+func TestStepLimit(t *testing.T) {
+	_, err := run(t, "while true do end", nil, nil, noCalls, Limits{MaxSteps: 1000})
+	if err == nil || !strings.Contains(err.Error(), "limit of 1000 steps") {
+		t.Fatalf("expected the step limit to stop the script, found %v", err)
+	}
+	if _, err := run(t, "local s = 0 for i = 1, 100 do s = s + i end return s", nil, nil, noCalls, Limits{MaxSteps: 1000}); err != nil {
+		t.Fatalf("expected a short loop to stay within the limit, found %v", err)
+	}
+}
+
+func TestTimeLimit(t *testing.T) {
+	start := time.Now()
+	_, err := run(t, "while true do end", nil, nil, noCalls, Limits{Timeout: 50 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "time limit") {
+		t.Fatalf("expected the time limit to stop the script, found %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("the script ran for %s past a 50ms limit", elapsed)
+	}
+}
+
+func TestDepthLimits(t *testing.T) {
+	nested := strings.Repeat("(", maxDepth+1) + "1" + strings.Repeat(")", maxDepth+1)
+	if _, err := Compile("return " + nested); err == nil || !strings.Contains(err.Error(), "nests deeper") {
+		t.Fatalf("expected deeply nested expressions to be rejected, found %v", err)
+	}
+	blocks := strings.Repeat("do ", maxDepth+1) + strings.Repeat("end ", maxDepth+1)
+	if _, err := Compile(blocks); err == nil || !strings.Contains(err.Error(), "nests deeper") {
+		t.Fatalf("expected deeply nested blocks to be rejected, found %v", err)
+	}
+	_, err := run(t, "local function f(n) return f(n + 1) end return f(1)", nil, nil, noCalls, Limits{})
+	if err == nil || !strings.Contains(err.Error(), "deeper than") {
+		t.Fatalf("expected unbounded recursion to be stopped, found %v", err)
+	}
+	_, err = run(t, "local t = {} for i = 1, 40 do t = {t} end return t", nil, nil, noCalls, Limits{})
+	if err == nil || !strings.Contains(err.Error(), "nested deeper") {
+		t.Fatalf("expected a deeply nested reply to be rejected, found %v", err)
+	}
+}
+
+func TestHash(t *testing.T) {
+	// the SHA-1 of "return 1"
+	if hash := Hash("return 1"); hash != "e0e1f9fabfc9d4800c877a703b823ac0578ff8db" {
+		t.Fatalf("unexpected hash %s", hash)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"in-memory-store/protocol"
+	"in-memory-store/schemas"
+	"in-memory-store/snapshots"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// serveUntilShutdown runs the server until SIGINT or SIGTERM, drains the
+// connections and writes the final snapshot. SIGHUP reloads the config. A
+// second SIGINT or SIGTERM while draining closes the connections at once.
+// The returned exit code is non zero when the server failed or the
+// snapshot could not be written.
+func serveUntilShutdown(server *protocol.Server, databases *schemas.Databases) int {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	exitCode := 0
+	serverErrors := make(chan error, 1)
+	go func() {
+		serverErrors <- server.ListenAndServe()
+	}()
+	running := true
+	for running {
+		select {
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				if err := server.ReloadConfig(); err != nil {
+					zap.L().Error("Failed reloading config, keeping the running one", zap.Error(err))
+				}
+				continue
+			}
+			zap.L().Info("Received signal, shutting down", zap.String("signal", sig.String()))
+			running = false
+		case err := <-serverErrors:
+			if err != nil {
+				zap.L().Error("Server stopped", zap.Error(err))
+				exitCode = 1
+			}
+			running = false
+		}
+	}
+
+	timeout := server.Config().ShutdownTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	drained := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig == syscall.SIGHUP {
+					continue
+				}
+				zap.L().Info("Received second signal, closing connections", zap.String("signal", sig.String()))
+				cancel()
+				return
+			case <-drained:
+				return
+			}
+		}
+	}()
+	err := server.Shutdown(ctx)
+	close(drained)
+	if errors.Is(err, context.Canceled) {
+		zap.L().Warn("Stopped draining connections, closed them")
+	} else if err != nil {
+		zap.L().Warn("Connections did not drain in time, closed them", zap.Duration("timeout", timeout), zap.Error(err))
+	}
+
+	if err := snapshots.SaveSnapShot(databases, server.Config().SnapshotPath); err != nil {
+		zap.L().Error("Failed writing final snapshot", zap.Error(err))
+		return 1
+	}
+	zap.L().Info("Final snapshot written")
+	return exitCode
+}
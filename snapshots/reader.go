@@ -54,6 +54,27 @@ func (reader *BinaryReader) skipBlockSeperator() error {
 	return nil
 }
 
+// checkCount fails unless count elements of at least elementSize bytes
+// each fit in what is left of the file, so a corrupt count can not make
+// the reader allocate without bound.
+func (reader *BinaryReader) checkCount(count int64, elementSize int64) error {
+	if count < 0 {
+		return fmt.Errorf("Negative element count %d", count)
+	}
+	info, err := reader.file.Stat()
+	if err != nil {
+		return err
+	}
+	offset, err := reader.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if remaining := info.Size() - offset; count > remaining/elementSize {
+		return fmt.Errorf("Element count %d does not fit in the %d bytes left", count, remaining)
+	}
+	return nil
+}
+
 func (reader *BinaryReader) getInt64DataFromBlock() (int64, error) {
 	bytes := make([]byte, constants.INT_TYPE_LENGTH)
 	l, err := reader.file.Read(bytes)
@@ -67,12 +88,15 @@ func (reader *BinaryReader) getInt64DataFromBlock() (int64, error) {
 	return int64(binary.LittleEndian.Uint64(bytes)), nil
 }
 func (reader *BinaryReader) getInt64ArrayDataFromBlock(length int64) ([]int64, error) {
+	if err := reader.checkCount(length, int64(constants.INT_TYPE_LENGTH)); err != nil {
+		return nil, err
+	}
 	bytes := make([]byte, length*int64(constants.INT_TYPE_LENGTH))
 	l, err := reader.file.Read(bytes)
 	if err != nil {
 		return nil, err
 	}
-	if l < int(length) {
+	if l < len(bytes) {
 		return nil, fmt.Errorf("Expected 8 bytes but found only %d while reading integer", l)
 	}
 	var int64Array []int64
@@ -97,12 +121,15 @@ func (reader *BinaryReader) getFloat64DataFromBlock() (float64, error) {
 	return math.Float64frombits(bits), nil
 }
 func (reader *BinaryReader) getFloat64ArrayDataFromBlock(length int64) ([]float64, error) {
+	if err := reader.checkCount(length, int64(constants.FLOAT_TYPE_LENGTH)); err != nil {
+		return nil, err
+	}
 	bytes := make([]byte, length*int64(constants.FLOAT_TYPE_LENGTH))
 	l, err := reader.file.Read(bytes)
 	if err != nil {
 		return nil, err
 	}
-	if l < int(length) {
+	if l < len(bytes) {
 		return nil, fmt.Errorf("Expected 8 bytes but found only %d while reading float", l)
 	}
 	var float64Array []float64
@@ -116,6 +143,11 @@ func (reader *BinaryReader) getFloat64ArrayDataFromBlock(length int64) ([]float6
 }
 
 func (reader *BinaryReader) getStringDataFromBlock(stringLength int64) (string, error) {
+	// the NUL ending the string is left to the read to find missing, as
+	// stringLength+1 could overflow
+	if err := reader.checkCount(stringLength, 1); err != nil {
+		return "", err
+	}
 	bytes := make([]byte, stringLength+1)
 	l, err := reader.file.Read(bytes)
 	if err != nil {
@@ -126,25 +158,98 @@ func (reader *BinaryReader) getStringDataFromBlock(stringLength int64) (string,
 	}
 	return string(bytes[:stringLength]), err
 }
+
+// minimum sizes of the elements of a snapshot's collections, used to
+// check their counts before reading them
+var (
+	// a length and the NUL ending the string
+	minStringSize = int64(constants.INT_TYPE_LENGTH + 1)
+	// a name, a value type and a value of at least 8 bytes
+	minHashFieldSize = minStringSize + int64(2*constants.INT_TYPE_LENGTH)
+	// a member and its score
+	minSortedSetMemberSize = minStringSize + int64(constants.FLOAT_TYPE_LENGTH)
+)
+
 func (reader *BinaryReader) getStringArrayDataFromBlock(stringArrayLength int64) ([]string, error) {
+	if err := reader.checkCount(stringArrayLength, minStringSize); err != nil {
+		return nil, err
+	}
 	stringArray := []string{}
 	for i := 0; i < int(stringArrayLength); i++ {
 		stringLength, err := reader.getInt64DataFromBlock()
 		if err != nil {
 			return nil, fmt.Errorf("Failed to read string length at index %d", i)
 		}
-		bytes := make([]byte, stringLength+1)
-		l, err := reader.file.Read(bytes)
+		value, err := reader.getStringDataFromBlock(stringLength)
 		if err != nil {
 			return nil, err
 		}
-		if l < int(stringLength+1) {
-			return nil, fmt.Errorf("Expected %d bytes but found only %d while reading string (array)", stringLength, l)
-		}
-		stringArray = append(stringArray, string(bytes[:stringLength]))
+		stringArray = append(stringArray, value)
 	}
 	return stringArray, nil
 }
+func (reader *BinaryReader) getHashDataFromBlock(fieldCount int64) (map[string]interface{}, error) {
+	if err := reader.checkCount(fieldCount, minHashFieldSize); err != nil {
+		return nil, err
+	}
+	hash := make(map[string]interface{})
+	for i := 0; i < int(fieldCount); i++ {
+		fieldLength, err := reader.getInt64DataFromBlock()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read field length at index %d", i)
+		}
+		field, err := reader.getStringDataFromBlock(fieldLength)
+		if err != nil {
+			return nil, err
+		}
+		valueType, err := reader.getInt64DataFromBlock()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read value type of field %s", field)
+		}
+		switch valueType {
+		case constants.INTEGER_TYPE:
+			hash[field], err = reader.getInt64DataFromBlock()
+		case constants.FLOAT_TYPE:
+			hash[field], err = reader.getFloat64DataFromBlock()
+		case constants.STRING_TYPE:
+			var valueLength int64
+			valueLength, err = reader.getInt64DataFromBlock()
+			if err == nil {
+				hash[field], err = reader.getStringDataFromBlock(valueLength)
+			}
+		default:
+			return nil, fmt.Errorf("Unknown value type %d in field %s", valueType, field)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return hash, nil
+}
+
+func (reader *BinaryReader) getSortedSetDataFromBlock(memberCount int64) (*schemas.SortedSet, error) {
+	if err := reader.checkCount(memberCount, minSortedSetMemberSize); err != nil {
+		return nil, err
+	}
+	set := schemas.NewSortedSet()
+	for i := 0; i < int(memberCount); i++ {
+		memberLength, err := reader.getInt64DataFromBlock()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read member length at index %d", i)
+		}
+		member, err := reader.getStringDataFromBlock(memberLength)
+		if err != nil {
+			return nil, err
+		}
+		score, err := reader.getFloat64DataFromBlock()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read score of member %s", member)
+		}
+		set.Add(member, score)
+	}
+	return set, nil
+}
+
 func handleError(err error, context string) bool {
 	if err == io.EOF {
 		return true
@@ -156,8 +261,12 @@ func handleError(err error, context string) bool {
 	return false
 }
 
-func ReadSnapShotFile(mainMap *schemas.MainMap) {
-	f, err := os.Open(constants.SNAPSHOT_FILE_NAME)
+// ReadSnapShotFile loads the snapshot at path into databases. Records
+// before the first database block, as written by older versions, belong
+// to the default database; databases the snapshot names but databases
+// lacks are created so they are not lost.
+func ReadSnapShotFile(databases *schemas.Databases, path string) {
+	f, err := os.Open(path)
 	defer f.Close()
 	if err != nil {
 		zap.L().Warn("Error reading snapshot file", zap.Error(err))
@@ -173,6 +282,7 @@ func ReadSnapShotFile(mainMap *schemas.MainMap) {
 		return
 	}
 	zap.L().Info("Reading snapshot file", zap.Int64("version", version))
+	mainMap := databases.Open(constants.DEFAULT_DATABASE)
 	for {
 		blockValueType, err := reader.getInt64DataFromBlock()
 		if handleError(err, "Error while reading block type") {
@@ -188,6 +298,11 @@ func ReadSnapShotFile(mainMap *schemas.MainMap) {
 			return
 		}
 		switch blockValueType {
+		case constants.DATABASE_TYPE:
+			if _, ok := databases.Get(key); !ok {
+				zap.L().Warn("Snapshot holds a database that is not configured, keeping it", zap.String("database", key))
+			}
+			mainMap = databases.Open(key)
 		case constants.INTEGER_TYPE:
 			intValue, err := reader.getInt64DataFromBlock()
 			if handleError(err, "Error while reading integer block value") {
@@ -240,6 +355,126 @@ func ReadSnapShotFile(mainMap *schemas.MainMap) {
 				return
 			}
 			mainMap.SetStringArray(key, blockValue)
+		case constants.SET_TYPE:
+			memberCount, err := reader.getInt64DataFromBlock()
+			if handleError(err, "Error while reading set size for block value") {
+				return
+			}
+			members, err := reader.getStringArrayDataFromBlock(memberCount)
+			if handleError(err, "Error while reading set block value") {
+				return
+			}
+			mainMap.SetSet(key, members)
+		case constants.HASH_TYPE:
+			fieldCount, err := reader.getInt64DataFromBlock()
+			if handleError(err, "Error while reading hash size for block value") {
+				return
+			}
+			fields, err := reader.getHashDataFromBlock(fieldCount)
+			if handleError(err, "Error while reading hash block value") {
+				return
+			}
+			mainMap.SetHash(key, fields)
+		case constants.SORTED_SET_TYPE:
+			memberCount, err := reader.getInt64DataFromBlock()
+			if handleError(err, "Error while reading sorted set size for block value") {
+				return
+			}
+			set, err := reader.getSortedSetDataFromBlock(memberCount)
+			if handleError(err, "Error while reading sorted set block value") {
+				return
+			}
+			mainMap.SetSortedSet(key, set)
+		case constants.VECTOR_INDEX_TYPE:
+			prefixLength, err := reader.getInt64DataFromBlock()
+			if handleError(err, "Error while reading vector index prefix length") {
+				return
+			}
+			prefix, err := reader.getStringDataFromBlock(prefixLength)
+			if handleError(err, "Error while reading vector index prefix") {
+				return
+			}
+			settings, err := reader.getInt64ArrayDataFromBlock(6)
+			if handleError(err, "Error while reading vector index settings") {
+				return
+			}
+			err = mainMap.CreateVectorIndex(schemas.VectorIndex{
+				Name:           key,
+				Prefix:         prefix,
+				Dimension:      int(settings[0]),
+				Metric:         schemas.VectorMetric(settings[1]),
+				HNSW:           settings[2] == 1,
+				M:              int(settings[3]),
+				EfConstruction: int(settings[4]),
+				EfSearch:       int(settings[5]),
+			})
+			if err != nil {
+				zap.L().Error("Error while restoring vector index", zap.String("name", key), zap.Error(err))
+			}
+		case constants.RANGE_INDEX_TYPE:
+			prefixLength, err := reader.getInt64DataFromBlock()
+			if handleError(err, "Error while reading range index prefix length") {
+				return
+			}
+			prefix, err := reader.getStringDataFromBlock(prefixLength)
+			if handleError(err, "Error while reading range index prefix") {
+				return
+			}
+			valueType, err := reader.getInt64DataFromBlock()
+			if handleError(err, "Error while reading range index value type") {
+				return
+			}
+			typeName := "integer"
+			if valueType == constants.FLOAT_TYPE {
+				typeName = "float"
+			}
+			if err := mainMap.CreateRangeIndex(key, prefix, typeName); err != nil {
+				zap.L().Error("Error while restoring range index", zap.String("name", key), zap.Error(err))
+			}
+		case constants.VERSIONS_TYPE:
+			header, err := reader.getInt64ArrayDataFromBlock(2)
+			if handleError(err, "Error while reading version clock") {
+				return
+			}
+			// each entry holds a key and a version
+			err = reader.checkCount(header[1], minStringSize+int64(constants.INT_TYPE_LENGTH))
+			if handleError(err, "Error while reading version count") {
+				return
+			}
+			versions := make(map[string]int64, header[1])
+			for i := int64(0); i < header[1]; i++ {
+				keyLength, err := reader.getInt64DataFromBlock()
+				if handleError(err, "Error while reading versioned key length") {
+					return
+				}
+				versionedKey, err := reader.getStringDataFromBlock(keyLength)
+				if handleError(err, "Error while reading versioned key") {
+					return
+				}
+				version, err := reader.getInt64DataFromBlock()
+				if handleError(err, "Error while reading key version") {
+					return
+				}
+				versions[versionedKey] = version
+			}
+			mainMap.RestoreVersions(header[0], versions)
+		case constants.TEXT_INDEX_TYPE:
+			prefixCount, err := reader.getInt64DataFromBlock()
+			if handleError(err, "Error while reading text index prefix count") {
+				return
+			}
+			prefixes, err := reader.getStringArrayDataFromBlock(prefixCount)
+			if handleError(err, "Error while reading text index prefixes") {
+				return
+			}
+			if err := mainMap.CreateTextIndex(key, prefixes); err != nil {
+				zap.L().Error("Error while restoring text index", zap.String("name", key), zap.Error(err))
+			}
+		default:
+			// the length of an unknown value is unknown too, so nothing
+			// after it can be read
+			zap.L().Error("Unknown block type, ignoring the rest of the snapshot", zap.Int64("type", blockValueType), zap.String("key", key))
+			return
 		}
 		err = reader.skipBlockSeperator()
 		if handleError(err, "Error while skipping block") {
@@ -0,0 +1,156 @@
+package snapshots
+
+import (
+	"bytes"
+	"encoding/binary"
+	"in-memory-store/constants"
+	"in-memory-store/schemas"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// saveAndRead saves databases to a temporary snapshot, reads it into a
+// fresh set of databases and returns those with the snapshot's path.
+func saveAndRead(t *testing.T, databases *schemas.Databases) (*schemas.Databases, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "snapshot")
+	if err := SaveSnapShot(databases, path); err != nil {
+		t.Fatalf("saving the snapshot failed: %v", err)
+	}
+	restored := schemas.NewDatabases(1, nil)
+	ReadSnapShotFile(restored, path)
+	return restored, path
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	databases := schemas.NewDatabases(2, nil)
+	mainMap, _ := databases.Get("0")
+	mainMap.SetInteger("int", 42)
+	mainMap.SetString("string", "text")
+	mainMap.SetFloat("float", 1.5)
+	mainMap.SetIntegerArray("ints", []int64{1, 2})
+	mainMap.SetStringArray("strings", []string{"a", ""})
+	mainMap.SetFloatArray("floats", []float64{0.5})
+	mainMap.SetAdd("set", "x", "y")
+	mainMap.HashSet("hash", map[string]interface{}{"field": "value", "count": int64(3)})
+	mainMap.SortedSetAdd("zset", map[string]float64{"low": 1, "high": 2})
+	other, _ := databases.Get("1")
+	other.SetString("elsewhere", "kept apart")
+
+	restored, _ := saveAndRead(t, databases)
+	restoredMap, _ := restored.Get("0")
+	for _, key := range []string{"int", "string", "float", "ints", "strings", "floats"} {
+		if !reflect.DeepEqual(restoredMap.GetValue(key), mainMap.GetValue(key)) {
+			t.Errorf("key %s restored as %#v, saved %#v", key, restoredMap.GetValue(key), mainMap.GetValue(key))
+		}
+	}
+	if members, _ := restoredMap.SetMembers("set"); len(members) != 2 {
+		t.Errorf("expected 2 set members, found %v", members)
+	}
+	if value, _ := restoredMap.HashGet("hash", "count"); value != int64(3) {
+		t.Errorf("expected hash field count 3, found %#v", value)
+	}
+	if rank, ok, _ := restoredMap.SortedSetRank("zset", "high", false); !ok || rank != 1 {
+		t.Errorf("expected high at rank 1, found %d, %v", rank, ok)
+	}
+	if version, saved := restoredMap.Version("int"), mainMap.Version("int"); version != saved {
+		t.Errorf("expected version %d, found %d", saved, version)
+	}
+	restoredOther, ok := restored.Get("1")
+	if !ok || restoredOther.GetValue("elsewhere") != "kept apart" || restoredMap.GetValue("elsewhere") != nil {
+		t.Errorf("expected the second database to be restored on its own")
+	}
+}
+
+func TestFailedSaveKeepsThePreviousSnapshot(t *testing.T) {
+	databases := schemas.NewDatabases(1, nil)
+	mainMap, _ := databases.Get("0")
+	mainMap.SetInteger("int", 1)
+	_, path := saveAndRead(t, databases)
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a directory in the way of the temporary file makes the save fail
+	if err := os.Mkdir(path+".tmp", 0700); err != nil {
+		t.Fatal(err)
+	}
+	mainMap.SetInteger("int", 2)
+	if err := SaveSnapShot(databases, path); err == nil {
+		t.Fatal("expected the save to fail")
+	}
+	if after, _ := os.ReadFile(path); !reflect.DeepEqual(after, before) {
+		t.Fatal("the failed save replaced the previous snapshot")
+	}
+}
+
+// corruptBlock writes a snapshot holding one block of blockType for key k
+// followed by the given little endian integers.
+func corruptBlock(t *testing.T, blockType int64, values ...int64) string {
+	t.Helper()
+	var buffer bytes.Buffer
+	buffer.WriteString(constants.FILE_HEADER)
+	// the snapshot version, the block type and the key's length
+	binary.Write(&buffer, binary.LittleEndian, []int64{1, blockType, 1})
+	buffer.WriteString("k\x00")
+	binary.Write(&buffer, binary.LittleEndian, values)
+	path := filepath.Join(t.TempDir(), "snapshot")
+	if err := os.WriteFile(path, buffer.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCorruptCountsAreRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		block  int64
+		values []int64
+	}{
+		{"set member count", constants.SET_TYPE, []int64{math.MaxInt64}},
+		{"set member length", constants.SET_TYPE, []int64{1, math.MaxInt64}},
+		{"hash field count", constants.HASH_TYPE, []int64{math.MaxInt64}},
+		{"hash field length", constants.HASH_TYPE, []int64{1, math.MaxInt64 - 1, 0, 0}},
+		{"sorted set member count", constants.SORTED_SET_TYPE, []int64{math.MaxInt64 / 2}},
+		{"sorted set member length", constants.SORTED_SET_TYPE, []int64{1, 1 << 40, 0}},
+		{"negative count", constants.SET_TYPE, []int64{-1}},
+		{"integer array length", constants.INTEGER_ARRAY_TYPE, []int64{math.MaxInt64 / 4}},
+		{"string length", constants.STRING_TYPE, []int64{math.MaxInt64}},
+	}
+	for _, test := range tests {
+		path := corruptBlock(t, test.block, test.values...)
+		databases := schemas.NewDatabases(1, nil)
+		// reading must neither panic nor allocate what the counts ask for
+		ReadSnapShotFile(databases, path)
+		if mainMap, _ := databases.Get("0"); mainMap.TypeOf("k") != "none" {
+			t.Errorf("%s: expected the corrupt block to be dropped, found a %s", test.name, mainMap.TypeOf("k"))
+		}
+	}
+}
+
+func TestTruncatedSnapshotsAreRead(t *testing.T) {
+	databases := schemas.NewDatabases(1, nil)
+	mainMap, _ := databases.Get("0")
+	mainMap.SetInteger("a", 1)
+	mainMap.SetAdd("set", "x", "y")
+	mainMap.HashSet("hash", map[string]interface{}{"field": "value"})
+	mainMap.SortedSetAdd("zset", map[string]float64{"member": 1})
+	mainMap.SetStringArray("strings", []string{"a", "b"})
+	_, path := saveAndRead(t, databases)
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every prefix of a snapshot must be read without panicking, keeping
+	// the blocks that were complete
+	for length := range saved {
+		truncated := filepath.Join(t.TempDir(), "snapshot")
+		if err := os.WriteFile(truncated, saved[:length], 0600); err != nil {
+			t.Fatal(err)
+		}
+		ReadSnapShotFile(schemas.NewDatabases(1, nil), truncated)
+	}
+}
@@ -3,6 +3,7 @@ package snapshots
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"go.uber.org/zap"
 	"in-memory-store/constants"
 	"in-memory-store/schemas"
@@ -102,7 +103,7 @@ func convertIntegerMapToBinary(minmap *schemas.MainMap) ([]byte, error) {
 		keyBytes := []byte(key)
 
 		// write the type of the value
-		if err := binary.Write(&buffer, binary.LittleEndian, int64(constants.INTEGER_ARRAY_TYPE)); err != nil {
+		if err := binary.Write(&buffer, binary.LittleEndian, int64(constants.INTEGER_TYPE)); err != nil {
 			return nil, err
 		}
 		// write the length of the key
@@ -214,64 +215,445 @@ func convertFloatArrayMapToBinary(minmap *schemas.MainMap) ([]byte, error) {
 	}
 	return buffer.Bytes(), nil
 }
-func createBytesForSnapShot(mainMap *schemas.MainMap) *bytes.Buffer {
+func convertSetMapToBinary(minmap *schemas.MainMap) ([]byte, error) {
+	var buffer bytes.Buffer
+	for key, set := range minmap.SET_MAP {
+		keyBytes := []byte(key)
+
+		// write the type of the value
+		if err := binary.Write(&buffer, binary.LittleEndian, constants.SET_TYPE); err != nil {
+			return nil, err
+		}
+		// write the length of the key
+		if err := binary.Write(&buffer, binary.LittleEndian, int64(len(keyBytes))); err != nil {
+			return nil, err
+		}
+		// write the key
+		if err := binary.Write(&buffer, binary.LittleEndian, keyBytes); err != nil {
+			return nil, err
+		}
+		buffer.WriteByte(byte(0))
+		// write the number of members, each is then written like a string
+		// array element
+		if err := binary.Write(&buffer, binary.LittleEndian, int64(len(set))); err != nil {
+			return nil, err
+		}
+		for member := range set {
+			memberBytes := []byte(member)
+			if err := binary.Write(&buffer, binary.LittleEndian, int64(len(memberBytes))); err != nil {
+				return nil, err
+			}
+			if err := binary.Write(&buffer, binary.LittleEndian, memberBytes); err != nil {
+				return nil, err
+			}
+			buffer.WriteByte(byte(0))
+		}
+		buffer.WriteString("\r\n")
+	}
+	return buffer.Bytes(), nil
+}
+
+func convertHashMapToBinary(minmap *schemas.MainMap) ([]byte, error) {
+	var buffer bytes.Buffer
+	for key, hash := range minmap.HASH_MAP {
+		keyBytes := []byte(key)
+
+		// write the type of the value
+		if err := binary.Write(&buffer, binary.LittleEndian, constants.HASH_TYPE); err != nil {
+			return nil, err
+		}
+		// write the length of the key
+		if err := binary.Write(&buffer, binary.LittleEndian, int64(len(keyBytes))); err != nil {
+			return nil, err
+		}
+		// write the key
+		if err := binary.Write(&buffer, binary.LittleEndian, keyBytes); err != nil {
+			return nil, err
+		}
+		buffer.WriteByte(byte(0))
+		// write the number of fields
+		if err := binary.Write(&buffer, binary.LittleEndian, int64(len(hash))); err != nil {
+			return nil, err
+		}
+		for field, value := range hash {
+			// write the field like a string array element
+			fieldBytes := []byte(field)
+			if err := binary.Write(&buffer, binary.LittleEndian, int64(len(fieldBytes))); err != nil {
+				return nil, err
+			}
+			if err := binary.Write(&buffer, binary.LittleEndian, fieldBytes); err != nil {
+				return nil, err
+			}
+			buffer.WriteByte(byte(0))
+			// write the type of the field value, then the value the way a
+			// top level value of that type is written
+			switch v := value.(type) {
+			case int64:
+				if err := binary.Write(&buffer, binary.LittleEndian, constants.INTEGER_TYPE); err != nil {
+					return nil, err
+				}
+				if err := binary.Write(&buffer, binary.LittleEndian, v); err != nil {
+					return nil, err
+				}
+			case float64:
+				if err := binary.Write(&buffer, binary.LittleEndian, constants.FLOAT_TYPE); err != nil {
+					return nil, err
+				}
+				if err := binary.Write(&buffer, binary.LittleEndian, v); err != nil {
+					return nil, err
+				}
+			case string:
+				valueBytes := []byte(v)
+				if err := binary.Write(&buffer, binary.LittleEndian, constants.STRING_TYPE); err != nil {
+					return nil, err
+				}
+				if err := binary.Write(&buffer, binary.LittleEndian, int64(len(valueBytes))); err != nil {
+					return nil, err
+				}
+				if err := binary.Write(&buffer, binary.LittleEndian, valueBytes); err != nil {
+					return nil, err
+				}
+				buffer.WriteByte(byte(0))
+			default:
+				return nil, fmt.Errorf("unsupported type %T in field %s of hash %s", value, field, key)
+			}
+		}
+		buffer.WriteString("\r\n")
+	}
+	return buffer.Bytes(), nil
+}
+
+func convertSortedSetMapToBinary(minmap *schemas.MainMap) ([]byte, error) {
+	var buffer bytes.Buffer
+	for key, set := range minmap.SORTED_SET_MAP {
+		keyBytes := []byte(key)
+
+		// write the type of the value
+		if err := binary.Write(&buffer, binary.LittleEndian, constants.SORTED_SET_TYPE); err != nil {
+			return nil, err
+		}
+		// write the length of the key
+		if err := binary.Write(&buffer, binary.LittleEndian, int64(len(keyBytes))); err != nil {
+			return nil, err
+		}
+		// write the key
+		if err := binary.Write(&buffer, binary.LittleEndian, keyBytes); err != nil {
+			return nil, err
+		}
+		buffer.WriteByte(byte(0))
+		// write the number of members
+		if err := binary.Write(&buffer, binary.LittleEndian, int64(set.Len())); err != nil {
+			return nil, err
+		}
+		for _, scored := range set.Members() {
+			// write the member like a string array element, then its score
+			memberBytes := []byte(scored.Member)
+			if err := binary.Write(&buffer, binary.LittleEndian, int64(len(memberBytes))); err != nil {
+				return nil, err
+			}
+			if err := binary.Write(&buffer, binary.LittleEndian, memberBytes); err != nil {
+				return nil, err
+			}
+			buffer.WriteByte(byte(0))
+			if err := binary.Write(&buffer, binary.LittleEndian, scored.Score); err != nil {
+				return nil, err
+			}
+		}
+		buffer.WriteString("\r\n")
+	}
+	return buffer.Bytes(), nil
+}
+
+// convertVectorIndexesToBinary writes the definition of every vector
+// index, keyed by its name. Graphs are rebuilt from the float arrays when
+// the snapshot is read, so the indexes are written after them.
+func convertVectorIndexesToBinary(minmap *schemas.MainMap) ([]byte, error) {
+	var buffer bytes.Buffer
+	for _, index := range minmap.VectorIndexes() {
+		nameBytes := []byte(index.Name)
+		prefixBytes := []byte(index.Prefix)
+
+		// write the type of the value
+		if err := binary.Write(&buffer, binary.LittleEndian, constants.VECTOR_INDEX_TYPE); err != nil {
+			return nil, err
+		}
+		// write the length of the name
+		if err := binary.Write(&buffer, binary.LittleEndian, int64(len(nameBytes))); err != nil {
+			return nil, err
+		}
+		// write the name
+		if err := binary.Write(&buffer, binary.LittleEndian, nameBytes); err != nil {
+			return nil, err
+		}
+		buffer.WriteByte(byte(0))
+		// write the prefix like a string value
+		if err := binary.Write(&buffer, binary.LittleEndian, int64(len(prefixBytes))); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(&buffer, binary.LittleEndian, prefixBytes); err != nil {
+			return nil, err
+		}
+		buffer.WriteByte(byte(0))
+		// write dimension, metric, whether it has a graph and the graph
+		// parameters
+		hnsw := int64(0)
+		if index.HNSW {
+			hnsw = 1
+		}
+		settings := []int64{int64(index.Dimension), int64(index.Metric), hnsw, int64(index.M), int64(index.EfConstruction), int64(index.EfSearch)}
+		if err := binary.Write(&buffer, binary.LittleEndian, settings); err != nil {
+			return nil, err
+		}
+		buffer.WriteString("\r\n")
+	}
+	return buffer.Bytes(), nil
+}
+
+// convertRangeIndexesToBinary writes the name, prefix and value type of
+// every range index. Like vector indexes they are rebuilt on read.
+func convertRangeIndexesToBinary(minmap *schemas.MainMap) ([]byte, error) {
+	var buffer bytes.Buffer
+	for _, index := range minmap.RangeIndexes() {
+		nameBytes := []byte(index.Name)
+		prefixBytes := []byte(index.Prefix)
+
+		// write the type of the value
+		if err := binary.Write(&buffer, binary.LittleEndian, constants.RANGE_INDEX_TYPE); err != nil {
+			return nil, err
+		}
+		// write the length of the name
+		if err := binary.Write(&buffer, binary.LittleEndian, int64(len(nameBytes))); err != nil {
+			return nil, err
+		}
+		// write the name
+		if err := binary.Write(&buffer, binary.LittleEndian, nameBytes); err != nil {
+			return nil, err
+		}
+		buffer.WriteByte(byte(0))
+		// write the prefix like a string value
+		if err := binary.Write(&buffer, binary.LittleEndian, int64(len(prefixBytes))); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(&buffer, binary.LittleEndian, prefixBytes); err != nil {
+			return nil, err
+		}
+		buffer.WriteByte(byte(0))
+		// write the type of the indexed values
+		valueType := constants.INTEGER_TYPE
+		if index.Type == "float" {
+			valueType = constants.FLOAT_TYPE
+		}
+		if err := binary.Write(&buffer, binary.LittleEndian, valueType); err != nil {
+			return nil, err
+		}
+		buffer.WriteString("\r\n")
+	}
+	return buffer.Bytes(), nil
+}
+
+// convertTextIndexesToBinary writes the name and prefixes of every text
+// index. Postings are rebuilt from the strings on read.
+func convertTextIndexesToBinary(minmap *schemas.MainMap) ([]byte, error) {
+	var buffer bytes.Buffer
+	for _, index := range minmap.TextIndexes() {
+		nameBytes := []byte(index.Name)
+
+		// write the type of the value
+		if err := binary.Write(&buffer, binary.LittleEndian, constants.TEXT_INDEX_TYPE); err != nil {
+			return nil, err
+		}
+		// write the length of the name
+		if err := binary.Write(&buffer, binary.LittleEndian, int64(len(nameBytes))); err != nil {
+			return nil, err
+		}
+		// write the name
+		if err := binary.Write(&buffer, binary.LittleEndian, nameBytes); err != nil {
+			return nil, err
+		}
+		buffer.WriteByte(byte(0))
+		// write the prefixes like a string array
+		if err := binary.Write(&buffer, binary.LittleEndian, int64(len(index.Prefixes))); err != nil {
+			return nil, err
+		}
+		for _, prefix := range index.Prefixes {
+			prefixBytes := []byte(prefix)
+			if err := binary.Write(&buffer, binary.LittleEndian, int64(len(prefixBytes))); err != nil {
+				return nil, err
+			}
+			if err := binary.Write(&buffer, binary.LittleEndian, prefixBytes); err != nil {
+				return nil, err
+			}
+			buffer.WriteByte(byte(0))
+		}
+		buffer.WriteString("\r\n")
+	}
+	return buffer.Bytes(), nil
+}
+
+// convertVersionsToBinary writes the version clock and the version of
+// every key in one block with an empty key. It follows the values, whose
+// loading assigns fresh versions, so the saved ones replace them.
+func convertVersionsToBinary(minmap *schemas.MainMap) ([]byte, error) {
+	var buffer bytes.Buffer
+	clock, versions := minmap.Versions()
+	// write the type of the value
+	if err := binary.Write(&buffer, binary.LittleEndian, constants.VERSIONS_TYPE); err != nil {
+		return nil, err
+	}
+	// write the empty key
+	if err := binary.Write(&buffer, binary.LittleEndian, int64(0)); err != nil {
+		return nil, err
+	}
+	buffer.WriteByte(byte(0))
+	// write the clock and the number of keys, then each key like a string
+	// array element followed by its version
+	if err := binary.Write(&buffer, binary.LittleEndian, []int64{clock, int64(len(versions))}); err != nil {
+		return nil, err
+	}
+	for key, version := range versions {
+		keyBytes := []byte(key)
+		if err := binary.Write(&buffer, binary.LittleEndian, int64(len(keyBytes))); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(&buffer, binary.LittleEndian, keyBytes); err != nil {
+			return nil, err
+		}
+		buffer.WriteByte(byte(0))
+		if err := binary.Write(&buffer, binary.LittleEndian, version); err != nil {
+			return nil, err
+		}
+	}
+	buffer.WriteString("\r\n")
+	return buffer.Bytes(), nil
+}
+
+// convertDatabaseNameToBinary writes the block that starts the records of
+// the database called name. It holds only the name, like a key.
+func convertDatabaseNameToBinary(name string) ([]byte, error) {
+	var buffer bytes.Buffer
+	nameBytes := []byte(name)
+	// write the type of the value
+	if err := binary.Write(&buffer, binary.LittleEndian, constants.DATABASE_TYPE); err != nil {
+		return nil, err
+	}
+	// write the length of the name
+	if err := binary.Write(&buffer, binary.LittleEndian, int64(len(nameBytes))); err != nil {
+		return nil, err
+	}
+	// write the name
+	if err := binary.Write(&buffer, binary.LittleEndian, nameBytes); err != nil {
+		return nil, err
+	}
+	buffer.WriteByte(byte(0))
+	buffer.WriteString("\r\n")
+	return buffer.Bytes(), nil
+}
+
+// createBytesForSnapShot writes every database, each starting with a
+// block naming it. All databases stay read locked until the last one is
+// written, so a transaction spanning several is captured whole or not at
+// all. Any section failing fails the whole snapshot.
+func createBytesForSnapShot(databases *schemas.Databases) (*bytes.Buffer, error) {
+	databases.RLockAll()
+	defer databases.RUnlockAll()
 	mainBuffer, err := createFileHeader()
 	if err != nil {
-		zap.L().Error("Failed to create file header", zap.Error(err))
+		return nil, fmt.Errorf("creating file header: %w", err)
 	}
-	// write integer bytes
-	integerBinBytes, err := convertIntegerMapToBinary(mainMap)
-	if err != nil {
-		zap.L().Error("Failed creating integer map bin", zap.Error(err))
+	for _, name := range databases.Names() {
+		mainMap, _ := databases.Get(name)
+		databaseBin, err := convertDatabaseNameToBinary(name)
+		if err != nil {
+			return nil, fmt.Errorf("creating database %s bin: %w", name, err)
+		}
+		mainBuffer.Write(databaseBin)
+		if err := writeDatabase(mainBuffer, mainMap); err != nil {
+			return nil, fmt.Errorf("database %s: %w", name, err)
+		}
 	}
-	mainBuffer.Write(integerBinBytes)
-	// write integer array bytes
-	integerArrayBytes, err := convertIntegerArrayMapToBinary(mainMap)
-	if err != nil {
-		zap.L().Error("Failed creating integer array map bin", zap.Error(err))
+	return mainBuffer, nil
+}
+
+// snapshotSections are the blocks of a database in the order they are
+// written; indexes follow the values they cover and versions come last.
+var snapshotSections = []struct {
+	name    string
+	convert func(mainMap *schemas.MainMap) ([]byte, error)
+}{
+	{"integer map", convertIntegerMapToBinary},
+	{"integer array map", convertIntegerArrayMapToBinary},
+	{"string map", convertStringMapToBin},
+	{"string array map", convertStringArrayMapToBin},
+	{"float map", convertFloatMapToBinary},
+	{"float array map", convertFloatArrayMapToBinary},
+	{"set map", convertSetMapToBinary},
+	{"hash map", convertHashMapToBinary},
+	{"sorted set map", convertSortedSetMapToBinary},
+	{"vector index", convertVectorIndexesToBinary},
+	{"range index", convertRangeIndexesToBinary},
+	{"text index", convertTextIndexesToBinary},
+	{"versions", convertVersionsToBinary},
+}
+
+func writeDatabase(mainBuffer *bytes.Buffer, mainMap *schemas.MainMap) error {
+	for _, section := range snapshotSections {
+		bin, err := section.convert(mainMap)
+		if err != nil {
+			return fmt.Errorf("creating %s bin: %w", section.name, err)
+		}
+		mainBuffer.Write(bin)
 	}
-	mainBuffer.Write(integerArrayBytes)
-	// write string bytes
-	stringBinBytes, err := convertStringMapToBin(mainMap)
+	return nil
+}
+
+// saveLock keeps snapshots taken at the same time from sharing the
+// temporary file.
+var saveLock sync.Mutex
+
+// SaveSnapShot writes the snapshot of every database to a temporary file,
+// syncs it and renames it over the previous snapshot, so a crash never
+// leaves a partial file. When any part fails the previous snapshot is
+// kept and the error returned.
+func SaveSnapShot(databases *schemas.Databases, path string) error {
+	saveLock.Lock()
+	defer saveLock.Unlock()
+	buffer, err := createBytesForSnapShot(databases)
 	if err != nil {
-		zap.L().Error("Failed creating string map bin", zap.Error(err))
+		return err
 	}
-	mainBuffer.Write(stringBinBytes)
-	// write string array bytes
-	stringArrayBinBytes, err := convertStringArrayMapToBin(mainMap)
+	tempFileName := path + ".tmp"
+	file, err := os.Create(tempFileName)
 	if err != nil {
-		zap.L().Error("Failed creating string array map bin", zap.Error(err))
+		return err
 	}
-	mainBuffer.Write(stringArrayBinBytes)
-	// write float bytes
-	floatBinBytes, err := convertFloatMapToBinary(mainMap)
-	if err != nil {
-		zap.L().Error("Failed creating float map bin", zap.Error(err))
+	if _, err := file.Write(buffer.Bytes()); err != nil {
+		file.Close()
+		os.Remove(tempFileName)
+		return err
 	}
-	mainBuffer.Write(floatBinBytes)
-	// write float array bytes
-	floatArrayBin, err := convertFloatArrayMapToBinary(mainMap)
-	if err != nil {
-		zap.L().Error("Failed creating float array map bin", zap.Error(err))
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tempFileName)
+		return err
 	}
-	mainBuffer.Write(floatArrayBin)
-	return mainBuffer
+	if err := file.Close(); err != nil {
+		os.Remove(tempFileName)
+		return err
+	}
+	return os.Rename(tempFileName, path)
 }
-func takeSnapShot(wg *sync.WaitGroup, mainMap *schemas.MainMap) {
+
+func takeSnapShot(wg *sync.WaitGroup, databases *schemas.Databases, path string) {
 	defer wg.Done()
-	file, err := os.Create(constants.SNAPSHOT_FILE_NAME)
-	if err != nil {
+	if err := SaveSnapShot(databases, path); err != nil {
 		zap.L().Error("Error while taking snapshot of file", zap.Error(err))
+		return
 	}
-	buffer := createBytesForSnapShot(mainMap)
-	bytes := buffer.Bytes()
-	file.Write(bytes)
 	zap.L().Info("Snapshot taken successfully")
-	file.Close()
 }
-func RunSnapShotTaker(mainMap *schemas.MainMap) {
+func RunSnapShotTaker(databases *schemas.Databases, path string) {
 	var wg sync.WaitGroup
 	wg.Add(1)
-	go takeSnapShot(&wg, mainMap)
+	go takeSnapShot(&wg, databases, path)
 	wg.Wait()
 }